@@ -15,6 +15,8 @@
 package core
 
 import (
+	"knative.dev/kperf/pkg/command/restore"
+	"knative.dev/kperf/pkg/command/run"
 	"knative.dev/kperf/pkg/command/service"
 	"knative.dev/kperf/pkg/command/version"
 	"knative.dev/kperf/pkg/config"
@@ -34,6 +36,8 @@ func NewPerfCommand(params ...pkg.PerfParams) *cobra.Command {
 		Long:  `A CLI to help with Knative performance test.`,
 	}
 	rootCmd.AddCommand(service.NewServiceCmd(p))
+	rootCmd.AddCommand(run.NewRunCommand(p))
+	rootCmd.AddCommand(restore.NewRestoreCommand(p))
 	rootCmd.AddCommand(version.NewVersionCommand())
 
 	cobra.OnInitialize(initConfig)
@@ -29,6 +29,8 @@ func TestNewPerfCommand(t *testing.T) {
 			"help",
 			"version",
 			"service",
+			"run",
+			"restore",
 		}
 
 		cmd := NewPerfCommand()
@@ -20,6 +20,7 @@ require (
 	knative.dev/networking v0.0.0-20230914014443-e3c3201da0e3
 	knative.dev/pkg v0.0.0-20230914012755-978068686674
 	knative.dev/serving v0.38.1-0.20230914175124-55bec5f5f8ca
+	sigs.k8s.io/yaml v1.3.0
 )
 
 require (
@@ -79,5 +80,4 @@ require (
 	k8s.io/utils v0.0.0-20221108210102-8e77b1f39fe2 // indirect
 	sigs.k8s.io/json v0.0.0-20220713155537-f223a00ba0e2 // indirect
 	sigs.k8s.io/structured-merge-diff/v4 v4.2.3 // indirect
-	sigs.k8s.io/yaml v1.3.0 // indirect
 )
@@ -0,0 +1,67 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/mutation"
+)
+
+// NewRestoreCommand implements 'kperf restore' command
+func NewRestoreCommand(p *pkg.PerfParams) *cobra.Command {
+	restoreArgs := pkg.RestoreArgs{}
+	restoreCommand := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore the changes of a kperf run to the cluster",
+		Long: `Restore the changes a kperf run made to the cluster, like the annotations set by 'kperf service scale',
+from the journal of the run. They are restored at the end of the run, this command recovers them if kperf was killed.
+
+For example:
+# To restore the changes of the run printed by 'kperf service scale'
+kperf restore --run-id 20230203093607-x1y2z
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if restoreArgs.RunID == "" {
+				return fmt.Errorf("'restore' requires flag --run-id")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Restore(p, restoreArgs)
+		},
+	}
+	restoreCommand.Flags().StringVarP(&restoreArgs.RunID, "run-id", "", "", "ID of the run to restore the changes of")
+	restoreCommand.Flags().StringVarP(&restoreArgs.JournalDir, "journal-dir", "", mutation.DefaultDir(), "Directory of the journal of the run")
+	return restoreCommand
+}
+
+// Restore restores the changes recorded in the journal of the run
+func Restore(params *pkg.PerfParams, inputs pkg.RestoreArgs) error {
+	journal, err := mutation.Load(inputs.JournalDir, inputs.RunID)
+	if errors.Is(err, mutation.ErrNotFound) {
+		fmt.Printf("no change to restore for run %s\n", inputs.RunID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return journal.Restore(context.Background(), mutation.NewPatcher(params))
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/mutation"
+	"knative.dev/kperf/pkg/testutil"
+)
+
+func TestNewRestoreCommand(t *testing.T) {
+	ctx := context.Background()
+	cfgm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config-autoscaler", Namespace: "knative-serving"},
+		Data: map[string]string{"allow-zero-initial-scale": "true"}}
+	p := &pkg.PerfParams{ClientSet: k8sfake.NewSimpleClientset(cfgm)}
+	dir := t.TempDir()
+
+	t.Run("require the run ID", func(t *testing.T) {
+		cmd := NewRestoreCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "--journal-dir", dir)
+		assert.ErrorContains(t, err, "'restore' requires flag --run-id")
+	})
+
+	t.Run("nothing to restore", func(t *testing.T) {
+		cmd := NewRestoreCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "--run-id", "run-0", "--journal-dir", dir)
+		assert.NilError(t, err)
+	})
+
+	t.Run("restore the changes of a killed run", func(t *testing.T) {
+		journal, err := mutation.Open(dir, "run-1")
+		assert.NilError(t, err)
+		noop := func(context.Context, string, string, string, []byte) error {
+			return nil
+		}
+		err = journal.Apply(ctx, noop, mutation.Change{Kind: mutation.KindConfigMap, Namespace: "knative-serving", Name: "config-autoscaler",
+			Path: []string{"data"}, Original: map[string]*string{"allow-zero-initial-scale": nil}}, map[string]*string{})
+		assert.NilError(t, err)
+
+		cmd := NewRestoreCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "--run-id", "run-1", "--journal-dir", dir)
+		assert.NilError(t, err)
+
+		got, err := p.ClientSet.CoreV1().ConfigMaps("knative-serving").Get(ctx, "config-autoscaler", metav1.GetOptions{})
+		assert.NilError(t, err)
+		_, exists := got.Data["allow-zero-initial-scale"]
+		assert.Assert(t, !exists)
+		_, err = mutation.Load(dir, "run-1")
+		assert.ErrorIs(t, err, mutation.ErrNotFound)
+	})
+}
@@ -0,0 +1,314 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/command/service"
+	"knative.dev/serving/pkg/apis/serving"
+)
+
+const (
+	ScenarioOutputFilename = "scenario_result"
+
+	StatusSucceeded = "Succeeded"
+	StatusFailed    = "Failed"
+	StatusSkipped   = "Skipped"
+
+	defaultWaitTimeout  = 10 * time.Minute
+	defaultWaitInterval = 5 * time.Second
+)
+
+// stepCommands maps the step types of a scenario to the commands executing them
+var stepCommands = map[string]func(*pkg.PerfParams) *cobra.Command{
+	"generate": service.NewServiceGenerateCommand,
+	"measure":  service.NewServiceMeasureCommand,
+	"scale":    service.NewServiceScaleCommand,
+	"load":     service.NewServiceLoadCommand,
+	"clean":    service.NewServiceCleanCommand,
+	"update":   service.NewServiceUpdateCommand,
+	"traffic":  service.NewServiceTrafficCommand,
+}
+
+// NewRunCommand implements 'kperf run' command
+func NewRunCommand(p *pkg.PerfParams) *cobra.Command {
+	runArgs := pkg.ScenarioArgs{}
+	runCommand := &cobra.Command{
+		Use:   "run SCENARIO",
+		Short: "Run a scenario file",
+		Long: `Run the steps of a scenario file in order and save all the results in one directory
+
+For example:
+# To generate, measure and clean Knative Services as described in scenario.yaml
+kperf run scenario.yaml --output /tmp/results
+
+The scenario file lists the kperf service commands to run and their flags:
+
+name: cold-start
+steps:
+- type: generate
+  args: {number: 10, batch: 5, interval: 1, namespace: perf}
+  wait: {for: ready, timeout: 10m}
+- type: measure
+  args: {svc-prefix: ksvc, range: "0,9", namespace: perf}
+cleanup:
+- type: clean
+  args: {namespace: perf, svc-prefix: ksvc}
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runArgs.File = args[0]
+			return RunScenario(cmd.Context(), p, runArgs)
+		},
+	}
+	runCommand.Flags().StringVarP(&runArgs.Output, "output", "o", ".", "Scenario result location")
+	return runCommand
+}
+
+// RunScenario runs the steps of the scenario file, the cleanup steps are always executed
+// even if a step fails or the run is interrupted.
+func RunScenario(ctx context.Context, params *pkg.PerfParams, inputs pkg.ScenarioArgs) error {
+	scenario, err := LoadScenario(inputs.File)
+	if err != nil {
+		return err
+	}
+
+	bundleDir, err := service.GenerateOutputPathPrefix(inputs.Output, "scenario_"+scenario.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(bundleDir, 0755); err != nil {
+		return fmt.Errorf("failed to create scenario result directory: %w", err)
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// The first interrupt stops the scenario and runs the cleanup steps, a second one exits
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	defer stop()
+
+	result := pkg.ScenarioResult{
+		Name:      scenario.Name,
+		File:      inputs.File,
+		Status:    StatusSucceeded,
+		StartTime: time.Now(),
+	}
+
+	var runErr error
+	for i := range scenario.Steps {
+		step := &scenario.Steps[i]
+		if runErr == nil && ctx.Err() != nil {
+			runErr = errors.New("scenario interrupted")
+		}
+		if runErr != nil {
+			result.Steps = append(result.Steps, pkg.ScenarioStepResult{Name: step.Name, Type: step.Type, Args: step.flags(), Status: StatusSkipped})
+			continue
+		}
+		stepResult := runStep(ctx, params, step, bundleDir)
+		result.Steps = append(result.Steps, stepResult)
+		if stepResult.Status == StatusFailed {
+			runErr = fmt.Errorf("step %s failed: %s", step.Name, stepResult.Error)
+		}
+	}
+
+	// Cleanup must not be interrupted by the cancellation of the scenario
+	for i := range scenario.Cleanup {
+		result.Cleanup = append(result.Cleanup, runStep(context.Background(), params, &scenario.Cleanup[i], bundleDir))
+	}
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).Seconds()
+	if runErr != nil {
+		result.Status = StatusFailed
+	}
+	for _, c := range result.Cleanup {
+		if c.Status == StatusFailed {
+			fmt.Printf("cleanup step %s failed: %s\n", c.Name, c.Error)
+		}
+	}
+
+	jsonPath, err := service.GenerateJSONOutput(result, filepath.Join(bundleDir, ScenarioOutputFilename))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Scenario %s %s, results saved in %s\n", scenario.Name, strings.ToLower(result.Status), filepath.Dir(jsonPath))
+	return runErr
+}
+
+// runStep executes the command of the step and evaluates its wait condition
+func runStep(ctx context.Context, params *pkg.PerfParams, step *Step, bundleDir string) pkg.ScenarioStepResult {
+	fmt.Printf("==================== step %s (%s) ====================\n", step.Name, step.Type)
+	cmd := stepCommands[step.Type](params)
+	args := step.flags()
+	// Results of the step are saved in the scenario result directory unless the step sets its own output
+	if cmd.Flags().Lookup("output") != nil {
+		if _, ok := step.Args["output"]; !ok {
+			args = append(args, "--output="+bundleDir)
+		}
+	}
+	cmd.SetArgs(args)
+	cmd.SilenceUsage = true
+
+	stepResult := pkg.ScenarioStepResult{
+		Name:      step.Name,
+		Type:      step.Type,
+		Args:      args,
+		Status:    StatusSucceeded,
+		StartTime: time.Now(),
+	}
+	err := cmd.ExecuteContext(ctx)
+	if err == nil && step.Wait != nil {
+		stepResult.Wait, err = waitForCondition(ctx, params, cmd, step.Wait)
+	}
+	stepResult.EndTime = time.Now()
+	stepResult.Duration = stepResult.EndTime.Sub(stepResult.StartTime).Seconds()
+	if err != nil {
+		stepResult.Status = StatusFailed
+		stepResult.Error = err.Error()
+	}
+	return stepResult
+}
+
+// waitForCondition sleeps for the wait duration, then waits for the services selected by
+// the flags of the executed command to reach the wait condition
+func waitForCondition(ctx context.Context, params *pkg.PerfParams, cmd *cobra.Command, cond *WaitCondition) (*pkg.ScenarioWaitResult, error) {
+	start := time.Now()
+	waitResult := &pkg.ScenarioWaitResult{For: cond.For}
+	finish := func(err error) (*pkg.ScenarioWaitResult, error) {
+		waitResult.Duration = time.Since(start).Seconds()
+		if err != nil {
+			waitResult.Error = err.Error()
+		}
+		return waitResult, err
+	}
+
+	if cond.Duration.Duration > 0 {
+		fmt.Printf("Waiting %s before next step\n", cond.Duration.Duration)
+		select {
+		case <-time.After(cond.Duration.Duration):
+		case <-ctx.Done():
+			return finish(ctx.Err())
+		}
+	}
+	if cond.For == "" {
+		return finish(nil)
+	}
+
+	timeout := cond.Timeout.Duration
+	if timeout <= 0 {
+		timeout = defaultWaitTimeout
+	}
+	interval := cond.Interval.Duration
+	if interval <= 0 {
+		interval = defaultWaitInterval
+	}
+
+	namespace := flagString(cmd, "namespace")
+	namespacePrefix := flagString(cmd, "namespace-prefix")
+	if namespace == "" && namespacePrefix == "" {
+		namespace = service.DefaultNamespace
+	}
+	nsNameList, err := service.GetNamespaces(ctx, params, namespace, flagString(cmd, "namespace-range"), namespacePrefix)
+	if err != nil {
+		return finish(err)
+	}
+	svcPrefix := flagString(cmd, "svc-prefix")
+
+	fmt.Printf("Waiting for services with prefix %s in namespaces %v to be %s\n", svcPrefix, nsNameList, cond.For)
+	var check func(context.Context, *pkg.PerfParams, []string, string) (bool, error)
+	switch cond.For {
+	case WaitForReady:
+		check = servicesReady
+	case WaitForScaledToZero:
+		check = servicesScaledToZero
+	}
+	err = wait.PollImmediateWithContext(ctx, interval, timeout, func(ctx context.Context) (bool, error) {
+		return check(ctx, params, nsNameList, svcPrefix)
+	})
+	if err != nil {
+		err = fmt.Errorf("services with prefix %s are not %s after %s: %w", svcPrefix, cond.For, timeout, err)
+	}
+	return finish(err)
+}
+
+// servicesReady returns true when there are services with the prefix in the namespaces and all of them are Ready
+func servicesReady(ctx context.Context, params *pkg.PerfParams, nsNameList []string, svcPrefix string) (bool, error) {
+	ksvcClient, err := params.NewServingClient()
+	if err != nil {
+		return false, err
+	}
+	found := 0
+	for _, ns := range nsNameList {
+		svcList, err := ksvcClient.Services(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		for i := range svcList.Items {
+			if !strings.HasPrefix(svcList.Items[i].Name, svcPrefix) {
+				continue
+			}
+			if !svcList.Items[i].IsReady() {
+				return false, nil
+			}
+			found++
+		}
+	}
+	return found > 0, nil
+}
+
+// servicesScaledToZero returns true when no deployment of the services with the prefix has replicas
+func servicesScaledToZero(ctx context.Context, params *pkg.PerfParams, nsNameList []string, svcPrefix string) (bool, error) {
+	for _, ns := range nsNameList {
+		dpList, err := params.ClientSet.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{LabelSelector: serving.ServiceLabelKey})
+		if err != nil {
+			return false, err
+		}
+		for _, dp := range dpList.Items {
+			if !strings.HasPrefix(dp.Labels[serving.ServiceLabelKey], svcPrefix) {
+				continue
+			}
+			if dp.Status.Replicas != 0 {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+func flagString(cmd *cobra.Command, name string) string {
+	f := cmd.Flags().Lookup(name)
+	if f == nil {
+		return ""
+	}
+	return f.Value.String()
+}
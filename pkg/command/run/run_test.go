@@ -0,0 +1,167 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/testutil"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
+)
+
+func writeScenario(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readScenarioResult(t *testing.T, output string) pkg.ScenarioResult {
+	matches, err := filepath.Glob(filepath.Join(output, "*_scenario_*", ScenarioOutputFilename+".json"))
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(matches))
+	data, err := os.ReadFile(matches[0])
+	assert.NilError(t, err)
+	result := pkg.ScenarioResult{}
+	assert.NilError(t, json.Unmarshal(data, &result))
+	return result
+}
+
+func fakePerfParams() *pkg.PerfParams {
+	client := k8sfake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "perf"}})
+	fakeServing := &servingv1fake.FakeServingV1{Fake: testutil.NewKnativeFake()}
+	return &pkg.PerfParams{
+		ClientSet: client,
+		NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+			return fakeServing, nil
+		},
+	}
+}
+
+func TestLoadScenario(t *testing.T) {
+	t.Run("load scenario with steps and cleanup", func(t *testing.T) {
+		path := writeScenario(t, `
+name: test
+steps:
+- type: generate
+  args: {number: 2, batch: 1, interval: 1, namespace: perf}
+  wait: {for: ready, timeout: 1m}
+- name: measure-all
+  type: measure
+  args: {svc-prefix: ksvc, range: "0,1", namespace: perf}
+cleanup:
+- type: clean
+  args: {namespace: perf, svc-prefix: ksvc}
+`)
+		scenario, err := LoadScenario(path)
+		assert.NilError(t, err)
+		assert.Equal(t, "test", scenario.Name)
+		assert.Equal(t, 2, len(scenario.Steps))
+		assert.Equal(t, "generate-0", scenario.Steps[0].Name)
+		assert.Equal(t, "measure-all", scenario.Steps[1].Name)
+		assert.Equal(t, WaitForReady, scenario.Steps[0].Wait.For)
+		assert.Equal(t, "1m0s", scenario.Steps[0].Wait.Timeout.Duration.String())
+		assert.DeepEqual(t, []string{"--batch=1", "--interval=1", "--namespace=perf", "--number=2"}, scenario.Steps[0].flags())
+		assert.Equal(t, 1, len(scenario.Cleanup))
+	})
+
+	t.Run("invalid scenarios", func(t *testing.T) {
+		_, err := LoadScenario(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.ErrorContains(t, err, "failed to read scenario file")
+
+		_, err = LoadScenario(writeScenario(t, "name: empty\n"))
+		assert.ErrorContains(t, err, "scenario \"empty\" has no steps")
+
+		_, err = LoadScenario(writeScenario(t, "steps:\n- type: unknown\n"))
+		assert.ErrorContains(t, err, "step 0 has unknown type \"unknown\"")
+
+		_, err = LoadScenario(writeScenario(t, "steps:\n- type: generate\n  wait: {for: deleted}\n"))
+		assert.ErrorContains(t, err, "unknown wait condition \"deleted\"")
+
+		_, err = LoadScenario(writeScenario(t, "steps:\n- type: generate\n  unknown: field\n"))
+		assert.ErrorContains(t, err, "failed to parse scenario file")
+	})
+}
+
+func TestRunScenario(t *testing.T) {
+	t.Run("run steps and cleanup", func(t *testing.T) {
+		p := fakePerfParams()
+		output := t.TempDir()
+		path := writeScenario(t, `
+name: test
+steps:
+- type: generate
+  args: {number: 1, batch: 1, interval: 1, namespace: perf}
+  wait: {duration: 10ms}
+cleanup:
+- type: clean
+  args: {namespace: perf, svc-prefix: ksvc}
+`)
+		err := RunScenario(context.Background(), p, pkg.ScenarioArgs{File: path, Output: output})
+		assert.NilError(t, err)
+
+		result := readScenarioResult(t, output)
+		assert.Equal(t, StatusSucceeded, result.Status)
+		assert.Equal(t, 1, len(result.Steps))
+		assert.Equal(t, StatusSucceeded, result.Steps[0].Status)
+		assert.Equal(t, 1, len(result.Cleanup))
+		assert.Equal(t, StatusSucceeded, result.Cleanup[0].Status)
+
+		ksvcClient, _ := p.NewServingClient()
+		svcList, err := ksvcClient.Services("perf").List(context.Background(), metav1.ListOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, 0, len(svcList.Items))
+	})
+
+	t.Run("failed step skips the next steps but runs cleanup", func(t *testing.T) {
+		p := fakePerfParams()
+		output := t.TempDir()
+		path := writeScenario(t, `
+steps:
+- type: generate
+  args: {number: 1, batch: 1, interval: 1, namespace: missing}
+- type: measure
+  args: {svc-prefix: ksvc, range: "0,0", namespace: perf}
+cleanup:
+- type: clean
+  args: {namespace: perf}
+`)
+		err := RunScenario(context.Background(), p, pkg.ScenarioArgs{File: path, Output: output})
+		assert.ErrorContains(t, err, "step generate-0 failed")
+
+		result := readScenarioResult(t, output)
+		assert.Equal(t, StatusFailed, result.Status)
+		assert.Equal(t, StatusFailed, result.Steps[0].Status)
+		assert.Equal(t, StatusSkipped, result.Steps[1].Status)
+		assert.Equal(t, StatusSucceeded, result.Cleanup[0].Status)
+	})
+}
+
+func TestNewRunCommand(t *testing.T) {
+	cmd := NewRunCommand(fakePerfParams())
+	_, err := testutil.ExecuteCommand(cmd)
+	assert.ErrorContains(t, err, "accepts 1 arg(s), received 0")
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package run
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// WaitForReady waits until all the Knative Services selected by the step are Ready
+	WaitForReady = "ready"
+	// WaitForScaledToZero waits until the deployments of the selected Knative Services have no replicas
+	WaitForScaledToZero = "scaled-to-zero"
+)
+
+// Scenario is the declarative description of a kperf run, it lists the steps to execute in order
+// and the cleanup steps which are executed whatever the result of the steps is.
+type Scenario struct {
+	Name    string `json:"name"`
+	Steps   []Step `json:"steps"`
+	Cleanup []Step `json:"cleanup,omitempty"`
+}
+
+// Step is a single `kperf service <type>` invocation. Args are the flags of the command,
+// e.g. {"number": 10, "namespace": "perf"} becomes `--number=10 --namespace=perf`.
+type Step struct {
+	Name string                 `json:"name,omitempty"`
+	Type string                 `json:"type"`
+	Args map[string]interface{} `json:"args,omitempty"`
+	Wait *WaitCondition         `json:"wait,omitempty"`
+}
+
+// WaitCondition is evaluated after a step has completed and before the next step starts.
+// Duration is a plain sleep, For waits for the services selected by the step args to reach
+// the given state within Timeout.
+type WaitCondition struct {
+	Duration metav1.Duration `json:"duration,omitempty"`
+	For      string          `json:"for,omitempty"`
+	Timeout  metav1.Duration `json:"timeout,omitempty"`
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
+// LoadScenario reads and validates the scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario file: %w", err)
+	}
+	scenario := &Scenario{}
+	if err := yaml.UnmarshalStrict(data, scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario file %s: %w", path, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+// Validate checks the scenario has steps with known types and wait conditions
+func (s *Scenario) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("scenario %q has no steps", s.Name)
+	}
+	if s.Name == "" {
+		s.Name = "scenario"
+	}
+	for i := range s.Steps {
+		if err := s.Steps[i].validate(i); err != nil {
+			return err
+		}
+	}
+	for i := range s.Cleanup {
+		if err := s.Cleanup[i].validate(i); err != nil {
+			return fmt.Errorf("cleanup: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Step) validate(index int) error {
+	if _, ok := stepCommands[s.Type]; !ok {
+		return fmt.Errorf("step %d has unknown type %q, expected one of %v", index, s.Type, stepTypes())
+	}
+	if s.Name == "" {
+		s.Name = fmt.Sprintf("%s-%d", s.Type, index)
+	}
+	if s.Wait != nil {
+		switch s.Wait.For {
+		case "", WaitForReady, WaitForScaledToZero:
+		default:
+			return fmt.Errorf("step %s has unknown wait condition %q, expected %q or %q", s.Name, s.Wait.For, WaitForReady, WaitForScaledToZero)
+		}
+	}
+	return nil
+}
+
+// flags converts the step args to command line flags, sorted by name to keep the order stable
+func (s *Step) flags() []string {
+	names := make([]string, 0, len(s.Args))
+	for name := range s.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	flags := make([]string, 0, len(names))
+	for _, name := range names {
+		flags = append(flags, fmt.Sprintf("--%s=%s", name, flagValue(s.Args[name])))
+	}
+	return flags
+}
+
+// flagValue formats a YAML value as flag value, YAML numbers are decoded as float64
+// and must not be printed in exponent format
+func flagValue(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func stepTypes() []string {
+	types := make([]string, 0, len(stepCommands))
+	for t := range stepCommands {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
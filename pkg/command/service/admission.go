@@ -0,0 +1,156 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"knative.dev/kperf/pkg"
+)
+
+// admissionTiming records the client side round trip of a request traced by the admission transport
+type admissionTiming struct {
+	lock sync.Mutex
+	// start is when the request is given to the transport
+	start time.Time
+	// wroteRequest is when the request is fully written to the connection
+	wroteRequest time.Time
+	// firstByte is when the first byte of the response is received, the API server having run the admission webhooks
+	firstByte time.Time
+	// done is when the response body is fully read
+	done time.Time
+}
+
+type admissionTimingKey struct{}
+
+// withAdmissionTiming returns a context recording the timing of the request made with it through the admission
+// transport
+func withAdmissionTiming(ctx context.Context) (context.Context, *admissionTiming) {
+	timing := &admissionTiming{}
+	return context.WithValue(ctx, admissionTimingKey{}, timing), timing
+}
+
+func (t *admissionTiming) mark(at *time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	*at = time.Now()
+}
+
+// result returns the timing in seconds with total the round trip measured by the caller, the phases are only set if
+// the request went through the admission transport
+func (t *admissionTiming) result(total time.Duration) *pkg.AdmissionTiming {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	result := &pkg.AdmissionTiming{Total: total.Seconds()}
+	if t.start.IsZero() || t.wroteRequest.IsZero() || t.firstByte.IsZero() || t.done.IsZero() {
+		return result
+	}
+	result.Send = t.wroteRequest.Sub(t.start).Seconds()
+	result.Server = t.firstByte.Sub(t.wroteRequest).Seconds()
+	result.Response = t.done.Sub(t.firstByte).Seconds()
+	return result
+}
+
+// admissionTransport traces the requests whose context has an admission timing
+type admissionTransport struct {
+	next http.RoundTripper
+}
+
+// wrapAdmissionTransport is a transport.WrapperFunc instrumenting the requests made with withAdmissionTiming
+func wrapAdmissionTransport(rt http.RoundTripper) http.RoundTripper {
+	return &admissionTransport{next: rt}
+}
+
+func (a *admissionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timing, ok := req.Context().Value(admissionTimingKey{}).(*admissionTiming)
+	if !ok {
+		return a.next.RoundTrip(req)
+	}
+	// a retried request is timed from its last attempt
+	timing.mark(&timing.start)
+	trace := &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			timing.mark(&timing.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			timing.mark(&timing.firstByte)
+		},
+	}
+	resp, err := a.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil || resp.Body == nil {
+		return resp, err
+	}
+	resp.Body = &timedBody{ReadCloser: resp.Body, timing: timing}
+	return resp, nil
+}
+
+// timedBody marks the admission timing done once the body is read until EOF or closed
+type timedBody struct {
+	io.ReadCloser
+	timing *admissionTiming
+	once   sync.Once
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.markDone()
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	b.markDone()
+	return b.ReadCloser.Close()
+}
+
+func (b *timedBody) markDone() {
+	b.once.Do(func() {
+		b.timing.mark(&b.timing.done)
+	})
+}
+
+// admissionResultHandler aggregates the admission timings of the Knative Services, the phases only from the timings
+// traced by the admission transport
+func admissionResultHandler(timings []*pkg.AdmissionTiming) pkg.AdmissionResult {
+	total, send, server, response := []float64{}, []float64{}, []float64{}, []float64{}
+	for _, timing := range timings {
+		total = append(total, timing.Total)
+		if timing.Send == 0 && timing.Server == 0 && timing.Response == 0 {
+			continue
+		}
+		send = append(send, timing.Send)
+		server = append(server, timing.Server)
+		response = append(response, timing.Response)
+	}
+	result := pkg.AdmissionResult{}
+	fmt.Printf("admission latency result:\n")
+	result.Total = latencyResultHandler(total)
+	if len(server) > 0 {
+		fmt.Printf("admission request send latency result:\n")
+		result.Send = latencyResultHandler(send)
+		fmt.Printf("admission server processing latency result:\n")
+		result.Server = latencyResultHandler(server)
+		fmt.Printf("admission response latency result:\n")
+		result.Response = latencyResultHandler(response)
+	}
+	return result
+}
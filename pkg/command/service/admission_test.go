@@ -0,0 +1,68 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"knative.dev/kperf/pkg"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+)
+
+func TestAdmissionTransport(t *testing.T) {
+	// the API server takes 50ms to admit the Knative Services
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	config := &rest.Config{Host: server.URL}
+	config.Wrap(wrapAdmissionTransport)
+	client, err := servingv1client.NewForConfig(config)
+	assert.NilError(t, err)
+	svc := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-0", Namespace: "test-kperf-1"}}
+
+	ctx, timing := withAdmissionTiming(context.Background())
+	start := time.Now()
+	created, err := client.Services("test-kperf-1").Create(ctx, svc, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	assert.NilError(t, err)
+	assert.Equal(t, "ksvc-0", created.Name)
+	result := timing.result(time.Since(start))
+	assert.Assert(t, result.Server >= 0.05, "server processing %f", result.Server)
+	assert.Assert(t, result.Send > 0)
+	assert.Assert(t, result.Response >= 0)
+	assert.Assert(t, result.Total >= result.Send+result.Server+result.Response)
+
+	// the requests without admission timing are not traced
+	_, err = client.Services("test-kperf-1").Create(context.Background(), svc, metav1.CreateOptions{})
+	assert.NilError(t, err)
+
+	aggregate := admissionResultHandler([]*pkg.AdmissionTiming{result, {Total: 0.2}})
+	assert.Equal(t, 0.2, aggregate.Total.Max)
+	assert.Equal(t, result.Server, aggregate.Server.Max)
+}
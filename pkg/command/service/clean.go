@@ -17,15 +17,21 @@ package service
 import (
 	"context"
 	"fmt"
-	"strings"
+	"sort"
+	"sync"
+	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"github.com/spf13/cobra"
 
 	"knative.dev/kperf/pkg"
 	"knative.dev/kperf/pkg/generator"
+	"knative.dev/kperf/pkg/target"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
 )
 
 func NewServiceCleanCommand(p *pkg.PerfParams) *cobra.Command {
@@ -38,6 +44,18 @@ func NewServiceCleanCommand(p *pkg.PerfParams) *cobra.Command {
 For example:
 # To clean Knative Service workload
 kperf service clean --namespace-prefix testns / --namespace nsname
+
+# To clean Knative Service workload and delete the namespaces created by generate --create-namespaces
+kperf service clean --namespace-prefix testns --namespace-range 1,10 --delete-namespaces
+
+# To clean only the Knative Services and namespaces created by the run 20230101120000-abcde
+kperf service clean --namespace-prefix testns --namespace-range 1,10 --run-id 20230101120000-abcde --delete-namespaces
+
+# To clean the Knative Services labeled tier=web whatever their name, the default svc-prefix being cleared
+kperf service clean --namespace-prefix testns --namespace-range 1,10 --selector tier=web --svc-prefix ""
+
+# To clean Knative Service workload and measure how long it takes for its objects to be deleted
+kperf service clean --namespace-prefix testns --namespace-range 1,10 --wait --output /tmp
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return CleanServices(p, cleanArgs)
@@ -45,17 +63,24 @@ kperf service clean --namespace-prefix testns / --namespace nsname
 	}
 
 	ksvcCleanCommand.Flags().StringVarP(&cleanArgs.NamespacePrefix, "namespace-prefix", "", "", "Namespace prefix. The ksvc in namespaces with the prefix will be cleaned.")
-	ksvcCleanCommand.Flags().StringVarP(&cleanArgs.NamespaceRange, "namespace-range", "", "", "")
+	ksvcCleanCommand.Flags().StringVarP(&cleanArgs.NamespaceRange, "namespace-range", "", "", "Namespace range like 1,500 or 1-5,8,20-30")
 	ksvcCleanCommand.Flags().StringVarP(&cleanArgs.Namespace, "namespace", "", "", "Namespace name. The ksvc in the namespace will be cleaned.")
-	ksvcCleanCommand.Flags().StringVarP(&cleanArgs.SvcPrefix, "svc-prefix", "", "testksvc", "ksvc name prefix. The ksvcs will be svcPrefix1,svcPrefix2,svcPrefix3......")
+	ksvcCleanCommand.Flags().StringVarP(&cleanArgs.SvcPrefix, "svc-prefix", "", "testksvc", "ksvc name prefix. The ksvcs will be svcPrefix1,svcPrefix2,svcPrefix3...... It also restricts the ksvcs selected by --selector and --svc-regex unless set to an empty string.")
+	ksvcCleanCommand.Flags().StringVarP(&cleanArgs.RunID, "run-id", "", "", "ID of the kperf run. Only the ksvcs created by the run are cleaned, whatever svc-prefix is.")
 	ksvcCleanCommand.Flags().IntVarP(&cleanArgs.Concurrency, "concurrency", "c", 10, "Number of multiple ksvcs to make at a time")
+	ksvcCleanCommand.Flags().BoolVarP(&cleanArgs.DeleteNamespaces, "delete-namespaces", "", false, "Whether to delete the namespaces created by kperf and wait for them to be terminated")
+	ksvcCleanCommand.Flags().DurationVarP(&cleanArgs.Timeout, "timeout", "", 10*time.Minute, "Duration to wait for the objects of the ksvcs to be deleted with --wait, and for the namespaces to be terminated")
+	ksvcCleanCommand.Flags().BoolVarP(&cleanArgs.Wait, "wait", "", false, "Whether to wait for the ksvcs and their Revisions, Deployments, Pods and Ingresses to be deleted and measure their teardown")
+	ksvcCleanCommand.Flags().StringVarP(&cleanArgs.Output, "output", "o", ".", "Teardown measurement result location")
+	addTargetFlags(ksvcCleanCommand, &cleanArgs.Selector, &cleanArgs.SvcRegex, &cleanArgs.NamespaceSelector, &cleanArgs.NamespaceRegex)
 
 	return ksvcCleanCommand
 }
 
 // CleanServices used to clean Knative Service workload
 func CleanServices(params *pkg.PerfParams, inputs pkg.CleanArgs) error {
-	nsNameList, err := GetNamespaces(context.Background(), params, inputs.Namespace, inputs.NamespaceRange, inputs.NamespacePrefix)
+	nsNameList, err := target.Namespaces{Name: inputs.Namespace, Prefix: inputs.NamespacePrefix, Range: inputs.NamespaceRange,
+		Selector: inputs.NamespaceSelector, Regex: inputs.NamespaceRegex}.Resolve(context.Background(), params.ClientSet)
 	if err != nil {
 		return err
 	}
@@ -65,22 +90,49 @@ func CleanServices(params *pkg.PerfParams, inputs pkg.CleanArgs) error {
 		return err
 	}
 
-	matchedNsNameList := [][2]string{}
+	// the services of a run are selected by its label, regardless of their names
+	services := target.Services{Prefix: inputs.SvcPrefix, Selector: inputs.Selector, Regex: inputs.SvcRegex, RunID: inputs.RunID}
+	runSelector := ""
+	if inputs.RunID != "" {
+		services.Prefix = ""
+		runSelector = pkg.RunSelector(inputs.RunID)
+	}
+	resolved, err := services.Resolve(context.TODO(), ksvcClient, nsNameList)
+	if err != nil {
+		return err
+	}
+	matchedNsNameList := make([][2]string, 0, len(resolved))
+	for _, svc := range resolved {
+		matchedNsNameList = append(matchedNsNameList, [2]string{svc.Namespace, svc.Name})
+	}
+	// deleted is the time each Knative Service is deleted at by namespace/name key, to measure its teardown
+	var deletedLock sync.Mutex
+	deleted := map[string]time.Time{}
 	cleanKsvc := func(namespace, name string) {
 		fmt.Printf("Delete ksvc %s in namespace %s\n", name, namespace)
+		start := time.Now()
 		err := ksvcClient.Services(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
 		if err != nil {
 			fmt.Printf("Failed to delete ksvc %s in namespace %s\n", name, namespace)
+			return
 		}
+		deletedLock.Lock()
+		deleted[namespace+"/"+name] = start
+		deletedLock.Unlock()
 	}
-	for i := 0; i < len(nsNameList); i++ {
-		svcList, err := ksvcClient.Services(nsNameList[i]).List(context.TODO(), metav1.ListOptions{})
-		if err == nil {
-			for j := 0; j < len(svcList.Items); j++ {
-				if strings.HasPrefix(svcList.Items[j].Name, inputs.SvcPrefix) {
-					matchedNsNameList = append(matchedNsNameList, [2]string{nsNameList[i], svcList.Items[j].Name})
-				}
-			}
+	// the deletion of the objects is recorded from before the first Knative Service is deleted
+	var recorder *timelineRecorder
+	var sources []timelineSource
+	if inputs.Wait && len(matchedNsNameList) > 0 {
+		sources, err = timelineSources(params)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		recorder, err = newTimelineRecorder(ctx, sources, nsNameList, runSelector)
+		if err != nil {
+			return err
 		}
 	}
 	if len(matchedNsNameList) > 0 {
@@ -88,5 +140,92 @@ func CleanServices(params *pkg.PerfParams, inputs pkg.CleanArgs) error {
 	} else {
 		fmt.Println("No service found for cleaning")
 	}
+	if recorder != nil && len(deleted) > 0 {
+		err = measureTeardown(params, inputs, sources, recorder, deleted)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the profile objects are owned by the runs of the Knative Services cleaned, never by the other runs
+	runIDs := map[string]bool{}
+	for _, svc := range resolved {
+		if runID := svc.Labels[pkg.RunIDLabelKey]; runID != "" {
+			runIDs[runID] = true
+		}
+	}
+	if inputs.RunID != "" {
+		runIDs[inputs.RunID] = true
+	}
+	err = cleanProfileObjects(context.TODO(), params, ksvcClient, nsNameList, runIDs)
+	if err != nil {
+		return err
+	}
+
+	if inputs.DeleteNamespaces {
+		return deleteNamespaces(context.TODO(), params, nsNameList, inputs.RunID, inputs.Timeout)
+	}
+	return nil
+}
+
+// cleanProfileObjects deletes the ConfigMaps and Secrets created by kperf for the workload profiles of the runs in the
+// namespaces. The objects of a run are kept in a namespace as long as Knative Services of the run are left in it, as
+// when only some of them are cleaned
+func cleanProfileObjects(ctx context.Context, params *pkg.PerfParams, ksvcClient servingv1client.ServingV1Interface, nsNameList []string, runIDs map[string]bool) error {
+	runIDList := make([]string, 0, len(runIDs))
+	for runID := range runIDs {
+		runIDList = append(runIDList, runID)
+	}
+	sort.Strings(runIDList)
+	for _, ns := range nsNameList {
+		for _, runID := range runIDList {
+			svcList, err := ksvcClient.Services(ns).List(ctx, metav1.ListOptions{LabelSelector: pkg.RunSelector(runID)})
+			if err != nil {
+				return fmt.Errorf("failed to list ksvc of run %s in namespace %s: %w", runID, ns, err)
+			}
+			left := 0
+			for _, svc := range svcList.Items {
+				if svc.DeletionTimestamp == nil {
+					left++
+				}
+			}
+			if left > 0 {
+				fmt.Printf("Keep the profile objects of run %s in namespace %s, used by %d ksvc\n", runID, ns, left)
+				continue
+			}
+			err = deleteRunProfileObjects(ctx, params, ns, runID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteRunProfileObjects deletes the ConfigMaps and Secrets of the run in the namespace
+func deleteRunProfileObjects(ctx context.Context, params *pkg.PerfParams, ns, runID string) error {
+	listOptions := metav1.ListOptions{LabelSelector: labels.SelectorFromSet(pkg.RunLabels(runID)).String()}
+	configMaps, err := params.ClientSet.CoreV1().ConfigMaps(ns).List(ctx, listOptions)
+	if err != nil {
+		return fmt.Errorf("failed to list ConfigMaps in namespace %s: %w", ns, err)
+	}
+	for _, cm := range configMaps.Items {
+		fmt.Printf("Delete ConfigMap %s in namespace %s\n", cm.Name, ns)
+		err = params.ClientSet.CoreV1().ConfigMaps(ns).Delete(ctx, cm.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete ConfigMap %s in namespace %s: %w", cm.Name, ns, err)
+		}
+	}
+	secrets, err := params.ClientSet.CoreV1().Secrets(ns).List(ctx, listOptions)
+	if err != nil {
+		return fmt.Errorf("failed to list Secrets in namespace %s: %w", ns, err)
+	}
+	for _, secret := range secrets.Items {
+		fmt.Printf("Delete Secret %s in namespace %s\n", secret.Name, ns)
+		err = params.ClientSet.CoreV1().Secrets(ns).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete Secret %s in namespace %s: %w", secret.Name, ns, err)
+		}
+	}
 	return nil
 }
@@ -15,14 +15,17 @@
 package service
 
 import (
+	"context"
 	"testing"
 
 	"gotest.tools/v3/assert"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	"knative.dev/kperf/pkg"
 	"knative.dev/kperf/pkg/testutil"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
 	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
 	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
 )
@@ -95,8 +98,8 @@ func TestNewServiceCleanCommand(t *testing.T) {
 		_, err = testutil.ExecuteCommand(cmd, "--namespace-prefix", "test-kperf", "--namespace-range", "1,y")
 		assert.ErrorContains(t, err, "strconv.Atoi: parsing \"y\": invalid syntax")
 
-		_, err = testutil.ExecuteCommand(cmd, "--namespace-prefix", "test-kperf", "--namespace-range", "1")
-		assert.ErrorContains(t, err, "expected range like 1,500, given 1")
+		_, err = testutil.ExecuteCommand(cmd, "--namespace-prefix", "test-kperf", "--namespace-range", "1-")
+		assert.ErrorContains(t, err, "expected range like 1,500, given 1-")
 
 		_, err = testutil.ExecuteCommand(cmd, "--namespace-prefix", "test-kperf-1", "--namespace-range", "1,2")
 		assert.ErrorContains(t, err, "no namespace found with prefix test-kperf-1")
@@ -174,4 +177,122 @@ func TestNewServiceCleanCommand(t *testing.T) {
 		_, err := testutil.ExecuteCommand(cmd, "--namespace", "test-kperf-prefix-1", "--svc-prefix", "test-ksvc")
 		assert.NilError(t, err)
 	})
+	t.Run("clean only the namespaces named after the prefix", func(t *testing.T) {
+		client := k8sfake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperfx"}},
+		)
+		fakeServing := &servingv1fake.FakeServingV1{Fake: testutil.NewKnativeFake(
+			&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "testksvc-1", Namespace: "test-kperf-1"}},
+			&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "testksvc-1", Namespace: "test-kperfx"}},
+		)}
+		p := &pkg.PerfParams{
+			ClientSet: client,
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return fakeServing, nil
+			},
+		}
+
+		cmd := NewServiceCleanCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "--namespace-prefix", "test-kperf")
+		assert.NilError(t, err)
+
+		_, err = fakeServing.Services("test-kperf-1").Get(context.TODO(), "testksvc-1", metav1.GetOptions{})
+		assert.Assert(t, apierrors.IsNotFound(err))
+		_, err = fakeServing.Services("test-kperfx").Get(context.TODO(), "testksvc-1", metav1.GetOptions{})
+		assert.NilError(t, err)
+	})
+	t.Run("clean with a regex keeps the default prefix", func(t *testing.T) {
+		client := k8sfake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1"}})
+		fakeServing := &servingv1fake.FakeServingV1{Fake: testutil.NewKnativeFake(
+			&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "testksvc-1", Namespace: "test-kperf-1"}},
+			&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app-1", Namespace: "test-kperf-1"}},
+		)}
+		p := &pkg.PerfParams{
+			ClientSet: client,
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return fakeServing, nil
+			},
+		}
+
+		cmd := NewServiceCleanCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "--namespace", "test-kperf-1", "--svc-regex", ".")
+		assert.NilError(t, err)
+		_, err = fakeServing.Services("test-kperf-1").Get(context.TODO(), "testksvc-1", metav1.GetOptions{})
+		assert.Assert(t, apierrors.IsNotFound(err))
+		_, err = fakeServing.Services("test-kperf-1").Get(context.TODO(), "app-1", metav1.GetOptions{})
+		assert.NilError(t, err)
+
+		// the prefix is only dropped once cleared explicitly
+		cmd = NewServiceCleanCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "--namespace", "test-kperf-1", "--svc-regex", "^app-", "--svc-prefix", "")
+		assert.NilError(t, err)
+		_, err = fakeServing.Services("test-kperf-1").Get(context.TODO(), "app-1", metav1.GetOptions{})
+		assert.Assert(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("clean services and delete namespaces created by kperf", func(t *testing.T) {
+		created := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "test-kperf-1",
+				Labels: pkg.RunLabels("test-run"),
+			},
+		}
+		existing := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-kperf-2",
+			},
+		}
+		client := k8sfake.NewSimpleClientset(created, existing)
+		fakeServing := &servingv1fake.FakeServingV1{Fake: &client.Fake}
+		servingClient := func() (servingv1client.ServingV1Interface, error) {
+			return fakeServing, nil
+		}
+
+		p := &pkg.PerfParams{
+			ClientSet:        client,
+			NewServingClient: servingClient,
+		}
+
+		cmd := NewServiceCleanCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "--namespace-prefix", "test-kperf", "--namespace-range", "1,2", "--delete-namespaces", "--timeout", "1s")
+		assert.NilError(t, err)
+
+		_, err = client.CoreV1().Namespaces().Get(context.TODO(), "test-kperf-1", metav1.GetOptions{})
+		assert.Assert(t, apierrors.IsNotFound(err))
+		_, err = client.CoreV1().Namespaces().Get(context.TODO(), "test-kperf-2", metav1.GetOptions{})
+		assert.NilError(t, err)
+	})
+	t.Run("clean services and namespaces of a run", func(t *testing.T) {
+		runNs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1", Labels: pkg.RunLabels("test-run")}}
+		otherNs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-2", Labels: pkg.RunLabels("other-run")}}
+		client := k8sfake.NewSimpleClientset(runNs, otherNs)
+		fakeServing := &servingv1fake.FakeServingV1{Fake: testutil.NewKnativeFake(
+			&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1", Namespace: "test-kperf-1", Labels: pkg.RunLabels("test-run")}},
+			&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-2", Namespace: "test-kperf-1"}},
+			&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1", Namespace: "test-kperf-2", Labels: pkg.RunLabels("other-run")}},
+		)}
+		p := &pkg.PerfParams{
+			ClientSet: client,
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return fakeServing, nil
+			},
+		}
+
+		cmd := NewServiceCleanCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "--namespace-prefix", "test-kperf", "--namespace-range", "1,2", "--run-id", "test-run", "--delete-namespaces", "--timeout", "1s")
+		assert.NilError(t, err)
+
+		_, err = fakeServing.Services("test-kperf-1").Get(context.TODO(), "ksvc-1", metav1.GetOptions{})
+		assert.Assert(t, apierrors.IsNotFound(err))
+		_, err = fakeServing.Services("test-kperf-1").Get(context.TODO(), "ksvc-2", metav1.GetOptions{})
+		assert.NilError(t, err)
+		_, err = fakeServing.Services("test-kperf-2").Get(context.TODO(), "ksvc-1", metav1.GetOptions{})
+		assert.NilError(t, err)
+
+		_, err = client.CoreV1().Namespaces().Get(context.TODO(), "test-kperf-1", metav1.GetOptions{})
+		assert.Assert(t, apierrors.IsNotFound(err))
+		_, err = client.CoreV1().Namespaces().Get(context.TODO(), "test-kperf-2", metav1.GetOptions{})
+		assert.NilError(t, err)
+	})
 }
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -27,152 +28,68 @@ import (
 	"time"
 
 	"github.com/montanaflynn/stats"
+	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 
 	"knative.dev/kperf/pkg"
 	"knative.dev/kperf/pkg/command/utils"
+	"knative.dev/kperf/pkg/mutation"
+	"knative.dev/kperf/pkg/target"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
 	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
 )
 
+// NewRunID returns an ID identifying a kperf run, usable as label value
+func NewRunID() string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	suffix := make([]byte, 5)
+	for i := range suffix {
+		suffix[i] = letters[rand.Intn(len(letters))]
+	}
+	return fmt.Sprintf("%s-%s", time.Now().Format(DateFormatString), suffix)
+}
+
 type ServicesToScale struct {
 	Namespace string
 	Service   *servingv1.Service
 }
 
+// GetNamespaces returns the namespace if it exists, or the namespaces with the prefix and the index in the range
 func GetNamespaces(ctx context.Context, params *pkg.PerfParams, namespace, namespaceRange, namespacePrefix string) ([]string, error) {
-	nsNameList := []string{}
-	var namespaceRangeMap map[string]bool = map[string]bool{}
-	if namespacePrefix != "" {
-		r := strings.Split(namespaceRange, ",")
-		if len(r) != 2 {
-			return nsNameList, fmt.Errorf("expected range like 1,500, given %s", namespaceRange)
-		}
-		start, err := strconv.Atoi(r[0])
-		if err != nil {
-			return nsNameList, err
-		}
-		end, err := strconv.Atoi(r[1])
-		if err != nil {
-			return nsNameList, err
-		}
-		if start >= 0 && end >= 0 && start <= end {
-			for i := start; i <= end; i++ {
-				namespaceRangeMap[fmt.Sprintf("%s-%d", namespacePrefix, i)] = true
-			}
-		} else {
-			return nsNameList, fmt.Errorf("failed to parse namespace range %s", namespaceRange)
-		}
-	}
+	return target.Namespaces{Name: namespace, Prefix: namespacePrefix, Range: namespaceRange}.Resolve(ctx, params.ClientSet)
+}
 
-	if namespace != "" {
-		nss, err := params.ClientSet.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
-		if err != nil {
-			return nsNameList, err
-		}
-		nsNameList = append(nsNameList, nss.Name)
-	} else if namespacePrefix != "" {
-		nsList, err := params.ClientSet.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
-		if err != nil {
-			return nsNameList, err
-		}
-		if len(nsList.Items) == 0 {
-			return nsNameList, fmt.Errorf("no namespace found with prefix %s", namespacePrefix)
-		}
-		if len(namespaceRangeMap) >= 0 {
-			for i := 0; i < len(nsList.Items); i++ {
-				if _, exists := namespaceRangeMap[nsList.Items[i].Name]; exists {
-					nsNameList = append(nsNameList, nsList.Items[i].Name)
-				}
-			}
-		} else {
-			for i := 0; i < len(nsList.Items); i++ {
-				if strings.HasPrefix(nsList.Items[i].Name, namespacePrefix) {
-					nsNameList = append(nsNameList, nsList.Items[i].Name)
-				}
-			}
-		}
+// servicesListFunc returns the Knative Services selected in the namespaces
+type servicesListFunc func(context.Context, servingv1client.ServingV1Interface, []string, target.Services) ([]ServicesToScale, error)
 
-		if len(nsNameList) == 0 {
-			return nsNameList, fmt.Errorf("no namespace found with prefix %s", namespacePrefix)
-		}
-	} else {
-		return nsNameList, errors.New("both namespace and namespace-prefix are empty")
+// getServices gets the existing Knative Services selected in the namespaces, at least one criteria being required
+func getServices(ctx context.Context, servingClient servingv1client.ServingV1Interface, nsNameList []string, services target.Services) ([]ServicesToScale, error) {
+	if services.All() {
+		return nil, errors.New("both svc and svc-prefix are empty, and none of selector, svc-regex and run-id is set")
 	}
-	return nsNameList, nil
-}
-
-// getServices gets existed services by svc or (svc-prefix, svcRange)
-func getServices(ctx context.Context, servingClient servingv1client.ServingV1Interface, nsNameList []string, svcPrefix string, svcRange string, service string) ([]ServicesToScale, error) {
-	objs := []ServicesToScale{}
-	// generate a svcRange map by svcPrefix and svcRange
-	var svcRangeMap map[string]bool = map[string]bool{}
-	if svcPrefix != "" && svcRange != "" {
-		r := strings.Split(svcRange, ",")
-		if len(r) != 2 {
-			return objs, fmt.Errorf("expected svc range like 1,500, given %s", svcRange)
-		}
-		start, err := strconv.Atoi(r[0])
-		if err != nil {
-			return objs, err
-		}
-		end, err := strconv.Atoi(r[1])
-		if err != nil {
-			return objs, err
-		}
-		if start >= 0 && end >= 0 && start <= end {
-			for i := start; i <= end; i++ {
-				svcRangeMap[fmt.Sprintf("%s-%d", svcPrefix, i)] = true
-			}
-		} else {
-			return objs, fmt.Errorf("failed to parse svc range %s", svcRange)
-		}
+	resolved, err := services.Resolve(ctx, servingClient, nsNameList)
+	if err != nil {
+		return nil, err
 	}
-	if service != "" { // get existed service by given svc name
-		for _, ns := range nsNameList {
-			svc, err := servingClient.Services(ns).Get(ctx, service, metav1.GetOptions{})
-			if err != nil {
-				return objs, err
-			}
-			if service == svc.Name {
-				objs = append(objs, ServicesToScale{Namespace: ns, Service: svc})
-			}
-		}
-		if len(objs) == 0 {
-			return objs, fmt.Errorf("svc with name %s not found", service)
-		}
-	} else if svcPrefix != "" { // get existed services by svcRangeMap and svcPrefix in nsNameList
-		for _, ns := range nsNameList {
-			svcList, err := servingClient.Services(ns).List(ctx, metav1.ListOptions{})
-			if err == nil {
-				if len(svcRangeMap) >= 0 { // get existed services in svcRangeMap
-					for _, s := range svcList.Items {
-						svc := s
-						if _, exists := svcRangeMap[svc.Name]; exists {
-							objs = append(objs, ServicesToScale{Namespace: ns, Service: &svc})
-						}
-					}
-				} else { // get existed services by svcPrefix if svcRangeMap is empty
-					for _, s := range svcList.Items {
-						svc := s
-						if strings.HasPrefix(s.Name, svcPrefix) {
-							objs = append(objs, ServicesToScale{Namespace: ns, Service: &svc})
-						}
-					}
-				}
-			}
-		}
-		if len(objs) == 0 {
-			return objs, fmt.Errorf("no ksvc found with prefix %s", svcPrefix)
-		}
-	} else {
-		return objs, errors.New("both svc and svc-prefix are empty")
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("no ksvc found %s", services)
+	}
+	objs := make([]ServicesToScale, 0, len(resolved))
+	for _, svc := range resolved {
+		objs = append(objs, ServicesToScale{Namespace: svc.Namespace, Service: svc})
 	}
 	return objs, nil
 }
 
+// addTargetFlags adds the flags selecting the Knative Services and the namespaces by label selector and name regex
+func addTargetFlags(cmd *cobra.Command, selector, svcRegex, namespaceSelector, namespaceRegex *string) {
+	cmd.Flags().StringVarP(selector, "selector", "l", "", "Label selector of the services, like app=foo,tier!=backend")
+	cmd.Flags().StringVarP(svcRegex, "svc-regex", "", "", "Regular expression the service names must match")
+	cmd.Flags().StringVarP(namespaceSelector, "namespace-selector", "", "", "Label selector of the namespaces, like team=perf")
+	cmd.Flags().StringVarP(namespaceRegex, "namespace-regex", "", "", "Regular expression the namespace names must match")
+}
+
 // Get Knative Serving and Eventing version
 // Returns a map like {"eventing":"0.20.0", "serving":"0.20.0"}
 func GetKnativeVersion(p *pkg.PerfParams) map[string]string {
@@ -281,6 +198,11 @@ func GenerateOutputPathPrefix(inputsOutput string, outputFilenameFlag string) (p
 }
 
 // GenerateOutput generates outputs according to flags(csvFlag, htmlFlag and josnFlag) from rows and result
+// formatSeconds returns the seconds with a millisecond resolution, every duration of the outputs is formatted with it
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
 func GenerateOutput(inputsOutput string, outputFilenameFlag string, csvFlag bool, htmlFlag bool, jsonFlag bool, rows [][]string, result interface{}) error {
 	outputPathPrefix, err := GenerateOutputPathPrefix(inputsOutput, outputFilenameFlag)
 	if err != nil {
@@ -477,64 +399,48 @@ func getNodePortFromService(svc *corev1.Service, protocol string) (string, error
 	return "", fmt.Errorf("%s port of ingress service not found", protocol)
 }
 
-// updateAllowZeroInitialScale updates configmap/config-autoscaler, configs allow-zero-initial-scale to new value, returns origin value of allow-zero-initial-scale
-func updateAllowZeroInitialScale(ctx context.Context, params *pkg.PerfParams, namespace, new string) (string, error) {
-	origin := ""
-	cfgms, _ := params.ClientSet.CoreV1().ConfigMaps(namespace).Get(ctx, "config-autoscaler", metav1.GetOptions{})
-	if cfgms != nil && cfgms.Data != nil {
-		val, ok := cfgms.Data["allow-zero-initial-scale"]
-		if ok {
-			origin = val
-			if origin == new {
-				return origin, nil
-			}
-		}
-	}
-
-	var payloads []pkg.PatchStringValue
-	var payload pkg.PatchStringValue
-	if new != "" {
-		payload.Op = "replace"
-		payload.Path = "/data/allow-zero-initial-scale"
-		payload.Value = new
-	} else {
-		payload.Op = "remove"
-		payload.Path = "/data/allow-zero-initial-scale"
-	}
-	payloads = append(payloads, payload)
-
-	dataBytes, err := json.Marshal(payloads)
+// updateAllowZeroInitialScale sets allow-zero-initial-scale in configmap/config-autoscaler, recording its original
+// value in the journal to be restored
+func updateAllowZeroInitialScale(ctx context.Context, params *pkg.PerfParams, journal *mutation.Journal, namespace, new string) error {
+	cfgms, err := params.ClientSet.CoreV1().ConfigMaps(namespace).Get(ctx, "config-autoscaler", metav1.GetOptions{})
 	if err != nil {
-		return origin, err
+		fmt.Printf("failed to get configmap config-autoscaler: %s\n", err)
+		return nil
 	}
-
-	params.ClientSet.CoreV1().ConfigMaps(namespace).Patch(ctx, "config-autoscaler", types.JSONPatchType, dataBytes, metav1.PatchOptions{})
-	return origin, nil
+	var origin *string
+	if val, ok := cfgms.Data["allow-zero-initial-scale"]; ok {
+		if val == new {
+			return nil
+		}
+		origin = &val
+	}
+	change := mutation.Change{Kind: mutation.KindConfigMap, Namespace: namespace, Name: "config-autoscaler", Path: []string{"data"},
+		Original: map[string]*string{"allow-zero-initial-scale": origin}}
+	return journal.Apply(ctx, mutation.NewPatcher(params), change, map[string]*string{"allow-zero-initial-scale": &new})
 }
 
-// updateKsvc configs stable window and initial scale
-func updateKsvc(ctx context.Context, ksvcClient servingv1client.ServingV1Interface, namespace, ksvcName string, window string, initialScale string) error {
-	payload := map[string]interface{}{
-		"spec": map[string]interface{}{
-			"template": map[string]interface{}{
-				"metadata": map[string]interface{}{
-					"annotations": map[string]string{
-						"autoscaling.knative.dev/window":        window,
-						"autoscaling.knative.dev/initial-scale": initialScale,
-					},
-				},
-			},
-		},
-	}
-	dataBytes, err := json.Marshal(payload)
-	if err != nil {
-		return err
+// updateKsvc configs stable window and initial scale, recording the original annotations of the template in the
+// journal to be restored
+func updateKsvc(ctx context.Context, params *pkg.PerfParams, journal *mutation.Journal, svc *servingv1.Service, window string, initialScale string) error {
+	annotations := map[string]string{
+		"autoscaling.knative.dev/window":        window,
+		"autoscaling.knative.dev/initial-scale": initialScale,
+	}
+	change := mutation.Change{Kind: mutation.KindService, Namespace: svc.Namespace, Name: svc.Name,
+		Path: []string{"spec", "template", "metadata", "annotations"}, Original: map[string]*string{}}
+	values := map[string]*string{}
+	for key, value := range annotations {
+		value := value
+		values[key] = &value
+		change.Original[key] = nil
+		if origin, ok := svc.Spec.Template.Annotations[key]; ok {
+			change.Original[key] = &origin
+		}
 	}
-	_, err = ksvcClient.Services(namespace).Patch(ctx, ksvcName, types.MergePatchType, dataBytes, metav1.PatchOptions{})
-	return err
+	return journal.Apply(ctx, mutation.NewPatcher(params), change, values)
 }
 
-// latencyResultHandler get total, avg, min, max and percentiles latency from latency list
+// latencyResultHandler get total, avg, min, max, percentiles and standard deviation latency from latency list
 func latencyResultHandler(input []float64) pkg.LatencyResult {
 	// Get total, avg, min, max from latency list
 	n := len(input)
@@ -559,6 +465,7 @@ func latencyResultHandler(input []float64) pkg.LatencyResult {
 	b, _ := stats.Percentile(input, 90)
 	c, _ := stats.Percentile(input, 95)
 	d, _ := stats.Percentile(input, 99)
+	stddev, _ := stats.StandardDeviation(input)
 
 	fmt.Printf("Average: %f s\n", avg)
 	fmt.Printf("Min: 	 %f s\n", min)
@@ -567,6 +474,7 @@ func latencyResultHandler(input []float64) pkg.LatencyResult {
 	fmt.Printf("P90:  	 %f s\n", b)
 	fmt.Printf("P95:   	 %f s\n", c)
 	fmt.Printf("P99:   	 %f s\n", d)
+	fmt.Printf("StdDev:	 %f s\n", stddev)
 
 	return pkg.LatencyResult{
 		Average: avg,
@@ -576,5 +484,6 @@ func latencyResultHandler(input []float64) pkg.LatencyResult {
 		P90:     b,
 		P95:     c,
 		P99:     d,
+		StdDev:  stddev,
 	}
 }
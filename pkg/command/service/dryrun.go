@@ -0,0 +1,114 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DryRunNone creates the objects
+	DryRunNone = "none"
+	// DryRunClient renders the objects without sending them to the cluster
+	DryRunClient = "client"
+	// DryRunServer submits the objects with DryRun All, the admission webhooks run but nothing is persisted
+	DryRunServer = "server"
+
+	// ManifestOutputStdout writes the rendered objects to stdout as a YAML stream
+	ManifestOutputStdout = "-"
+)
+
+// parseDryRun validates the dry-run mode, empty meaning none
+func parseDryRun(dryRun string) (string, error) {
+	switch dryRun {
+	case "", DryRunNone:
+		return DryRunNone, nil
+	case DryRunClient, DryRunServer:
+		return dryRun, nil
+	}
+	return "", fmt.Errorf("unknown dry-run mode %s, expected one of none, client or server", dryRun)
+}
+
+// writeManifests writes the objects as YAML to stdout if output is "-", to a YAML stream file if output ends with
+// .yaml or .yml, or to a file per object in the output directory otherwise
+func writeManifests(output string, objects []runtime.Object) error {
+	if output == ManifestOutputStdout {
+		return writeManifestStream(os.Stdout, objects)
+	}
+	if ext := filepath.Ext(output); ext == ".yaml" || ext == ".yml" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create manifest file: %w", err)
+		}
+		defer f.Close()
+		err = writeManifestStream(f, objects)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Manifests of %d objects saved in %s\n", len(objects), output)
+		return nil
+	}
+
+	err := os.MkdirAll(output, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+	for _, obj := range objects {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("failed to encode manifest: %w", err)
+		}
+		err = os.WriteFile(filepath.Join(output, manifestFilename(obj)), data, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Manifests of %d objects saved in %s\n", len(objects), output)
+	return nil
+}
+
+func writeManifestStream(w io.Writer, objects []runtime.Object) error {
+	for _, obj := range objects {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("failed to encode manifest: %w", err)
+		}
+		_, err = fmt.Fprintf(w, "---\n%s", data)
+		if err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+	}
+	return nil
+}
+
+// manifestFilename returns the file name of the object like kind-namespace-name.yaml
+func manifestFilename(obj runtime.Object) string {
+	parts := []string{strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind)}
+	if accessor, err := meta.Accessor(obj); err == nil {
+		if accessor.GetNamespace() != "" {
+			parts = append(parts, accessor.GetNamespace())
+		}
+		parts = append(parts, accessor.GetName())
+	}
+	return strings.Join(parts, "-") + ".yaml"
+}
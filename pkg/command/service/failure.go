@@ -0,0 +1,231 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1"
+	"knative.dev/pkg/apis"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1api "knative.dev/serving/pkg/apis/serving/v1"
+	autoscalingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/autoscaling/v1alpha1"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+
+	"knative.dev/kperf/pkg"
+)
+
+const (
+	FailureOutputFilename = "ksvc_creation_failures"
+
+	// The statuses of the services which are not measured, as counted in ServiceCount
+	FailureNotReady = "NotReady"
+	FailureNotFound = "NotFound"
+	FailureFail     = "Fail"
+)
+
+// The resources a failure is found in
+const (
+	failureService           = "Service"
+	failureConfiguration     = "Configuration"
+	failureRevision          = "Revision"
+	failureDeployment        = "Deployment"
+	failurePod               = "Pod"
+	failurePodAutoscaler     = "PodAutoscaler"
+	failureServerlessService = "ServerlessService"
+	failureIngress           = "Ingress"
+)
+
+// newServiceFailure returns the failure of the service caused by an error with the resource
+func newServiceFailure(svc, svcNs, status, resource, name string, err error) pkg.ServiceFailure {
+	reason := string(apierrors.ReasonForError(err))
+	if reason == "" {
+		reason = "Error"
+	}
+	return pkg.ServiceFailure{
+		ServiceName:      svc,
+		ServiceNamespace: svcNs,
+		Status:           status,
+		Resource:         resource,
+		ResourceName:     name,
+		Reason:           reason,
+		Message:          err.Error(),
+	}
+}
+
+// diagnoseService returns the failure of a service which is not ready, from the deepest of its resources with a
+// failing condition, the Pods being deeper than the Deployment, the Deployment than the Revision and so on
+func diagnoseService(ctx context.Context, params *pkg.PerfParams, servingClient servingv1client.ServingV1Interface,
+	autoscalingClient autoscalingv1client.AutoscalingV1alpha1Interface, nwclient networkingv1alpha1.NetworkingV1alpha1Interface,
+	svc *servingv1api.Service) pkg.ServiceFailure {
+	failure := pkg.ServiceFailure{
+		ServiceName:      svc.Name,
+		ServiceNamespace: svc.Namespace,
+		Status:           FailureNotReady,
+		Resource:         failureService,
+		ResourceName:     svc.Name,
+	}
+	if ready := svc.Status.GetCondition(apis.ConditionReady); ready != nil {
+		failure.ServiceReason = ready.Reason
+		failure.Reason, failure.Message = ready.Reason, ready.Message
+	}
+	if failure.Reason == "" && svc.Status.ObservedGeneration != svc.Generation {
+		failure.Reason = "NotReconciled"
+	}
+	// found sets the failure of a deeper resource
+	found := func(resource, name, reason, message string) {
+		failure.Resource, failure.ResourceName, failure.Reason, failure.Message = resource, name, reason, message
+	}
+	notReady := func(resource, name string, condition *apis.Condition) {
+		if condition != nil && condition.Status != corev1.ConditionTrue {
+			found(resource, name, condition.Reason, condition.Message)
+		}
+	}
+
+	cfg, err := servingClient.Configurations(svc.Namespace).Get(ctx, svc.Name, metav1.GetOptions{})
+	if err != nil {
+		found(failureConfiguration, svc.Name, string(apierrors.ReasonForError(err)), err.Error())
+		return failure
+	}
+	notReady(failureConfiguration, cfg.Name, cfg.Status.GetCondition(apis.ConditionReady))
+
+	// the routing of the service fails independently of its latest revision
+	if ingress, err := nwclient.Ingresses(svc.Namespace).Get(ctx, svc.Name, metav1.GetOptions{}); err == nil {
+		notReady(failureIngress, ingress.Name, ingress.Status.GetCondition(apis.ConditionReady))
+	}
+
+	revisionName := cfg.Status.LatestCreatedRevisionName
+	if revisionName == "" {
+		return failure
+	}
+	revision, err := servingClient.Revisions(svc.Namespace).Get(ctx, revisionName, metav1.GetOptions{})
+	if err != nil {
+		found(failureRevision, revisionName, string(apierrors.ReasonForError(err)), err.Error())
+		return failure
+	}
+	notReady(failureRevision, revisionName, revision.Status.GetCondition(apis.ConditionReady))
+
+	if sks, err := nwclient.ServerlessServices(svc.Namespace).Get(ctx, revisionName, metav1.GetOptions{}); err == nil {
+		notReady(failureServerlessService, sks.Name, sks.Status.GetCondition(apis.ConditionReady))
+	}
+	if kpa, err := autoscalingClient.PodAutoscalers(svc.Namespace).Get(ctx, revisionName, metav1.GetOptions{}); err == nil {
+		notReady(failurePodAutoscaler, kpa.Name, kpa.Status.GetCondition(apis.ConditionReady))
+	}
+
+	deployment, err := params.ClientSet.AppsV1().Deployments(svc.Namespace).Get(ctx, revisionName+"-deployment", metav1.GetOptions{})
+	if err == nil {
+		for _, condition := range deployment.Status.Conditions {
+			if (condition.Type == appsv1.DeploymentProgressing && condition.Status == corev1.ConditionFalse) ||
+				(condition.Type == appsv1.DeploymentReplicaFailure && condition.Status == corev1.ConditionTrue) {
+				found(failureDeployment, deployment.Name, condition.Reason, condition.Message)
+			}
+		}
+	}
+
+	podList, err := params.ClientSet.CoreV1().Pods(svc.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", serving.RevisionLabelKey, revisionName),
+	})
+	if err == nil {
+		for i := range podList.Items {
+			if reason, message, failing := podFailure(&podList.Items[i]); failing {
+				found(failurePod, podList.Items[i].Name, reason, message)
+				break
+			}
+		}
+	}
+	return failure
+}
+
+// podFailure returns the reason a pod is failing, like being unschedulable or a container waiting in
+// ImagePullBackOff or CrashLoopBackOff
+func podFailure(pod *corev1.Pod) (string, string, bool) {
+	statuses := make([]corev1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	statuses = append(append(statuses, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if waiting := status.State.Waiting; waiting != nil && waiting.Reason != "" && waiting.Reason != "ContainerCreating" &&
+			waiting.Reason != "PodInitializing" {
+			return waiting.Reason, fmt.Sprintf("container %s: %s", status.Name, waiting.Message), true
+		}
+		if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			return terminated.Reason, fmt.Sprintf("container %s: exit code %d %s", status.Name, terminated.ExitCode, terminated.Message), true
+		}
+	}
+	if present, scheduled := getPodCondition(&pod.Status, corev1.PodScheduled); present != -1 && scheduled.Status == corev1.ConditionFalse {
+		return scheduled.Reason, scheduled.Message, true
+	}
+	return "", "", false
+}
+
+// failureGroups groups the failures by status, resource and reason, the largest groups first
+func failureGroups(failures []pkg.ServiceFailure) []pkg.FailureGroup {
+	counts := map[pkg.FailureGroup]int{}
+	for _, failure := range failures {
+		counts[pkg.FailureGroup{Status: failure.Status, Resource: failure.Resource, Reason: failureReason(failure)}]++
+	}
+	groups := make([]pkg.FailureGroup, 0, len(counts))
+	for group, count := range counts {
+		group.Count = count
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Status+groups[i].Resource+groups[i].Reason < groups[j].Status+groups[j].Resource+groups[j].Reason
+	})
+	return groups
+}
+
+// failureReason returns the reason of the service followed by the reason of the failing resource, like
+// RevisionMissing/ProgressDeadlineExceeded
+func failureReason(failure pkg.ServiceFailure) string {
+	reasons := []string{}
+	if failure.ServiceReason != "" && failure.Resource != failureService {
+		reasons = append(reasons, failure.ServiceReason)
+	}
+	if failure.Reason != "" {
+		reasons = append(reasons, failure.Reason)
+	}
+	if len(reasons) == 0 {
+		return "Unknown"
+	}
+	return strings.Join(reasons, "/")
+}
+
+// printFailureGroups prints the table of the failure groups
+func printFailureGroups(groups []pkg.FailureGroup) {
+	fmt.Printf("Failures:\n")
+	fmt.Printf("%6s  %-9s  %-17s  %s\n", "Count", "Status", "Resource", "Reason")
+	for _, group := range groups {
+		fmt.Printf("%5d×  %-9s  %-17s  %s\n", group.Count, group.Status, group.Resource, group.Reason)
+	}
+}
+
+// failureRows returns the rows of the failure CSV with a row per service
+func failureRows(failures []pkg.ServiceFailure) [][]string {
+	rows := [][]string{{"svc_name", "svc_namespace", "status", "resource", "resource_name", "service_reason", "reason", "message"}}
+	for _, failure := range failures {
+		rows = append(rows, []string{failure.ServiceName, failure.ServiceNamespace, failure.Status, failure.Resource,
+			failure.ResourceName, failure.ServiceReason, failure.Reason, failure.Message})
+	}
+	return rows
+}
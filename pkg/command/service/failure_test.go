@@ -0,0 +1,110 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	fakenetworkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1/fake"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	autoscalingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/autoscaling/v1alpha1/fake"
+	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
+
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/testutil"
+)
+
+func TestDiagnoseService(t *testing.T) {
+	notReady := func(reason string) apis.Condition {
+		return apis.Condition{Type: apis.ConditionReady, Status: corev1.ConditionFalse, Reason: reason}
+	}
+	svc := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1", Namespace: "ns-1"}}
+	svc.Status.Conditions = duckv1.Conditions{notReady("RevisionMissing")}
+	cfg := &servingv1.Configuration{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1", Namespace: "ns-1"}}
+	cfg.Status.Conditions = duckv1.Conditions{notReady("RevisionFailed")}
+	cfg.Status.LatestCreatedRevisionName = "ksvc-1-00001"
+	revision := &servingv1.Revision{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1-00001", Namespace: "ns-1"}}
+	revision.Status.Conditions = duckv1.Conditions{notReady("ProgressDeadlineExceeded")}
+	knativeFake := testutil.NewKnativeFake(svc, cfg, revision)
+	servingClient := &servingv1fake.FakeServingV1{Fake: knativeFake}
+	autoscalingClient := &autoscalingv1fake.FakeAutoscalingV1alpha1{Fake: knativeFake}
+	nwclient := &fakenetworkingv1alpha1.FakeNetworkingV1alpha1{Fake: knativeFake}
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1-00001-deployment", Namespace: "ns-1"}}
+	deployment.Status.Conditions = []appsv1.DeploymentCondition{
+		{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"},
+	}
+	client := k8sfake.NewSimpleClientset(deployment)
+	params := &pkg.PerfParams{ClientSet: client}
+
+	failure := diagnoseService(context.Background(), params, servingClient, autoscalingClient, nwclient, svc)
+	assert.DeepEqual(t, pkg.ServiceFailure{
+		ServiceName:      "ksvc-1",
+		ServiceNamespace: "ns-1",
+		Status:           FailureNotReady,
+		Resource:         failureDeployment,
+		ResourceName:     "ksvc-1-00001-deployment",
+		ServiceReason:    "RevisionMissing",
+		Reason:           "ProgressDeadlineExceeded",
+	}, failure)
+
+	// the pods are deeper than the deployment
+	_, err := client.CoreV1().Pods("ns-1").Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1-00001-deployment-abcde", Namespace: "ns-1",
+			Labels: map[string]string{serving.RevisionLabelKey: "ksvc-1-00001"}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  "user-container",
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"}},
+		}}},
+	}, metav1.CreateOptions{})
+	assert.NilError(t, err)
+	failure = diagnoseService(context.Background(), params, servingClient, autoscalingClient, nwclient, svc)
+	assert.Equal(t, failurePod, failure.Resource)
+	assert.Equal(t, "ImagePullBackOff", failure.Reason)
+	assert.Equal(t, "container user-container: Back-off pulling image", failure.Message)
+	assert.Equal(t, "RevisionMissing/ImagePullBackOff", failureReason(failure))
+
+	// the configuration is not found
+	svc.Name = "ksvc-2"
+	failure = diagnoseService(context.Background(), params, servingClient, autoscalingClient, nwclient, svc)
+	assert.Equal(t, failureConfiguration, failure.Resource)
+	assert.Equal(t, "NotFound", failure.Reason)
+}
+
+func TestFailureGroups(t *testing.T) {
+	failures := []pkg.ServiceFailure{
+		{ServiceName: "ksvc-1", Status: FailureNotReady, Resource: failureRevision, ServiceReason: "RevisionMissing", Reason: "ProgressDeadlineExceeded"},
+		newServiceFailure("ksvc-2", "ns-1", FailureFail, failureService, "ksvc-2", errors.New("connection refused")),
+		{ServiceName: "ksvc-3", Status: FailureNotReady, Resource: failureRevision, ServiceReason: "RevisionMissing", Reason: "ProgressDeadlineExceeded"},
+	}
+	assert.DeepEqual(t, []pkg.FailureGroup{
+		{Status: FailureNotReady, Resource: failureRevision, Reason: "RevisionMissing/ProgressDeadlineExceeded", Count: 2},
+		{Status: FailureFail, Resource: failureService, Reason: "Error", Count: 1},
+	}, failureGroups(failures))
+
+	rows := failureRows(failures)
+	assert.Equal(t, 4, len(rows))
+	assert.DeepEqual(t, []string{"ksvc-2", "ns-1", FailureFail, failureService, "ksvc-2", "", "Error", "connection refused"}, rows[2])
+}
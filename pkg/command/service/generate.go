@@ -16,20 +16,26 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"knative.dev/kperf/pkg/config"
 
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/util/yaml"
-
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
@@ -37,21 +43,31 @@ import (
 
 	"knative.dev/kperf/pkg"
 	"knative.dev/kperf/pkg/generator"
-	knativeapis "knative.dev/pkg/apis"
+	"knative.dev/kperf/pkg/profile"
+	"knative.dev/kperf/pkg/target"
+	"knative.dev/pkg/apis"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1beta1 "knative.dev/serving/pkg/apis/serving/v1beta1"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+	servingv1beta1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1beta1"
 )
 
 const (
-	DefaultNamespace = "default"
-	ServiceImage     = "gcr.io/knative-samples/helloworld-go"
+	DefaultNamespace       = "default"
+	ServiceImage           = profile.HelloworldImage
+	GenerateOutputFilename = "ksvc_generation"
 )
 
-// Required flags of generate command
-var generateRequiredFlagsSet = map[string]bool{
-	"batch":    true,
-	"interval": true,
-	"number":   true,
-}
+// Required flags of generate command, and of each arrival pattern. The flags of the patterns are only required with
+// the pattern, the batches of the fixed pattern not applying to the poisson or burst ones
+var (
+	generateRequiredFlags = []string{"number"}
+	arrivalRequiredFlags  = map[string][]string{
+		generator.ArrivalFixed: {"batch", "interval"},
+		generator.ArrivalRamp:  {"interval"},
+		generator.ArrivalStep:  {"interval", "steps"},
+	}
+)
 
 func NewServiceGenerateCommand(p *pkg.PerfParams) *cobra.Command {
 	generateArgs := pkg.GenerateArgs{}
@@ -63,13 +79,59 @@ func NewServiceGenerateCommand(p *pkg.PerfParams) *cobra.Command {
 For example:
 # To generate Knative Service workload
 kperf service generate -n 500 --interval 20 --batch 20 --min-scale 0 --max-scale 5 (--namespace-prefix testns/ --namespace nsname)
+
+# To generate Knative Service workload in namespaces testns-1 to testns-10, created if not found
+kperf service generate -n 500 --interval 20 --batch 20 --namespace-prefix testns --namespace-range 1,10 --create-namespaces
+
+# To generate Knative Service workload with 5 Knative Services per second on average
+kperf service generate -n 500 --arrival poisson --rate 5 --namespace nsname
+
+# To generate Knative Service workload from a template, rendered for each Knative Service with
+# .Index, .Namespace, .Name and .RunID, e.g. image: {{ choice "image-a" "image-b" }}
+kperf service generate -n 500 --interval 20 --batch 20 --namespace nsname --template ksvc.yaml
+
+# To generate Knative Service workload with a readiness probe delayed by 10 seconds
+kperf service generate -n 500 --interval 20 --batch 20 --namespace nsname --profile slow-start
+
+# To generate Knative Services with 3 revisions each, splitting the traffic 50/30/20 with a tag for the latest revision
+kperf service generate -n 500 --interval 20 --batch 20 --namespace nsname --revisions 3 --traffic 50,30,20 --tags ,,latest
+
+# To render the Knative Services in a directory, one file per object, without creating them
+kperf service generate -n 500 --interval 20 --batch 20 --namespace nsname --dry-run client --manifest-output manifests/
+
+# To submit the Knative Services to the admission webhooks without persisting them and measure the admission latency
+kperf service generate -n 500 --interval 20 --batch 20 --namespace nsname --dry-run server
+
+# To generate Knative Service workload and measure the admission latency of each Knative Service
+kperf service generate -n 500 --interval 20 --batch 20 --namespace nsname --measure-admission
+
+# To generate Knative Service workload and record the timeline of the creation of the Knative Services
+kperf service generate -n 500 --interval 20 --batch 20 --namespace nsname --timeline --output /tmp
+
+# To generate Knative Service workload and measure the first successful HTTPS response of each Knative Service and
+# of its DomainMapping once ready
+kperf service generate -n 500 --interval 20 --batch 20 --namespace nsname --profile domain-mapping --wait --https --insecure-skip-verify
 `,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			flags := cmd.Flags()
-			err := config.BindFlags(cmd, "service.generate.", generateRequiredFlagsSet)
+			err := config.BindFlags(cmd, "service.generate.", nil)
 			if err != nil {
 				return err
 			}
+			// the arrival pattern may be set in the config file, so the required flags are known once bound
+			arrival := generateArgs.Arrival
+			if arrival == "" {
+				arrival = generator.ArrivalFixed
+			}
+			missing := []string{}
+			for _, name := range append(generateRequiredFlags, arrivalRequiredFlags[arrival]...) {
+				if !flags.Changed(name) {
+					missing = append(missing, fmt.Sprintf("%q", name))
+				}
+			}
+			if len(missing) > 0 {
+				return fmt.Errorf("failed to get required flags, required flag(s) %s", strings.Join(missing, ", "))
+			}
 			if flags.Changed("namespace-prefix") && flags.Changed("namespace") {
 				return errors.New("expected either namespace with prefix & range or only namespace name")
 			}
@@ -82,21 +144,44 @@ kperf service generate -n 500 --interval 20 --batch 20 --min-scale 0 --max-scale
 
 	// Define cobra flags, the default value has the lowest (least significant) precedence
 	ksvcGenCommand.Flags().IntVarP(&generateArgs.Number, "number", "n", 0, "Total number of Knative Service to be created")
-	ksvcGenCommand.Flags().IntVarP(&generateArgs.Interval, "interval", "i", 0, "Interval for each batch generation")
-	ksvcGenCommand.Flags().IntVarP(&generateArgs.Batch, "batch", "b", 0, "Number of Knative Service each time to be created")
+	ksvcGenCommand.Flags().IntVarP(&generateArgs.Interval, "interval", "i", 0, "Interval for each batch generation, required by the fixed, ramp and step arrival patterns")
+	ksvcGenCommand.Flags().IntVarP(&generateArgs.Batch, "batch", "b", 0, "Number of Knative Service each time to be created, required by the fixed arrival pattern")
 	ksvcGenCommand.Flags().IntVarP(&generateArgs.Concurrency, "concurrency", "c", 10, "Number of multiple Knative Services to make at a time")
 	ksvcGenCommand.Flags().IntVarP(&generateArgs.MinScale, "min-scale", "", 0, "For autoscaling.knative.dev/minScale")
 	ksvcGenCommand.Flags().IntVarP(&generateArgs.MaxScale, "max-scale", "", 0, "For autoscaling.knative.dev/minScale")
 
 	ksvcGenCommand.Flags().StringVarP(&generateArgs.NamespacePrefix, "namespace-prefix", "", "", "Namespace prefix. The Knative Services will be created in the namespaces with the prefix")
-	ksvcGenCommand.Flags().StringVarP(&generateArgs.NamespaceRange, "namespace-range", "", "", "")
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.NamespaceRange, "namespace-range", "", "", "Namespace range like 1,500 or 1-5,8,20-30")
 	ksvcGenCommand.Flags().StringVarP(&generateArgs.Namespace, "namespace", "", "", "Namespace name. The Knative Services will be created in the namespace")
 
 	ksvcGenCommand.Flags().StringVarP(&generateArgs.SvcPrefix, "svc-prefix", "", "ksvc", "Knative Service name prefix. The Knative Services will be ksvc-1,ksvc-2,ksvc-3 and etc.")
-	ksvcGenCommand.Flags().BoolVarP(&generateArgs.CheckReady, "wait", "", false, "Whether to wait the previous Knative Service to be ready")
+	ksvcGenCommand.Flags().BoolVarP(&generateArgs.CheckReady, "wait", "", false, "Whether to wait the previous Knative Service to be ready, watching the Knative Services of the run and recording their time to ready")
 	ksvcGenCommand.Flags().DurationVarP(&generateArgs.Timeout, "timeout", "", 10*time.Minute, "Duration to wait for previous Knative Service to be ready")
 
-	ksvcGenCommand.Flags().StringVarP(&generateArgs.Template, "template", "", "", "YAML file to use for Knative Service")
+	ksvcGenCommand.Flags().BoolVarP(&generateArgs.ContinueOnError, "continue-on-error", "", false, "Whether to go on generating the Knative Services after a failure, the generation stops at the first failure by default")
+	ksvcGenCommand.Flags().BoolVarP(&generateArgs.CreateNamespaces, "create-namespaces", "", false, "Whether to create the namespaces not found, labeled with the run ID")
+
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.Template, "template", "", "", "YAML file to use for Knative Service. It is rendered as Go template for each Knative Service with .Index, .Namespace, .Name and .RunID and the functions choice, mod and add")
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.Profile, "profile", "", "", "Workload profile of the Knative Services, one of "+strings.Join(profile.Names(), ", ")+". The "+profile.Default+" profile is used if neither profile nor template is given")
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.Domain, "domain", "", "example.com", "Domain the Knative Services are mapped under by the DomainMappings of the domain-mapping profile, as <name>.<namespace>.<domain>")
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.RunID, "run-id", "", "", "ID of the run, generated if not set")
+
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.Arrival, "arrival", "", generator.ArrivalFixed, "Arrival pattern of the Knative Services: fixed (--batch every --interval), ramp (--batch increased by --ramp-increment every --interval), step (--steps plateaus every --interval), poisson (--rate per second on average) or burst (all at once after --interval)")
+	ksvcGenCommand.Flags().IntVarP(&generateArgs.RampIncrement, "ramp-increment", "", 1, "Number of Knative Services added to each batch for the ramp arrival pattern")
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.Steps, "steps", "", "", "Plateaus of the step arrival pattern like 5x3,10x3, i.e. 3 batches of 5 Knative Services then batches of 10")
+	ksvcGenCommand.Flags().Float64VarP(&generateArgs.Rate, "rate", "", 1, "Average number of Knative Services created per second for the poisson arrival pattern")
+	ksvcGenCommand.Flags().IntVarP(&generateArgs.Revisions, "revisions", "", 1, "Number of revisions of each Knative Service, named <service>-rev-1, <service>-rev-2 and etc.")
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.Traffic, "traffic", "", "", "Traffic percents of the revisions from the first to the last, like 50,30,20. The traffic is split evenly if not set and there are several revisions")
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.Tags, "tags", "", "", "Tags of the revisions from the first to the last, like stable,,canary")
+	ksvcGenCommand.Flags().BoolVarP(&generateArgs.MeasureAdmission, "measure-admission", "", false, "Whether to measure the create request of each Knative Service on the client side, split into request send, server processing including the admission webhooks, and response")
+	ksvcGenCommand.Flags().BoolVarP(&generateArgs.Timeline, "timeline", "", false, "Whether to record the condition transitions of the objects of the Knative Services with informers, and compute the phases of their creation from the time each transition is first seen")
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.DryRun, "dry-run", "", DryRunNone, "Dry-run mode: none, client (render the objects without sending them to the cluster) or server (submit the objects with DryRun All to measure the admission latency without persisting them)")
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.ManifestOutput, "manifest-output", "", ManifestOutputStdout, "Where to write the objects rendered with --dry-run client: - for stdout, a .yaml or .yml file for a YAML stream, or a directory for a file per object")
+	ksvcGenCommand.Flags().BoolVarP(&generateArgs.Https, "https", "", false, "Whether to request the HTTPS URLs of the Knative Services and of their DomainMappings once ready until the first successful response, measured from their create request. Requires --wait")
+	ksvcGenCommand.Flags().BoolVarP(&generateArgs.ResolvableDomain, "resolvable-domain", "", false, "Whether the domains of the HTTPS URLs are resolvable, the requests are sent to the ingress otherwise")
+	ksvcGenCommand.Flags().BoolVarP(&generateArgs.InsecureSkipVerify, "insecure-skip-verify", "", false, "Skip the verification of the certificates of the HTTPS URLs, like the ones of a local self-signed issuer")
+	ksvcGenCommand.Flags().DurationVarP(&generateArgs.HttpsTimeout, "https-timeout", "", time.Minute, "Duration to wait for the first successful response of each HTTPS URL")
+	ksvcGenCommand.Flags().StringVarP(&generateArgs.Output, "output", "o", "", "Generation result location, the result is not saved if empty")
 
 	return ksvcGenCommand
 }
@@ -107,107 +192,612 @@ func GenerateServices(params *pkg.PerfParams, inputs pkg.GenerateArgs) error {
 	if inputs.NamespacePrefix == "" && inputs.Namespace == "" {
 		nsNameList = []string{DefaultNamespace}
 	} else if inputs.NamespacePrefix != "" {
-		r := strings.Split(inputs.NamespaceRange, ",")
-		if len(r) != 2 {
-			return fmt.Errorf("expected range like 1,500, given %s", inputs.NamespaceRange)
+		indexes, err := target.ParseRange(inputs.NamespaceRange)
+		if err != nil {
+			return fmt.Errorf("failed to parse namespace range %s: %w", inputs.NamespaceRange, err)
+		}
+		for _, i := range indexes {
+			nsNameList = append(nsNameList, fmt.Sprintf("%s-%d", inputs.NamespacePrefix, i))
 		}
-		start, err := strconv.Atoi(r[0])
+	} else if inputs.Namespace != "" {
+		nsNameList = append(nsNameList, inputs.Namespace)
+	}
+
+	if inputs.RunID == "" {
+		inputs.RunID = NewRunID()
+	}
+
+	dryRun, err := parseDryRun(inputs.DryRun)
+	if err != nil {
+		return err
+	}
+	if dryRun != DryRunNone && inputs.CheckReady {
+		return errors.New("--wait is not supported with --dry-run")
+	}
+	if dryRun != DryRunNone && inputs.Timeline {
+		return errors.New("--timeline is not supported with --dry-run")
+	}
+	if inputs.Https && !inputs.CheckReady {
+		return errors.New("--https requires --wait, the HTTPS URLs being requested once the Knative Services are ready")
+	}
+	if dryRun == DryRunServer && inputs.CreateNamespaces {
+		return errors.New("--create-namespaces is not supported with --dry-run server, the namespaces must exist")
+	}
+	createOptions := metav1.CreateOptions{}
+	if dryRun == DryRunServer {
+		createOptions.DryRun = []string{metav1.DryRunAll}
+	}
+	manifestOutput := inputs.ManifestOutput
+	if manifestOutput == "" {
+		manifestOutput = ManifestOutputStdout
+	}
+	// the progress is logged to stderr when the manifests are written to stdout
+	var logOut io.Writer = os.Stdout
+	if dryRun == DryRunClient && manifestOutput == ManifestOutputStdout {
+		logOut = os.Stderr
+	}
+
+	if inputs.Template != "" && inputs.Profile != "" {
+		return errors.New("expected either template or profile")
+	}
+	profileName := inputs.Profile
+	if profileName == "" {
+		profileName = profile.Default
+	}
+	svcProfile, err := profile.Get(profileName)
+	if err != nil {
+		return err
+	}
+	arrival, err := newArrival(inputs)
+	if err != nil {
+		return err
+	}
+
+	var weights []trafficWeight
+	if inputs.Revisions > 1 || inputs.Traffic != "" || inputs.Tags != "" {
+		if inputs.Revisions < 1 {
+			return fmt.Errorf("revisions must be positive, given %d", inputs.Revisions)
+		}
+		traffic := inputs.Traffic
+		if traffic == "" {
+			traffic = evenTraffic(inputs.Revisions)
+		}
+		weights, err = parseTrafficSplit(traffic, inputs.Tags)
 		if err != nil {
 			return err
 		}
-		end, err := strconv.Atoi(r[1])
+		if len(weights) != inputs.Revisions {
+			return fmt.Errorf("expected %d traffic percents, given %s", inputs.Revisions, traffic)
+		}
+	}
+
+	var svcTemplate *template.Template
+	if inputs.Template != "" {
+		svcTemplate, err = parseServiceTemplate(inputs.Template)
+		if err != nil {
+			return err
+		}
+	}
+	generateArgs, err := generateArgsAnnotation(inputs, arrival)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(logOut, "Generating Knative Services with run ID %s\n", inputs.RunID)
+	runLabels := pkg.RunLabels(inputs.RunID)
+
+	// the admission is always measured on server dry-run since the Knative Services are only admitted
+	measureAdmission := dryRun == DryRunServer || (inputs.MeasureAdmission && dryRun == DryRunNone)
+	if measureAdmission {
+		// the transport is only instrumented for this generation, not for the later commands sharing params
+		wrapServingTransport := params.WrapServingTransport
+		params.WrapServingTransport = wrapAdmissionTransport
+		defer func() {
+			params.WrapServingTransport = wrapServingTransport
+		}()
+	}
+	// the cluster is not accessed at all to render the manifests
+	var ksvcClient servingv1client.ServingV1Interface
+	if dryRun != DryRunClient {
+		ksvcClient, err = params.NewServingClient()
+		if err != nil {
+			return err
+		}
+	}
+	// the Knative Services of the profile are mapped to their domain once created
+	mapDomain := inputs.Template == "" && svcProfile.DomainMapping
+	var dmClient servingv1beta1client.ServingV1beta1Interface
+	if mapDomain && dryRun != DryRunClient {
+		dmClient, err = params.NewDomainMappingClient()
 		if err != nil {
 			return err
 		}
-		if start > 0 && end > 0 && start <= end {
-			for i := start; i <= end; i++ {
-				nsNameList = append(nsNameList, fmt.Sprintf("%s-%d", inputs.NamespacePrefix, i))
+	}
+	// the HTTPS URLs are requested by the workers once the Knative Services are ready
+	var probe *httpsProbe
+	if inputs.Https {
+		probe = &httpsProbe{insecure: inputs.InsecureSkipVerify, timeout: inputs.HttpsTimeout}
+		if !inputs.ResolvableDomain {
+			probe.endpoint, err = getIngressEndpoint(context.TODO(), params, true)
+			if err != nil {
+				return fmt.Errorf("failed to get the ingress endpoint: %w", err)
 			}
-		} else {
-			return errors.New("failed to parse namespace range")
 		}
-	} else if inputs.Namespace != "" {
-		nsNameList = append(nsNameList, inputs.Namespace)
 	}
-
-	// Check if namespace exists, in NOT, return error
-	for _, ns := range nsNameList {
-		_, err := params.ClientSet.CoreV1().Namespaces().Get(context.TODO(), ns, metav1.GetOptions{})
-		if err != nil && apierrors.IsNotFound(err) {
-			return fmt.Errorf("namespace %s not found, please create one", ns)
-		} else if err != nil {
-			return fmt.Errorf("failed to get namespace: %w", err)
+	// the cluster is only changed once all the flags are valid and the clients are created,
+	// the namespaces and the objects of the profile are never left behind by an invalid generation
+	// Check if namespace exists, in NOT, create it with --create-namespaces or return error
+	if dryRun != DryRunClient {
+		err = ensureNamespaces(context.TODO(), params, nsNameList, inputs.CreateNamespaces, inputs.RunID)
+		if err != nil {
+			return err
 		}
 	}
-
-	ksvcClient, err := params.NewServingClient()
-	if err != nil {
+	if inputs.Template == "" && dryRun != DryRunClient {
+		err = ensureProfileObjects(context.TODO(), params, nsNameList, svcProfile, inputs.RunID, createOptions)
+		if err != nil {
+			return err
+		}
+	}
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithCancel(signalCtx)
+	defer cancel()
+	// stopOnError stops the generation at the first failure unless --continue-on-error is set
+	stopOnError := func(err error) error {
+		if err != nil && !inputs.ContinueOnError {
+			cancel()
+		}
 		return err
 	}
-	createKSVCFunc := func(ns string, index int) (string, string) {
+
+	// the readiness of the Knative Services of the run is watched rather than polled for each of them
+	var readinessWatcher *serviceReadinessWatcher
+	if inputs.CheckReady {
+		readinessWatcher, err = newServiceReadinessWatcher(signalCtx, ksvcClient, nsNameList, pkg.RunSelector(inputs.RunID))
+		if err != nil {
+			return err
+		}
+	}
+	// the timeline is recorded from before the first Knative Service is created
+	var recorder *timelineRecorder
+	if inputs.Timeline {
+		sources, err := timelineSources(params)
+		if err != nil {
+			return err
+		}
+		recorder, err = newTimelineRecorder(signalCtx, sources, nsNameList, pkg.RunSelector(inputs.RunID))
+		if err != nil {
+			return err
+		}
+	}
+	var createdAt, readyDurations, admissionTimings sync.Map
+	// the DomainMappings created by namespace/name key of their Knative Service, with the first HTTPS responses
+	var domainMappings, httpsDurations, dmHTTPSDurations sync.Map
+	var renderedLock sync.Mutex
+	rendered := map[int]*servingv1.Service{}
+	renderedMappings := map[int]*servingv1beta1.DomainMapping{}
+
+	createKSVCFunc := func(ns string, index int) (string, string, error) {
 		service := &servingv1.Service{}
-		if inputs.Template != "" {
-			template, err := os.Open(inputs.Template)
+		name := fmt.Sprintf("%s-%d", inputs.SvcPrefix, index)
+		if svcTemplate != nil {
+			fromTemplate, err := renderService(svcTemplate, ServiceTemplateData{Index: index, Namespace: ns, Name: name, RunID: inputs.RunID})
 			if err != nil {
-				fmt.Printf("Error: Failed to open template file: %v", err)
-				os.Exit(1)
-			}
-			decoder := yaml.NewYAMLOrJSONDecoder(template, 64)
-			if err := decoder.Decode(service); err != nil {
-				fmt.Printf("Error: Failed to decode YAML content: %v", err)
-				os.Exit(1)
+				fmt.Fprintf(logOut, "Error: Failed to create Knative Service %s from template: %v\n", name, err)
+				return ns, name, stopOnError(fmt.Errorf("failed to render template: %w", err))
 			}
+			service = fromTemplate
 		} else {
-			service.Spec.Template = servingv1.RevisionTemplateSpec{
-				Spec: servingv1.RevisionSpec{},
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: map[string]string{
-						"autoscaling.knative.dev/minScale": strconv.Itoa(inputs.MinScale),
-						"autoscaling.knative.dev/maxScale": strconv.Itoa(inputs.MaxScale),
-					},
-				},
-			}
-			service.Spec.Template.Spec.Containers = []corev1.Container{
-				{
-					Image: ServiceImage,
-					Ports: []corev1.ContainerPort{
-						{
-							ContainerPort: 8080,
-						},
-					},
-				},
-			}
-		}
-		service.ObjectMeta.Name = fmt.Sprintf("%s-%d", inputs.SvcPrefix, index)
+			service = svcProfile.Build(profile.Options{MinScale: inputs.MinScale, MaxScale: inputs.MaxScale, RunID: inputs.RunID})
+		}
+		service.ObjectMeta.Name = name
 		service.ObjectMeta.Namespace = ns
+		// the run labels are set on the revision template as well to select the revisions and pods of the run
+		service.ObjectMeta.Labels = mergeStringMaps(service.ObjectMeta.Labels, runLabels)
+		service.Spec.Template.ObjectMeta.Labels = mergeStringMaps(service.Spec.Template.ObjectMeta.Labels, runLabels)
+		service.ObjectMeta.Annotations = mergeStringMaps(service.ObjectMeta.Annotations, map[string]string{
+			pkg.IndexAnnotationKey:        strconv.Itoa(index),
+			pkg.GenerateArgsAnnotationKey: generateArgs,
+		})
+		if weights != nil {
+			// revisions are named to be referenced by the traffic targets before they exist
+			service.Spec.Template.ObjectMeta.Name = generatedRevisionName(name, 1)
+			if len(weights) == 1 {
+				service.Spec.Traffic = trafficTargets([]string{service.Spec.Template.ObjectMeta.Name}, weights)
+			}
+		}
+
+		if dryRun == DryRunClient {
+			service.TypeMeta = metav1.TypeMeta{APIVersion: servingv1.SchemeGroupVersion.String(), Kind: "Service"}
+			renderedLock.Lock()
+			rendered[index] = service
+			if mapDomain {
+				dm := profile.NewDomainMapping(service, inputs.Domain)
+				dm.TypeMeta = metav1.TypeMeta{APIVersion: servingv1beta1.SchemeGroupVersion.String(), Kind: "DomainMapping"}
+				renderedMappings[index] = dm
+			}
+			renderedLock.Unlock()
+			return ns, name, nil
+		}
 
 		fmt.Printf("Creating Knative Service %s in namespace %s\n", service.GetName(), service.GetNamespace())
-		_, err := ksvcClient.Services(ns).Create(context.TODO(), service, metav1.CreateOptions{})
+		// the requests in flight are cancelled with the generation
+		createCtx := ctx
+		var timing *admissionTiming
+		if measureAdmission {
+			createCtx, timing = withAdmissionTiming(createCtx)
+		}
+		createStart := time.Now()
+		createdAt.Store(ns+"/"+name, createStart)
+		created, err := ksvcClient.Services(ns).Create(createCtx, service, createOptions)
 		if err != nil {
 			fmt.Printf("failed to create Knative Service %s in namespace %s : %s\n", service.GetName(), service.GetNamespace(), err)
+			return service.GetNamespace(), service.GetName(), stopOnError(err)
 		}
-		return service.GetNamespace(), service.GetName()
+		if timing != nil {
+			admissionTimings.Store(ns+"/"+name, timing.result(time.Since(createStart)))
+		}
+		if mapDomain {
+			// the DomainMapping is owned by the created Knative Service to be deleted along with it
+			dm := profile.NewDomainMapping(created, inputs.Domain)
+			dmCreateStart := time.Now()
+			_, err = dmClient.DomainMappings(ns).Create(createCtx, dm, createOptions)
+			if err != nil {
+				fmt.Printf("failed to create DomainMapping %s in namespace %s : %s\n", dm.GetName(), ns, err)
+				return service.GetNamespace(), service.GetName(), stopOnError(fmt.Errorf("failed to create DomainMapping: %w", err))
+			}
+			domainMappings.Store(ns+"/"+name, createdDomainMapping{name: dm.Name, createdAt: dmCreateStart})
+		}
+		// the revisions are not rolled out on dry-run as the Knative Service is not persisted
+		if len(weights) > 1 && dryRun == DryRunNone {
+			err = rolloutTrafficSplit(ctx, ksvcClient, ns, name, weights, inputs.Timeout)
+			if err != nil {
+				fmt.Printf("failed to split traffic of Knative Service %s in namespace %s : %s\n", service.GetName(), service.GetNamespace(), err)
+				return service.GetNamespace(), service.GetName(), stopOnError(fmt.Errorf("failed to split traffic: %w", err))
+			}
+		}
+		return service.GetNamespace(), service.GetName(), nil
 	}
 	checkServiceStatusReadyFunc := func(ns, name string) error {
-		start := time.Now()
-		for time.Since(start) < inputs.Timeout {
-			svc, _ := ksvcClient.Services(ns).Get(context.TODO(), name, metav1.GetOptions{})
-			conditions := svc.Status.Conditions
-			for i := 0; i < len(conditions); i++ {
-				if conditions[i].Type == knativeapis.ConditionReady && conditions[i].IsTrue() {
-					return nil
+		readyAt, err := readinessWatcher.waitReady(ctx, ns, name, inputs.Timeout)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return stopOnError(err)
+		}
+		// time to ready is measured from the create request of the Knative Service
+		key := ns + "/" + name
+		created, ok := createdAt.Load(key)
+		if ok {
+			readyDurations.Store(key, readyAt.Sub(created.(time.Time)).Seconds())
+		}
+		if probe != nil && ok {
+			// the first responses are measured from the create requests as well
+			svc, err := ksvcClient.Services(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil || svc.Status.URL == nil {
+				fmt.Printf("failed to get the URL of Knative Service %s in namespace %s: %v\n", name, ns, err)
+			} else if first, err := probe.firstResponse(ctx, svc.Status.URL); err != nil {
+				fmt.Printf("Knative Service %s/%s: %s\n", ns, name, err)
+			} else {
+				httpsDurations.Store(key, first.Sub(created.(time.Time)).Seconds())
+			}
+			if mapping, ok := domainMappings.Load(key); ok {
+				dm := mapping.(createdDomainMapping)
+				if first, err := probe.firstResponse(ctx, &apis.URL{Scheme: "https", Host: dm.name}); err != nil {
+					fmt.Printf("DomainMapping %s/%s: %s\n", ns, dm.name, err)
+				} else {
+					dmHTTPSDurations.Store(key, first.Sub(dm.createdAt).Seconds())
 				}
 			}
 		}
-		fmt.Printf("Error: Knative Service %s in namespace %s is not ready after %s\n", name, ns, inputs.Timeout)
-		return fmt.Errorf("Knative Service %s in namespace %s is not ready after %s ", name, ns, inputs.Timeout)
-
+		return nil
+	}
+	result := pkg.GenerateResult{
+		RunID:       inputs.RunID,
+		Number:      inputs.Number,
+		Concurrency: inputs.Concurrency,
+		Namespaces:  nsNameList,
+		Arrival: pkg.ArrivalInfo{
+			Pattern:    arrival.Name(),
+			Parameters: arrival.Parameters(),
+		},
+		StartTime: time.Now(),
+	}
+	if dryRun != DryRunNone {
+		result.DryRun = dryRun
 	}
+	postGenerateFunc := func(ns, name string) error { return nil }
 	if inputs.CheckReady {
-		generator.NewBatchGenerator(time.Duration(inputs.Interval)*time.Second, inputs.Number, inputs.Batch, inputs.Concurrency, nsNameList, createKSVCFunc, checkServiceStatusReadyFunc).Generate()
+		postGenerateFunc = checkServiceStatusReadyFunc
+	}
+	// the arrival pattern is only recorded when rendering, there is no point in waiting between the batches
+	genArrival := arrival
+	if dryRun == DryRunClient {
+		genArrival = &generator.BurstArrival{}
+	}
+	report, genErr := generator.NewArrivalBatchGenerator(genArrival, inputs.Number, inputs.Concurrency, nsNameList, createKSVCFunc, postGenerateFunc).Generate(ctx)
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).Seconds()
+	setGenerateReport(&result, report, &readyDurations, &admissionTimings, &httpsDurations, &dmHTTPSDurations)
+	if recorder != nil {
+		waitTimelineReady(signalCtx, recorder, report, inputs.Timeout)
+		setTimelineResult(&result, recorder)
+		if inputs.Output != "" {
+			err = GenerateOutput(inputs.Output, TimelineOutputFilename, true, false, false, timelineRows(recorder.timeline()), nil)
+			if err != nil {
+				fmt.Printf("failed to generate output: %s\n", err)
+				return err
+			}
+		}
+	}
+	switch dryRun {
+	case DryRunClient:
+		fmt.Fprintf(logOut, "Attempted %d of %d Knative Services: %d rendered, %d failed\n", report.Attempted, inputs.Number, report.Created, report.Failed)
+	case DryRunServer:
+		fmt.Printf("Attempted %d of %d Knative Services: %d admitted, %d failed\n", report.Attempted, inputs.Number, report.Created, report.Failed)
+	default:
+		fmt.Printf("Attempted %d of %d Knative Services: %d created, %d failed\n", report.Attempted, inputs.Number, report.Created, report.Failed)
+	}
+
+	// the manifests are only written when every Knative Service is rendered
+	if dryRun == DryRunClient && genErr == nil && report.Failed == 0 {
+		objects := []runtime.Object{}
+		if inputs.CreateNamespaces {
+			for _, ns := range nsNameList {
+				objects = append(objects, newNamespace(ns, inputs.RunID))
+			}
+		}
+		if inputs.Template == "" {
+			for _, ns := range nsNameList {
+				configMaps, secrets := profileObjects(ns, svcProfile, inputs.RunID)
+				for _, cm := range configMaps {
+					cm.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}
+					objects = append(objects, cm)
+				}
+				for _, secret := range secrets {
+					secret.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+					objects = append(objects, secret)
+				}
+			}
+		}
+		indexes := make([]int, 0, len(rendered))
+		for index := range rendered {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+		for _, index := range indexes {
+			objects = append(objects, rendered[index])
+			if dm, ok := renderedMappings[index]; ok {
+				objects = append(objects, dm)
+			}
+		}
+		err = writeManifests(manifestOutput, objects)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the partial result is saved as well when the generation is stopped
+	if inputs.Output != "" {
+		// generate JSON output from the generation result
+		err = GenerateOutput(inputs.Output, GenerateOutputFilename, false, false, true, nil, result)
+		if err != nil {
+			fmt.Printf("failed to generate output: %s\n", err)
+			return err
+		}
+	}
+
+	for _, item := range report.Items {
+		if item.Err != nil && !inputs.ContinueOnError {
+			return fmt.Errorf("failed to generate Knative Service %s in namespace %s: %w", item.Name, item.Namespace, item.Err)
+		}
+	}
+	if genErr != nil {
+		return fmt.Errorf("generation stopped after %d of %d Knative Services: %w", report.Attempted, inputs.Number, genErr)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d Knative Services failed to be generated", report.Failed, inputs.Number)
+	}
+	return nil
+}
+
+// setGenerateReport records the result of every attempted Knative Service in the generation result, along with the
+// time to ready and to the first HTTPS responses in seconds and the admission timing of the Knative Services by
+// namespace/name key if measured
+func setGenerateReport(result *pkg.GenerateResult, report generator.Report, readyDurations, admissionTimings, httpsDurations, dmHTTPSDurations *sync.Map) {
+	result.Attempted = report.Attempted
+	result.Created = report.Created
+	result.Failed = report.Failed
+	result.Items = make([]pkg.GenerateItemResult, 0, len(report.Items))
+	readyList, httpsList, dmHTTPSList := []float64{}, []float64{}, []float64{}
+	admissionList := []*pkg.AdmissionTiming{}
+	for _, item := range report.Items {
+		itemResult := pkg.GenerateItemResult{
+			Index:         item.Index,
+			Namespace:     item.Namespace,
+			Name:          item.Name,
+			Created:       item.Created,
+			CreateLatency: item.CreateLatency.Seconds(),
+		}
+		if item.Err != nil {
+			itemResult.Error = item.Err.Error()
+		}
+		if readyDuration, ok := readyDurations.Load(item.Namespace + "/" + item.Name); ok {
+			itemResult.ReadyDuration = readyDuration.(float64)
+			readyList = append(readyList, itemResult.ReadyDuration)
+		}
+		if httpsDuration, ok := httpsDurations.Load(item.Namespace + "/" + item.Name); ok {
+			itemResult.FirstHTTPSResponse = httpsDuration.(float64)
+			httpsList = append(httpsList, itemResult.FirstHTTPSResponse)
+		}
+		if dmHTTPSDuration, ok := dmHTTPSDurations.Load(item.Namespace + "/" + item.Name); ok {
+			itemResult.DomainMappingHTTPSResponse = dmHTTPSDuration.(float64)
+			dmHTTPSList = append(dmHTTPSList, itemResult.DomainMappingHTTPSResponse)
+		}
+		if timing, ok := admissionTimings.Load(item.Namespace + "/" + item.Name); ok {
+			itemResult.Admission = timing.(*pkg.AdmissionTiming)
+			admissionList = append(admissionList, itemResult.Admission)
+		}
+		result.Items = append(result.Items, itemResult)
+	}
+	if len(readyList) > 0 {
+		fmt.Printf("time to ready latency result:\n")
+		result.ReadyLatency = latencyResultHandler(readyList)
+	}
+	if len(httpsList) > 0 {
+		fmt.Printf("first HTTPS response latency result:\n")
+		httpsLatency := latencyResultHandler(httpsList)
+		result.HTTPSResponse = &httpsLatency
+	}
+	if len(dmHTTPSList) > 0 {
+		fmt.Printf("DomainMapping first HTTPS response latency result:\n")
+		dmHTTPSLatency := latencyResultHandler(dmHTTPSList)
+		result.DomainMappingHTTPSResponse = &dmHTTPSLatency
+	}
+	if len(admissionList) > 0 {
+		result.Admission = admissionResultHandler(admissionList)
+	}
+}
+
+// createdDomainMapping is the DomainMapping created for a Knative Service, its name being its domain
+type createdDomainMapping struct {
+	name      string
+	createdAt time.Time
+}
+
+// waitTimelineReady waits for the created Knative Services to be seen ready by the timeline recorder, so that the
+// phases of all of them are recorded, until timeout
+func waitTimelineReady(ctx context.Context, recorder *timelineRecorder, report generator.Report, timeout time.Duration) {
+	err := wait.PollImmediateWithContext(ctx, timelinePollInterval, timeout, func(ctx context.Context) (bool, error) {
+		for _, item := range report.Items {
+			if item.Created && !recorder.ready(item.Namespace, item.Name) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		fmt.Printf("Stopped recording the timeline before all the Knative Services were ready: %s\n", err)
+	}
+}
+
+// setTimelineResult records the phases of every Knative Service computed from the timeline in the generation result
+func setTimelineResult(result *pkg.GenerateResult, recorder *timelineRecorder) {
+	phases := recorder.phases()
+	for i, item := range result.Items {
+		if itemPhases, ok := phases[item.Namespace+"/"+item.Name]; ok {
+			result.Items[i].Phases = itemPhases
+		}
+	}
+	result.Phases = phaseResultHandler(phases)
+}
+
+// generateArgsAnnotation returns the parameters of the generation recorded on every generated Knative Service
+func generateArgsAnnotation(inputs pkg.GenerateArgs, arrival generator.Arrival) (string, error) {
+	args := map[string]string{
+		"number":      strconv.Itoa(inputs.Number),
+		"concurrency": strconv.Itoa(inputs.Concurrency),
+		"arrival":     arrival.Name(),
+		"revisions":   strconv.Itoa(inputs.Revisions),
+	}
+	for k, v := range arrival.Parameters() {
+		args[k] = v
+	}
+	if inputs.Template != "" {
+		args["template"] = inputs.Template
+	} else if inputs.Profile != "" {
+		args["profile"] = inputs.Profile
 	} else {
-		generator.NewBatchGenerator(time.Duration(inputs.Interval)*time.Second, inputs.Number, inputs.Batch, inputs.Concurrency, nsNameList, createKSVCFunc, func(ns, name string) error { return nil }).Generate()
+		args["profile"] = profile.Default
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode generate args: %w", err)
 	}
+	return string(data), nil
+}
 
+// ensureProfileObjects creates the ConfigMaps and Secrets mounted by the Knative Services of the profile in the
+// namespaces, named after and labeled with the run ID so that clean only deletes the ones of the run cleaned. The
+// objects may already exist if the run is generated in several steps
+func ensureProfileObjects(ctx context.Context, params *pkg.PerfParams, nsNameList []string, svcProfile profile.Profile, runID string, opts metav1.CreateOptions) error {
+	for _, ns := range nsNameList {
+		configMaps, secrets := profileObjects(ns, svcProfile, runID)
+		for _, configMap := range configMaps {
+			_, err := params.ClientSet.CoreV1().ConfigMaps(ns).Create(ctx, configMap, opts)
+			if err != nil && !apierrors.IsAlreadyExists(err) {
+				return fmt.Errorf("failed to create ConfigMap %s in namespace %s: %w", configMap.Name, ns, err)
+			}
+		}
+		for _, secret := range secrets {
+			_, err := params.ClientSet.CoreV1().Secrets(ns).Create(ctx, secret, opts)
+			if err != nil && !apierrors.IsAlreadyExists(err) {
+				return fmt.Errorf("failed to create Secret %s in namespace %s: %w", secret.Name, ns, err)
+			}
+		}
+	}
 	return nil
 }
+
+// profileObjects returns the ConfigMaps and Secrets of the profile in the namespace for the run
+func profileObjects(ns string, svcProfile profile.Profile, runID string) ([]*corev1.ConfigMap, []*corev1.Secret) {
+	configMaps := make([]*corev1.ConfigMap, 0, len(svcProfile.ConfigMaps))
+	for _, cm := range svcProfile.ConfigMaps {
+		configMap := cm.DeepCopy()
+		configMap.Name = profile.RunObjectName(configMap.Name, runID)
+		configMap.Namespace = ns
+		configMap.Labels = mergeStringMaps(configMap.Labels, pkg.RunLabels(runID))
+		configMaps = append(configMaps, configMap)
+	}
+	secrets := make([]*corev1.Secret, 0, len(svcProfile.Secrets))
+	for _, s := range svcProfile.Secrets {
+		secret := s.DeepCopy()
+		secret.Name = profile.RunObjectName(secret.Name, runID)
+		secret.Namespace = ns
+		secret.Labels = mergeStringMaps(secret.Labels, pkg.RunLabels(runID))
+		secrets = append(secrets, secret)
+	}
+	return configMaps, secrets
+}
+
+// mergeStringMaps returns base with the entries of extra added, extra overriding the existing keys
+func mergeStringMaps(base, extra map[string]string) map[string]string {
+	if base == nil {
+		base = make(map[string]string, len(extra))
+	}
+	for k, v := range extra {
+		base[k] = v
+	}
+	return base
+}
+
+// newArrival returns the arrival schedule of the Knative Services selected by the generate args
+func newArrival(inputs pkg.GenerateArgs) (generator.Arrival, error) {
+	interval := time.Duration(inputs.Interval) * time.Second
+	switch inputs.Arrival {
+	case "", generator.ArrivalFixed:
+		return &generator.FixedArrival{Interval: interval, Batch: inputs.Batch}, nil
+	case generator.ArrivalRamp:
+		if inputs.Batch < 0 || inputs.RampIncrement < 0 {
+			return nil, fmt.Errorf("ramp arrival requires a non-negative batch and ramp-increment, given %d and %d", inputs.Batch, inputs.RampIncrement)
+		}
+		if inputs.Batch == 0 && inputs.RampIncrement == 0 {
+			return nil, errors.New("ramp arrival requires a positive batch or ramp-increment")
+		}
+		return &generator.RampArrival{Interval: interval, Start: inputs.Batch, Increment: inputs.RampIncrement}, nil
+	case generator.ArrivalStep:
+		steps, err := generator.ParseArrivalSteps(inputs.Steps)
+		if err != nil {
+			return nil, err
+		}
+		return &generator.StepArrival{Interval: interval, Steps: steps}, nil
+	case generator.ArrivalPoisson:
+		if inputs.Rate <= 0 {
+			return nil, fmt.Errorf("poisson arrival requires a positive rate, given %v", inputs.Rate)
+		}
+		return &generator.PoissonArrival{Rate: inputs.Rate}, nil
+	case generator.ArrivalBurst:
+		return &generator.BurstArrival{Delay: interval}, nil
+	}
+	return nil, fmt.Errorf("unknown arrival pattern %s, expected one of fixed, ramp, step, poisson or burst", inputs.Arrival)
+}
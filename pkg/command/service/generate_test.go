@@ -16,18 +16,36 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
 	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/generator"
+	"knative.dev/kperf/pkg/profile"
 	"knative.dev/kperf/pkg/testutil"
+	"knative.dev/pkg/apis"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
 	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
 	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
+	servingv1beta1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1beta1"
+	servingv1beta1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1beta1/fake"
+	"sigs.k8s.io/yaml"
 )
 
 func TestNewServiceGenerateCommand(t *testing.T) {
@@ -75,8 +93,8 @@ func TestNewServiceGenerateCommand(t *testing.T) {
 		_, err = testutil.ExecuteCommand(cmd, "--namespace-prefix", "test-kperf", "--namespace-range", "1,y")
 		assert.ErrorContains(t, err, "strconv.Atoi: parsing \"y\": invalid syntax")
 
-		_, err = testutil.ExecuteCommand(cmd, "--namespace-prefix", "test-kperf", "--namespace-range", "1")
-		assert.ErrorContains(t, err, "expected range like 1,500, given 1")
+		_, err = testutil.ExecuteCommand(cmd, "--namespace-prefix", "test-kperf", "--namespace-range", "1-")
+		assert.ErrorContains(t, err, "expected range like 1,500, given 1-")
 
 		_, err = testutil.ExecuteCommand(cmd, "--namespace-prefix", "test-kperf", "--namespace", "test-kperf")
 		assert.ErrorContains(t, err, "expected either namespace with prefix & range or only namespace name")
@@ -115,6 +133,34 @@ func TestNewServiceGenerateCommand(t *testing.T) {
 		assert.DeepEqual(t, targetAnnotations, resultAnnotations)
 	})
 
+	t.Run("generate services labelled with the run", func(t *testing.T) {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1"}}
+		client := k8sfake.NewSimpleClientset(ns)
+		fakeServing := &servingv1fake.FakeServingV1{Fake: &client.Fake}
+		p := &pkg.PerfParams{
+			ClientSet: client,
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return fakeServing, nil
+			},
+		}
+
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "2", "-b", "2", "-i", "0", "--namespace", "test-kperf-1", "--run-id", "test-run")
+		assert.NilError(t, err)
+
+		for i := 0; i < 2; i++ {
+			svc, err := fakeServing.Services("test-kperf-1").Get(context.TODO(), fmt.Sprintf("ksvc-%d", i), metav1.GetOptions{})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, pkg.RunLabels("test-run"), svc.Labels)
+			assert.DeepEqual(t, pkg.RunLabels("test-run"), svc.Spec.Template.Labels)
+			assert.Equal(t, strconv.Itoa(i), svc.Annotations[pkg.IndexAnnotationKey])
+			generateArgs := map[string]string{}
+			assert.NilError(t, json.Unmarshal([]byte(svc.Annotations[pkg.GenerateArgsAnnotationKey]), &generateArgs))
+			assert.Equal(t, "2", generateArgs["number"])
+			assert.Equal(t, "fixed", generateArgs["arrival"])
+		}
+	})
+
 	t.Run("generate service as expected with namespace prefix flag", func(t *testing.T) {
 		ns1 := &corev1.Namespace{
 			ObjectMeta: metav1.ObjectMeta{
@@ -185,6 +231,83 @@ func TestNewServiceGenerateCommand(t *testing.T) {
 		assert.ErrorContains(t, err, "namespace test-kperf-1 not found, please create one")
 	})
 
+	t.Run("generate services in created namespaces", func(t *testing.T) {
+		client := k8sfake.NewSimpleClientset()
+		fakeServing := &servingv1fake.FakeServingV1{Fake: &client.Fake}
+		servingClient := func() (servingv1client.ServingV1Interface, error) {
+			return fakeServing, nil
+		}
+
+		p := &pkg.PerfParams{
+			ClientSet:        client,
+			NewServingClient: servingClient,
+		}
+
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "2", "-b", "2", "-i", "0", "--namespace-prefix", "test-kperf", "--namespace-range", "1,2", "--create-namespaces", "--run-id", "test-run")
+		assert.NilError(t, err)
+
+		for _, name := range []string{"test-kperf-1", "test-kperf-2"} {
+			ns, err := client.CoreV1().Namespaces().Get(context.TODO(), name, metav1.GetOptions{})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, pkg.RunLabels("test-run"), ns.Labels)
+		}
+	})
+
+	t.Run("invalid flags do not create namespaces", func(t *testing.T) {
+		client := k8sfake.NewSimpleClientset()
+		fakeServing := &servingv1fake.FakeServingV1{Fake: &client.Fake}
+		servingClient := func() (servingv1client.ServingV1Interface, error) {
+			return fakeServing, nil
+		}
+
+		p := &pkg.PerfParams{
+			ClientSet:        client,
+			NewServingClient: servingClient,
+		}
+
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--create-namespaces", "--arrival", "rmap")
+		assert.ErrorContains(t, err, "unknown arrival pattern rmap")
+
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--create-namespaces", "--revisions", "2", "--traffic", "50")
+		assert.ErrorContains(t, err, "traffic percents must sum to 100, given 50")
+
+		namespaces, err := client.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, 0, len(namespaces.Items))
+	})
+
+	t.Run("invalid flags do not create the profile objects", func(t *testing.T) {
+		ns1 := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-kperf-1",
+			},
+		}
+		client := k8sfake.NewSimpleClientset(ns1)
+		fakeServing := &servingv1fake.FakeServingV1{Fake: &client.Fake}
+		servingClient := func() (servingv1client.ServingV1Interface, error) {
+			return fakeServing, nil
+		}
+
+		p := &pkg.PerfParams{
+			ClientSet:        client,
+			NewServingClient: servingClient,
+		}
+
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--profile", "volumes", "--arrival", "rmap")
+		assert.ErrorContains(t, err, "unknown arrival pattern rmap")
+
+		configMaps, err := client.CoreV1().ConfigMaps("test-kperf-1").List(context.TODO(), metav1.ListOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, 0, len(configMaps.Items))
+		secrets, err := client.CoreV1().Secrets("test-kperf-1").List(context.TODO(), metav1.ListOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, 0, len(secrets.Items))
+	})
+
 	t.Run("create service from template", func(t *testing.T) {
 
 		ns1 := &corev1.Namespace{
@@ -243,4 +366,473 @@ spec:
 		assert.DeepEqual(t, targetAnnotations, resultAnnotations)
 
 	})
+
+	t.Run("create services from rendered template", func(t *testing.T) {
+		ns1 := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-kperf-1",
+			},
+		}
+		client := k8sfake.NewSimpleClientset(ns1)
+		fakeServing := &servingv1fake.FakeServingV1{Fake: &client.Fake}
+		servingClient := func() (servingv1client.ServingV1Interface, error) {
+			return fakeServing, nil
+		}
+
+		p := &pkg.PerfParams{
+			ClientSet:        client,
+			NewServingClient: servingClient,
+		}
+
+		templatePath := writeTemplate(t, variedTemplateYaml)
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "2", "-b", "2", "-i", "1", "--namespace", "test-kperf-1", "--template", templatePath, "--run-id", "test-run")
+		assert.NilError(t, err)
+
+		ksvcClient, err := p.NewServingClient()
+		assert.NilError(t, err)
+		svc, err := ksvcClient.Services("test-kperf-1").Get(context.TODO(), "ksvc-0", metav1.GetOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, "gcr.io/knative-samples/helloworld-go", svc.Spec.Template.Spec.Containers[0].Image)
+		assert.Equal(t, "test-run", svc.Annotations["kperf.knative.dev/run"])
+		svc, err = ksvcClient.Services("test-kperf-1").Get(context.TODO(), "ksvc-1", metav1.GetOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, "gcr.io/knative-samples/helloworld-rust", svc.Spec.Template.Spec.Containers[0].Image)
+
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "1", "--namespace", "test-kperf-1", "--template", writeTemplate(t, "{{ .Name"))
+		assert.ErrorContains(t, err, "failed to parse template file")
+	})
+
+	t.Run("generate services with arrival pattern and save result", func(t *testing.T) {
+		ns1 := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-kperf-1",
+			},
+		}
+		client := k8sfake.NewSimpleClientset(ns1)
+		fakeServing := &servingv1fake.FakeServingV1{Fake: &client.Fake}
+		servingClient := func() (servingv1client.ServingV1Interface, error) {
+			return fakeServing, nil
+		}
+
+		p := &pkg.PerfParams{
+			ClientSet:        client,
+			NewServingClient: servingClient,
+		}
+
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "1", "--namespace", "test-kperf-1", "--arrival", "unknown")
+		assert.ErrorContains(t, err, "unknown arrival pattern unknown")
+
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "1", "--namespace", "test-kperf-1", "--arrival", "step", "--steps", "1")
+		assert.ErrorContains(t, err, "expected steps like 5x3,10x3, given 1")
+
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "1", "-i", "1", "--namespace", "test-kperf-1", "--arrival", "step")
+		assert.ErrorContains(t, err, "required flag(s) \"steps\"")
+
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "1", "--namespace", "test-kperf-1", "--arrival", "poisson", "--rate", "0")
+		assert.ErrorContains(t, err, "poisson arrival requires a positive rate")
+
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "1", "-b", "5", "-i", "0", "--namespace", "test-kperf-1", "--arrival", "ramp", "--ramp-increment", "-3")
+		assert.ErrorContains(t, err, "ramp arrival requires a non-negative batch and ramp-increment, given 5 and -3")
+
+		// the batches do not apply to the burst pattern
+		output := t.TempDir()
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "3", "--namespace", "test-kperf-1", "--arrival", "burst", "--run-id", "test-run", "--output", output)
+		assert.NilError(t, err)
+
+		matches, err := filepath.Glob(filepath.Join(output, "*_"+GenerateOutputFilename+".json"))
+		assert.NilError(t, err)
+		assert.Equal(t, 1, len(matches))
+		data, err := os.ReadFile(matches[0])
+		assert.NilError(t, err)
+		result := pkg.GenerateResult{}
+		assert.NilError(t, json.Unmarshal(data, &result))
+		assert.Equal(t, "test-run", result.RunID)
+		assert.Equal(t, 3, result.Number)
+		assert.Equal(t, generator.ArrivalBurst, result.Arrival.Pattern)
+		assert.Equal(t, "0s", result.Arrival.Parameters["delay"])
+		assert.Equal(t, 3, result.Attempted)
+		assert.Equal(t, 3, result.Created)
+		assert.Equal(t, 0, result.Failed)
+		assert.Equal(t, 3, len(result.Items))
+	})
+
+	t.Run("generate services with a profile", func(t *testing.T) {
+		ns1 := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1"}}
+		client := k8sfake.NewSimpleClientset(ns1)
+		// the Knative Services are listed to clean them
+		fakeServing := &servingv1fake.FakeServingV1{Fake: testutil.NewKnativeFake()}
+		p := &pkg.PerfParams{
+			ClientSet: client,
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return fakeServing, nil
+			},
+		}
+
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--profile", "unknown")
+		assert.ErrorContains(t, err, "unknown profile unknown")
+
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--profile", "volumes", "--template", "ksvc.yaml")
+		assert.ErrorContains(t, err, "expected either template or profile")
+
+		// the default profile is not used with a template either
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--profile", "default", "--template", "ksvc.yaml")
+		assert.ErrorContains(t, err, "expected either template or profile")
+
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--profile", "volumes", "--run-id", "test-run")
+		assert.NilError(t, err)
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "2", "-b", "2", "-i", "0", "--namespace", "test-kperf-1", "--profile", "volumes", "--run-id", "other-run", "--svc-prefix", "other")
+		assert.NilError(t, err)
+
+		testRunData := profile.RunObjectName(profile.DataName, "test-run")
+		otherRunData := profile.RunObjectName(profile.DataName, "other-run")
+		svc, err := fakeServing.Services("test-kperf-1").Get(context.TODO(), "ksvc-0", metav1.GetOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, 2, len(svc.Spec.Template.Spec.Volumes))
+		assert.Equal(t, testRunData, svc.Spec.Template.Spec.Volumes[0].ConfigMap.Name)
+		assert.Equal(t, testRunData, svc.Spec.Template.Spec.Volumes[1].Secret.SecretName)
+		configMap, err := client.CoreV1().ConfigMaps("test-kperf-1").Get(context.TODO(), testRunData, metav1.GetOptions{})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, pkg.RunLabels("test-run"), configMap.Labels)
+		_, err = client.CoreV1().Secrets("test-kperf-1").Get(context.TODO(), testRunData, metav1.GetOptions{})
+		assert.NilError(t, err)
+
+		// the ConfigMap and Secret are cleaned with the Knative Services of their run, without run ID too, and
+		// the ones of the other run are kept
+		err = CleanServices(p, pkg.CleanArgs{Namespace: "test-kperf-1", SvcPrefix: "ksvc"})
+		assert.NilError(t, err)
+		_, err = client.CoreV1().ConfigMaps("test-kperf-1").Get(context.TODO(), testRunData, metav1.GetOptions{})
+		assert.Assert(t, apierrors.IsNotFound(err))
+		_, err = client.CoreV1().Secrets("test-kperf-1").Get(context.TODO(), testRunData, metav1.GetOptions{})
+		assert.Assert(t, apierrors.IsNotFound(err))
+		_, err = client.CoreV1().ConfigMaps("test-kperf-1").Get(context.TODO(), otherRunData, metav1.GetOptions{})
+		assert.NilError(t, err)
+
+		// the objects of a run are kept until its last Knative Service is cleaned
+		err = CleanServices(p, pkg.CleanArgs{Namespace: "test-kperf-1", SvcPrefix: "other", SvcRegex: "^other-0$"})
+		assert.NilError(t, err)
+		_, err = client.CoreV1().Secrets("test-kperf-1").Get(context.TODO(), otherRunData, metav1.GetOptions{})
+		assert.NilError(t, err)
+		err = CleanServices(p, pkg.CleanArgs{Namespace: "test-kperf-1", RunID: "other-run"})
+		assert.NilError(t, err)
+		_, err = client.CoreV1().ConfigMaps("test-kperf-1").Get(context.TODO(), otherRunData, metav1.GetOptions{})
+		assert.Assert(t, apierrors.IsNotFound(err))
+		_, err = client.CoreV1().Secrets("test-kperf-1").Get(context.TODO(), otherRunData, metav1.GetOptions{})
+		assert.Assert(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("generate services mapped to a domain", func(t *testing.T) {
+		ns1 := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1"}}
+		client := k8sfake.NewSimpleClientset(ns1)
+		knativeFake := testutil.NewKnativeFake()
+		fakeServing := &servingv1fake.FakeServingV1{Fake: knativeFake}
+		fakeDomainMapping := &servingv1beta1fake.FakeServingV1beta1{Fake: knativeFake}
+		p := &pkg.PerfParams{
+			ClientSet: client,
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return fakeServing, nil
+			},
+			NewDomainMappingClient: func() (servingv1beta1client.ServingV1beta1Interface, error) {
+				return fakeDomainMapping, nil
+			},
+		}
+
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "2", "-b", "2", "-i", "0", "--namespace", "test-kperf-1", "--profile", "domain-mapping",
+			"--domain", "kperf.test", "--run-id", "test-run")
+		assert.NilError(t, err)
+		dm, err := fakeDomainMapping.DomainMappings("test-kperf-1").Get(context.TODO(), "ksvc-1.test-kperf-1.kperf.test", metav1.GetOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, "ksvc-1", dm.Spec.Ref.Name)
+		assert.Equal(t, "test-run", dm.Labels[pkg.RunIDLabelKey])
+
+		// the DomainMappings are rendered after their Knative Service
+		file := filepath.Join(t.TempDir(), "manifests.yaml")
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "2", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--profile", "domain-mapping",
+			"--dry-run", "client", "--manifest-output", file)
+		assert.NilError(t, err)
+		data, err := os.ReadFile(file)
+		assert.NilError(t, err)
+		assert.Equal(t, 2, strings.Count(string(data), "kind: DomainMapping\n"))
+		assert.Assert(t, strings.Index(string(data), "name: ksvc-0\n") < strings.Index(string(data), "name: ksvc-0.test-kperf-1.example.com\n"))
+	})
+
+	t.Run("generate services and wait for them to be ready", func(t *testing.T) {
+		ns1 := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1"}}
+		client := k8sfake.NewSimpleClientset(ns1)
+		fakeServing := &servingv1fake.FakeServingV1{Fake: testutil.NewKnativeFake()}
+		// the services named ksvc-0 become ready as soon as they are created
+		fakeServing.PrependReactor("create", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+			svc := a.(clienttesting.CreateAction).GetObject().(*servingv1.Service)
+			if svc.Name == "ksvc-0" {
+				svc.Status = readyService(svc, corev1.ConditionTrue, "", "").Status
+			} else {
+				svc.Status = readyService(svc, corev1.ConditionFalse, "RevisionFailed", "Revision failed").Status
+			}
+			return false, nil, nil
+		})
+		p := &pkg.PerfParams{
+			ClientSet: client,
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return fakeServing, nil
+			},
+		}
+
+		output := t.TempDir()
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "2", "-b", "1", "-i", "0", "-c", "1", "--namespace", "test-kperf-1", "--wait", "--timeout", "100ms", "--continue-on-error", "--output", output)
+		assert.ErrorContains(t, err, "1 of 2 Knative Services failed to be generated")
+
+		matches, err := filepath.Glob(filepath.Join(output, "*_"+GenerateOutputFilename+".json"))
+		assert.NilError(t, err)
+		data, err := os.ReadFile(matches[0])
+		assert.NilError(t, err)
+		result := pkg.GenerateResult{}
+		assert.NilError(t, json.Unmarshal(data, &result))
+		assert.Equal(t, 2, result.Created)
+		assert.Equal(t, 1, result.Failed)
+		assert.Equal(t, "", result.Items[0].Error)
+		assert.Assert(t, result.Items[0].ReadyDuration > 0)
+		assert.Equal(t, result.Items[0].ReadyDuration, result.ReadyLatency.Max)
+		assert.ErrorContains(t, errors.New(result.Items[1].Error), "RevisionFailed: Revision failed")
+	})
+
+	t.Run("generate services and request their HTTPS URLs once ready", func(t *testing.T) {
+		restoreClock()
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		url, err := apis.ParseURL(server.URL)
+		assert.NilError(t, err)
+
+		ns1 := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1"}}
+		client := k8sfake.NewSimpleClientset(ns1)
+		fakeServing := &servingv1fake.FakeServingV1{Fake: testutil.NewKnativeFake()}
+		fakeServing.PrependReactor("create", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+			svc := a.(clienttesting.CreateAction).GetObject().(*servingv1.Service)
+			svc.Status = readyService(svc, corev1.ConditionTrue, "", "").Status
+			svc.Status.URL = url
+			return false, nil, nil
+		})
+		p := &pkg.PerfParams{
+			ClientSet: client,
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return fakeServing, nil
+			},
+		}
+
+		cmd := NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--https")
+		assert.ErrorContains(t, err, "--https requires --wait")
+
+		output := t.TempDir()
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "2", "-b", "2", "-i", "0", "--namespace", "test-kperf-1", "--wait", "--timeout", "5s",
+			"--https", "--resolvable-domain", "--insecure-skip-verify", "--https-timeout", "5s", "--output", output)
+		assert.NilError(t, err)
+
+		matches, err := filepath.Glob(filepath.Join(output, "*_"+GenerateOutputFilename+".json"))
+		assert.NilError(t, err)
+		data, err := os.ReadFile(matches[0])
+		assert.NilError(t, err)
+		result := pkg.GenerateResult{}
+		assert.NilError(t, json.Unmarshal(data, &result))
+		// the first responses are measured from the create requests, after the services are ready
+		for _, item := range result.Items {
+			assert.Assert(t, item.FirstHTTPSResponse > 0 && item.FirstHTTPSResponse >= item.ReadyDuration)
+		}
+		assert.Equal(t, 2, len(result.Items))
+		assert.Assert(t, result.HTTPSResponse != nil)
+		assert.Assert(t, result.DomainMappingHTTPSResponse == nil)
+	})
+
+	t.Run("stop or continue generating services after a failure", func(t *testing.T) {
+		setup := func() *pkg.PerfParams {
+			ns1 := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1"}}
+			client := k8sfake.NewSimpleClientset(ns1)
+			client.PrependReactor("create", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+				svc := a.(clienttesting.CreateAction).GetObject().(*servingv1.Service)
+				if svc.Name == "ksvc-0" {
+					return true, nil, errors.New("create error")
+				}
+				return false, nil, nil
+			})
+			fakeServing := &servingv1fake.FakeServingV1{Fake: &client.Fake}
+			return &pkg.PerfParams{
+				ClientSet: client,
+				NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+					return fakeServing, nil
+				},
+			}
+		}
+		readResult := func(output string) pkg.GenerateResult {
+			matches, err := filepath.Glob(filepath.Join(output, "*_"+GenerateOutputFilename+".json"))
+			assert.NilError(t, err)
+			assert.Equal(t, 1, len(matches))
+			data, err := os.ReadFile(matches[0])
+			assert.NilError(t, err)
+			result := pkg.GenerateResult{}
+			assert.NilError(t, json.Unmarshal(data, &result))
+			return result
+		}
+
+		output := t.TempDir()
+		cmd := NewServiceGenerateCommand(setup())
+		_, err := testutil.ExecuteCommand(cmd, "-n", "3", "-b", "1", "-i", "0", "-c", "1", "--namespace", "test-kperf-1", "--output", output)
+		assert.ErrorContains(t, err, "failed to generate Knative Service ksvc-0 in namespace test-kperf-1: create error")
+		result := readResult(output)
+		assert.Equal(t, 1, result.Attempted)
+		assert.Equal(t, 0, result.Created)
+		assert.Equal(t, 1, result.Failed)
+		assert.Equal(t, "create error", result.Items[0].Error)
+
+		output = t.TempDir()
+		cmd = NewServiceGenerateCommand(setup())
+		_, err = testutil.ExecuteCommand(cmd, "-n", "3", "-b", "1", "-i", "0", "-c", "1", "--namespace", "test-kperf-1", "--output", output, "--continue-on-error")
+		assert.ErrorContains(t, err, "1 of 3 Knative Services failed to be generated")
+		result = readResult(output)
+		assert.Equal(t, 3, result.Attempted)
+		assert.Equal(t, 2, result.Created)
+		assert.Equal(t, 1, result.Failed)
+		assert.Equal(t, "", result.Items[1].Error)
+	})
+
+	t.Run("render services with client dry-run", func(t *testing.T) {
+		// the cluster is not accessed to render the manifests
+		p := &pkg.PerfParams{
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return nil, errors.New("unexpected serving client")
+			},
+		}
+
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--dry-run", "unknown")
+		assert.ErrorContains(t, err, "unknown dry-run mode unknown")
+
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--dry-run", "client", "--wait")
+		assert.ErrorContains(t, err, "--wait is not supported with --dry-run")
+
+		dir := filepath.Join(t.TempDir(), "manifests")
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "2", "-b", "1", "-i", "10", "--namespace-prefix", "test-kperf", "--namespace-range", "1,2",
+			"--create-namespaces", "--profile", "volumes", "--run-id", "test-run", "--dry-run", "client", "--manifest-output", dir)
+		assert.NilError(t, err)
+		files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+		assert.NilError(t, err)
+		assert.Equal(t, 8, len(files))
+		data, err := os.ReadFile(filepath.Join(dir, "service-test-kperf-2-ksvc-1.yaml"))
+		assert.NilError(t, err)
+		svc := &servingv1.Service{}
+		assert.NilError(t, yaml.Unmarshal(data, svc))
+		assert.Equal(t, "Service", svc.Kind)
+		assert.Equal(t, "test-run", svc.Labels[pkg.RunIDLabelKey])
+		assert.Equal(t, 2, len(svc.Spec.Template.Spec.Volumes))
+		data, err = os.ReadFile(filepath.Join(dir, "namespace-test-kperf-1.yaml"))
+		assert.NilError(t, err)
+		namespace := &corev1.Namespace{}
+		assert.NilError(t, yaml.Unmarshal(data, namespace))
+		assert.DeepEqual(t, pkg.RunLabels("test-run"), namespace.Labels)
+
+		file := filepath.Join(t.TempDir(), "manifests.yaml")
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "3", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--dry-run", "client", "--manifest-output", file)
+		assert.NilError(t, err)
+		data, err = os.ReadFile(file)
+		assert.NilError(t, err)
+		assert.Equal(t, 3, strings.Count(string(data), "kind: Service\n"))
+	})
+
+	t.Run("submit services with server dry-run", func(t *testing.T) {
+		ns1 := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1"}}
+		client := k8sfake.NewSimpleClientset(ns1)
+		// the API server admits the dry-run objects without persisting them
+		client.PrependReactor("create", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+			return true, a.(clienttesting.CreateAction).GetObject(), nil
+		})
+		fakeServing := &servingv1fake.FakeServingV1{Fake: &client.Fake}
+		p := &pkg.PerfParams{
+			ClientSet: client,
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return fakeServing, nil
+			},
+		}
+
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-2", "--create-namespaces", "--dry-run", "server")
+		assert.ErrorContains(t, err, "--create-namespaces is not supported with --dry-run server")
+
+		output := t.TempDir()
+		cmd = NewServiceGenerateCommand(p)
+		// the revisions are not rolled out as the Knative Services do not exist
+		_, err = testutil.ExecuteCommand(cmd, "-n", "2", "-b", "2", "-i", "0", "--namespace", "test-kperf-1", "--revisions", "2", "--dry-run", "server", "--output", output)
+		assert.NilError(t, err)
+
+		matches, err := filepath.Glob(filepath.Join(output, "*_"+GenerateOutputFilename+".json"))
+		assert.NilError(t, err)
+		data, err := os.ReadFile(matches[0])
+		assert.NilError(t, err)
+		result := pkg.GenerateResult{}
+		assert.NilError(t, json.Unmarshal(data, &result))
+		assert.Equal(t, DryRunServer, result.DryRun)
+		assert.Equal(t, 2, result.Created)
+		// the phases are not traced by the fake client, only the round trip of the create requests
+		assert.Assert(t, result.Items[0].Admission != nil)
+		assert.Equal(t, 0.0, result.Items[0].Admission.Server)
+		assert.Equal(t, result.Admission.Total.Max, math.Max(result.Items[0].Admission.Total, result.Items[1].Admission.Total))
+		assert.Assert(t, result.Admission.Total.Max > 0)
+		// the serving clients created after the generation are not instrumented
+		assert.Assert(t, p.WrapServingTransport == nil)
+	})
+
+	t.Run("generate services and record their timeline", func(t *testing.T) {
+		p, _, fakeServing := newTimelineParams(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1"}})
+		// the services become ready as soon as they are created
+		fakeServing.PrependReactor("create", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+			svc := a.(clienttesting.CreateAction).GetObject().(*servingv1.Service)
+			svc.Status = readyService(svc, corev1.ConditionTrue, "", "").Status
+			return false, nil, nil
+		})
+
+		cmd := NewServiceGenerateCommand(p)
+		_, err := testutil.ExecuteCommand(cmd, "-n", "1", "-b", "1", "-i", "0", "--namespace", "test-kperf-1", "--timeline", "--dry-run", "server")
+		assert.ErrorContains(t, err, "--timeline is not supported with --dry-run")
+
+		output := t.TempDir()
+		cmd = NewServiceGenerateCommand(p)
+		_, err = testutil.ExecuteCommand(cmd, "-n", "2", "-b", "2", "-i", "0", "--namespace", "test-kperf-1", "--timeline", "--timeout", "5s", "--output", output)
+		assert.NilError(t, err)
+
+		matches, err := filepath.Glob(filepath.Join(output, "*_"+GenerateOutputFilename+".json"))
+		assert.NilError(t, err)
+		data, err := os.ReadFile(matches[0])
+		assert.NilError(t, err)
+		result := pkg.GenerateResult{}
+		assert.NilError(t, json.Unmarshal(data, &result))
+		_, exists := result.Items[1].Phases["ServiceReady"]
+		assert.Assert(t, exists)
+		_, exists = result.Phases["ServiceReady"]
+		assert.Assert(t, exists)
+
+		matches, err = filepath.Glob(filepath.Join(output, "*_"+TimelineOutputFilename+".csv"))
+		assert.NilError(t, err)
+		data, err = os.ReadFile(matches[0])
+		assert.NilError(t, err)
+		// the header, then the creation and readiness of each Knative Service
+		assert.Equal(t, 5, strings.Count(string(data), "\n"))
+	})
 }
@@ -0,0 +1,205 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"knative.dev/kperf/pkg"
+)
+
+const (
+	HerdOutputFilename = "ksvc_herd_scaling_time"
+
+	// maxHerdServices is the maximum number of services of a herd, each armed service holding a goroutine, a watch of
+	// its Deployment and a prepared request until the release
+	maxHerdServices = 500
+)
+
+// herdRun is the scale from zero of a service in a herd iteration
+type herdRun struct {
+	wait       time.Duration
+	cold       bool
+	start, end time.Time
+	sdur, ddur time.Duration
+	err        error
+}
+
+// forEachService runs fn for the index of each service with at most concurrency at a time
+func forEachService(count, concurrency int, fn func(i int)) {
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// herdScaleAndMeasure scales all the services from zero at the same instant in each iteration, to measure the
+// activator and the autoscaler under a storm of cold starts. The services are first confirmed at zero, the services
+// still not at zero after the timeout being warm, then the request to each service is prepared, with at most
+// concurrency preparations at a time, and held by a barrier until all of them are ready to be sent, and released at
+// once. The number of services is bounded by maxHerdServices
+func herdScaleAndMeasure(ctx context.Context, params *pkg.PerfParams, inputs pkg.ScaleArgs, objs []ServicesToScale) ([]pkg.HerdIterationResult, []pkg.ScaleFromZeroResult) {
+	herd := []pkg.HerdIterationResult{}
+	iterations := make([][]pkg.ScaleIterationResult, len(objs))
+	for j := 0; j < inputs.Iterations; j++ {
+		if ctx.Err() != nil {
+			fmt.Printf("herd scale interrupted\n")
+			break
+		}
+		runs := make([]herdRun, len(objs))
+		// a service which cannot be watched is left out of the iteration
+		forEachService(len(objs), inputs.Concurrency, func(i int) {
+			runs[i].wait, runs[i].cold, runs[i].err = waitScaledToZero(ctx, params, objs[i].Namespace, objs[i].Service.Name, inputs.ScaleToZeroTimeout)
+			if runs[i].err != nil {
+				fmt.Printf("failed to wait for service %s/%s to scale to zero: %s\n", objs[i].Namespace, objs[i].Service.Name, runs[i].err)
+			} else if !runs[i].cold {
+				fmt.Printf("service %s/%s is not scaled to zero after %s, it is warm in iteration %d\n",
+					objs[i].Namespace, objs[i].Service.Name, inputs.ScaleToZeroTimeout, j)
+			}
+		})
+
+		var armed, done sync.WaitGroup
+		release := make(chan struct{})
+		preparing := make(chan struct{}, inputs.Concurrency)
+		for i := range objs {
+			if runs[i].err != nil {
+				continue
+			}
+			armed.Add(1)
+			done.Add(1)
+			go func(i int) {
+				defer done.Done()
+				preparing <- struct{}{}
+				scale, err := prepareScaleFromZero(ctx, params, inputs, objs[i].Namespace, objs[i].Service)
+				<-preparing
+				armed.Done()
+				if err != nil {
+					runs[i].err = err
+					return
+				}
+				defer scale.stop()
+				<-release
+				runs[i].start = time.Now()
+				runs[i].sdur, runs[i].ddur, runs[i].err = scale.run(inputs)
+				runs[i].end = time.Now()
+			}(i)
+		}
+		armed.Wait()
+		released := time.Now()
+		close(release)
+		done.Wait()
+
+		fmt.Printf("====================== herd iteration %d result =====================\n", j)
+		result := pkg.HerdIterationResult{Iteration: j, Released: released}
+		var svcLatencyList, dpLatencyList []float64
+		for i, run := range runs {
+			if run.start.IsZero() && run.err != nil {
+				continue
+			}
+			result.Services++
+			if skew := run.start.Sub(released).Seconds(); skew > result.ReleaseSkew {
+				result.ReleaseSkew = skew
+			}
+			if run.err != nil {
+				result.Failed++
+				fmt.Printf("failed to scale service %s/%s from zero: %s\n", objs[i].Namespace, objs[i].Service.Name, run.err)
+				continue
+			}
+			iteration := pkg.ScaleIterationResult{Iteration: j, Cold: run.cold, ScaleToZeroWait: run.wait.Seconds(),
+				ServiceLatency: run.sdur.Seconds(), DeploymentLatency: run.ddur.Seconds()}
+			var err error
+			iteration.PodName, iteration.PodStartup, err = scaledPodStartup(ctx, params, objs[i].Namespace, objs[i].Service.Name, run.start)
+			if err != nil {
+				fmt.Printf("failed to get the startup of the pod: %s\n", err)
+			}
+			iterations[i] = append(iterations[i], iteration)
+			if !run.cold {
+				result.Warm++
+				continue
+			}
+			result.Cold++
+			svcLatencyList = append(svcLatencyList, run.sdur.Seconds())
+			dpLatencyList = append(dpLatencyList, run.ddur.Seconds())
+			if lastReady := run.end.Sub(released).Seconds(); lastReady > result.LastReady {
+				result.LastReady = lastReady
+			}
+		}
+		fmt.Printf("%d services released at once: %d cold, %d warm, %d failed\n", result.Services, result.Cold, result.Warm, result.Failed)
+		fmt.Printf("service latency result:\n")
+		result.ServiceLatency = latencyResultHandler(svcLatencyList)
+		fmt.Printf("deployment latency result:\n")
+		result.DeploymentLatency = latencyResultHandler(dpLatencyList)
+		if len(svcLatencyList) > 0 {
+			result.Spread = result.ServiceLatency.Max - result.ServiceLatency.Min
+		}
+		fmt.Printf("spread of the cold starts: %f s, last service ready after %f s\n", result.Spread, result.LastReady)
+		herd = append(herd, result)
+	}
+
+	measurements := []pkg.ScaleFromZeroResult{}
+	for i, obj := range objs {
+		var svcLatencyList, dpLatencyList []float64
+		for _, iteration := range iterations[i] {
+			if iteration.Cold {
+				svcLatencyList = append(svcLatencyList, iteration.ServiceLatency)
+				dpLatencyList = append(dpLatencyList, iteration.DeploymentLatency)
+			}
+		}
+		fmt.Printf("====================== service %s/%s result =====================\n", obj.Namespace, obj.Service.Name)
+		fmt.Printf("service latency result:\n")
+		svcLatencyResult := latencyResultHandler(svcLatencyList)
+		fmt.Printf("deployment latency result:\n")
+		dpLatencyResult := latencyResultHandler(dpLatencyList)
+		measurements = append(measurements, pkg.ScaleFromZeroResult{
+			ServiceName:       obj.Service.Name,
+			ServiceNamespace:  obj.Namespace,
+			ServiceLatency:    svcLatencyResult,
+			DeploymentLatency: dpLatencyResult,
+			Iterations:        iterations[i],
+			ColdIterations:    len(svcLatencyList),
+			WarmIterations:    len(iterations[i]) - len(svcLatencyList),
+		})
+	}
+	return herd, measurements
+}
+
+// herdRows returns the CSV rows of the herd iterations
+func herdRows(herd []pkg.HerdIterationResult) [][]string {
+	rows := [][]string{{"iteration", "services", "cold", "warm", "failed", "release_skew",
+		"svc_latency_avg", "svc_latency_min", "svc_latency_max",
+		"svc_latency_p50", "svc_latency_p90", "svc_latency_p95", "svc_latency_p99",
+		"deployment_latency_avg", "deployment_latency_max", "spread", "last_ready"}}
+	for _, h := range herd {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", h.Iteration), fmt.Sprintf("%d", h.Services), fmt.Sprintf("%d", h.Cold), fmt.Sprintf("%d", h.Warm),
+			fmt.Sprintf("%d", h.Failed), formatSeconds(h.ReleaseSkew),
+			formatSeconds(h.ServiceLatency.Average), formatSeconds(h.ServiceLatency.Min), formatSeconds(h.ServiceLatency.Max),
+			formatSeconds(h.ServiceLatency.P50), formatSeconds(h.ServiceLatency.P90), formatSeconds(h.ServiceLatency.P95), formatSeconds(h.ServiceLatency.P99),
+			formatSeconds(h.DeploymentLatency.Average), formatSeconds(h.DeploymentLatency.Max),
+			formatSeconds(h.Spread), formatSeconds(h.LastReady)})
+	}
+	return rows
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"knative.dev/pkg/apis"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"knative.dev/kperf/pkg"
+)
+
+func TestHerdScaleAndMeasure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	service := func(name, url string) ServicesToScale {
+		svc := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns-1"}}
+		svc.Status.URL, _ = apis.ParseURL(url)
+		return ServicesToScale{Namespace: "ns-1", Service: svc}
+	}
+	// ksvc-3 still has a pod so it is warm, ksvc-4 cannot be reached
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-3-pod", Namespace: "ns-1",
+		Labels: map[string]string{serving.ServiceLabelKey: "ksvc-3"}}}
+	p := zeroParams(k8sfake.NewSimpleClientset(pod))
+	objs := []ServicesToScale{
+		service("ksvc-1", server.URL),
+		service("ksvc-2", server.URL),
+		service("ksvc-3", server.URL),
+		service("ksvc-4", closed.URL),
+	}
+	inputs := pkg.ScaleArgs{
+		Iterations:         2,
+		Concurrency:        2,
+		ResolvableDomain:   true,
+		ScaleToZeroTimeout: 50 * time.Millisecond,
+		RequestInterval:    10 * time.Millisecond,
+		RequestTimeout:     time.Second,
+	}
+
+	herd, measurements := herdScaleAndMeasure(context.Background(), p, inputs, objs)
+	assert.Equal(t, 2, len(herd))
+	for j, h := range herd {
+		assert.Equal(t, j, h.Iteration)
+		assert.Equal(t, 4, h.Services)
+		assert.Equal(t, 2, h.Cold)
+		assert.Equal(t, 1, h.Warm)
+		assert.Equal(t, 1, h.Failed)
+		assert.Assert(t, h.ServiceLatency.Min >= 0.02)
+		assert.Equal(t, h.ServiceLatency.Max-h.ServiceLatency.Min, h.Spread)
+		assert.Assert(t, h.LastReady >= h.ServiceLatency.Max)
+	}
+
+	assert.Equal(t, 4, len(measurements))
+	assert.Equal(t, 2, measurements[0].ColdIterations)
+	assert.Equal(t, 2, len(measurements[0].Iterations))
+	assert.Equal(t, 0, measurements[2].ColdIterations)
+	assert.Equal(t, 2, measurements[2].WarmIterations)
+	assert.Equal(t, 0, len(measurements[3].Iterations))
+
+	rows := herdRows(herd)
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, len(rows[0]), len(rows[1]))
+}
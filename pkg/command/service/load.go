@@ -43,9 +43,9 @@ import (
 
 	"knative.dev/kperf/pkg"
 	"knative.dev/kperf/pkg/config"
+	"knative.dev/kperf/pkg/target"
 	"knative.dev/serving/pkg/apis/serving"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
-	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
 )
 
 const (
@@ -75,11 +75,12 @@ kperf service load --namespace ktest --svc-prefix ktest --range 0,3 --load-tool
 	}
 
 	serviceLoadCommand.Flags().StringVarP(&loadArgs.Namespace, "namespace", "", "", "Service namespace")
-	serviceLoadCommand.Flags().StringVarP(&loadArgs.NamespaceRange, "namespace-range", "", "", "Service namespace range")
+	serviceLoadCommand.Flags().StringVarP(&loadArgs.NamespaceRange, "namespace-range", "", "", "Service namespace range like 1,500 or 1-5,8,20-30")
 	serviceLoadCommand.Flags().StringVarP(&loadArgs.NamespacePrefix, "namespace-prefix", "", "", "Service namespace prefix")
 	serviceLoadCommand.Flags().StringVarP(&loadArgs.Svc, "svc", "", "", "Service name")
 	serviceLoadCommand.Flags().StringVarP(&loadArgs.SvcPrefix, "svc-prefix", "", "", "Service name prefix")
-	serviceLoadCommand.Flags().StringVarP(&loadArgs.SvcRange, "range", "r", "", "Desired service range")
+	serviceLoadCommand.Flags().StringVarP(&loadArgs.RunID, "run-id", "", "", "Only select the services created by the kperf run with this ID, svc and svc-prefix are optional if set")
+	serviceLoadCommand.Flags().StringVarP(&loadArgs.SvcRange, "range", "r", "", "Desired service range like 1,500 or 1-5,8,20-30")
 	serviceLoadCommand.Flags().BoolVarP(&loadArgs.Verbose, "verbose", "v", false, "Service verbose result")
 	serviceLoadCommand.Flags().BoolVarP(&loadArgs.ResolvableDomain, "resolvable", "", false, "If Service endpoint resolvable url")
 	serviceLoadCommand.Flags().DurationVarP(&loadArgs.WaitPodsReadyDuration, "wait-time", "w", 10*time.Second, "Time to wait for all pods to be ready")
@@ -88,13 +89,17 @@ kperf service load --namespace ktest --svc-prefix ktest --range 0,3 --load-tool
 	serviceLoadCommand.Flags().StringVarP(&loadArgs.LoadDuration, "load-duration", "d", "60s", "Duration of the test for the load test tool")
 	serviceLoadCommand.Flags().StringVarP(&loadArgs.Output, "output", "o", ".", "Measure result location")
 	serviceLoadCommand.Flags().BoolVarP(&loadArgs.Https, "https", "", false, "Use https with TLS")
+	serviceLoadCommand.Flags().IntVarP(&loadArgs.Concurrency, "concurrency", "", 10, "Number of services loaded at the same time")
+	serviceLoadCommand.Flags().DurationVarP(&loadArgs.Stagger, "stagger", "", 0, "Minimum time between the starts of two services")
 
+	addTargetFlags(serviceLoadCommand, &loadArgs.Selector, &loadArgs.SvcRegex, &loadArgs.NamespaceSelector, &loadArgs.NamespaceRegex)
 	return serviceLoadCommand
 }
 
 func LoadServicesUpFromZero(params *pkg.PerfParams, inputs pkg.LoadArgs) error {
 	ctx := context.Background()
-	nsNameList, err := GetNamespaces(ctx, params, inputs.Namespace, inputs.NamespaceRange, inputs.NamespacePrefix)
+	nsNameList, err := target.Namespaces{Name: inputs.Namespace, Prefix: inputs.NamespacePrefix, Range: inputs.NamespaceRange,
+		Selector: inputs.NamespaceSelector, Regex: inputs.NamespaceRegex}.Resolve(ctx, params.ClientSet)
 	if err != nil {
 		return err
 	}
@@ -120,7 +125,7 @@ func LoadServicesUpFromZero(params *pkg.PerfParams, inputs pkg.LoadArgs) error {
 		row = append(row, loadFromZeroResult.Measurment[i].ServiceName, loadFromZeroResult.Measurment[i].ServiceNamespace)
 		for j := 0; j < len(loadFromZeroResult.Measurment[i].ReplicaResults); j++ {
 			if loadFromZeroResult.Measurment[i].ReplicaResults[j].ReadyReplicasCount <= replicasCountList[i] {
-				row = append(row, strconv.FormatFloat(loadFromZeroResult.Measurment[i].ReplicaResults[j].ReplicaReadyDuration, 'f', 3, 32))
+				row = append(row, formatSeconds(loadFromZeroResult.Measurment[i].ReplicaResults[j].ReplicaReadyDuration))
 			}
 		}
 		rows = append(rows, row)
@@ -146,51 +151,48 @@ func LoadServicesUpFromZero(params *pkg.PerfParams, inputs pkg.LoadArgs) error {
 	return nil
 }
 
-func loadAndMeasure(ctx context.Context, params *pkg.PerfParams, inputs pkg.LoadArgs, nsNameList []string, servicesListFunc func(context.Context, servingv1client.ServingV1Interface, []string, string, string, string) ([]ServicesToScale, error)) (pkg.LoadResult, error) {
+func loadAndMeasure(ctx context.Context, params *pkg.PerfParams, inputs pkg.LoadArgs, nsNameList []string, listServices servicesListFunc) (pkg.LoadResult, error) {
 	result := pkg.LoadResult{}
+	if inputs.Concurrency <= 0 {
+		return result, fmt.Errorf("concurrency must be positive, given %d", inputs.Concurrency)
+	}
 	ksvcClient, err := params.NewServingClient()
 	if err != nil {
 		return result, err
 	}
-	objs, err := servicesListFunc(ctx, ksvcClient, nsNameList, inputs.SvcPrefix, inputs.SvcRange, inputs.Svc)
+	objs, err := listServices(ctx, ksvcClient, nsNameList, target.Services{Name: inputs.Svc, Prefix: inputs.SvcPrefix, Range: inputs.SvcRange,
+		Selector: inputs.Selector, Regex: inputs.SvcRegex, RunID: inputs.RunID})
 	if err != nil {
 		return result, err
 	}
-	count := len(objs)
-
-	var wg sync.WaitGroup
+	// the services are loaded by a bounded pool of workers, not all at once
 	var m sync.Mutex
-	wg.Add(count)
-	for i := 0; i < count; i++ {
-		go func(ndx int, m *sync.Mutex) {
-			defer wg.Done()
-			loadToolOutput, loadResult, err := runLoadFromZero(ctx, params, inputs, objs[ndx].Namespace, objs[ndx].Service)
-			if err == nil {
-				// print result(load test tool output, replicas result, pods result)
-				if inputs.Verbose {
-					fmt.Printf("\n[Verbose] Namespace %s, Service %s:\n", loadResult.ServiceNamespace, loadResult.ServiceName)
-					fmt.Printf("\n[Verbose] Load tool(%s) output:\n%s\n", inputs.LoadTool, loadToolOutput)
-					fmt.Printf("[Verbose] Deployment replicas changed from 0 to %d:\n", len(loadResult.ReplicaResults))
-					fmt.Printf("replicas\tready_duration(seconds)\n")
-					for i := 0; i < len(loadResult.ReplicaResults); i++ {
-						fmt.Printf("%8d\t%23.3f\n", i, loadResult.ReplicaResults[i].ReplicaReadyDuration)
-					}
-					fmt.Printf("\n[Verbose] Pods changed from 0 to %d:\n", len(loadResult.PodResults))
-					fmt.Printf("pods\tready_duration(seconds)\n")
-					for i := 0; i < len(loadResult.PodResults); i++ {
-						fmt.Printf("%4d\t%23.1f\n", i, loadResult.PodResults[i].PodReadyDuration)
-					}
-					fmt.Printf("\n---------------------------------------------------------------------------------\n")
+	result.Schedule = runServicePool(ctx, objs, inputs.Concurrency, inputs.Stagger, func(obj ServicesToScale) {
+		loadToolOutput, loadResult, err := runLoadFromZero(ctx, params, inputs, obj.Namespace, obj.Service)
+		if err == nil {
+			// print result(load test tool output, replicas result, pods result)
+			if inputs.Verbose {
+				fmt.Printf("\n[Verbose] Namespace %s, Service %s:\n", loadResult.ServiceNamespace, loadResult.ServiceName)
+				fmt.Printf("\n[Verbose] Load tool(%s) output:\n%s\n", inputs.LoadTool, loadToolOutput)
+				fmt.Printf("[Verbose] Deployment replicas changed from 0 to %d:\n", len(loadResult.ReplicaResults))
+				fmt.Printf("replicas\tready_duration(seconds)\n")
+				for i := 0; i < len(loadResult.ReplicaResults); i++ {
+					fmt.Printf("%8d\t%23.3f\n", i, loadResult.ReplicaResults[i].ReplicaReadyDuration)
 				}
-				m.Lock()
-				result.Measurment = append(result.Measurment, loadResult)
-				m.Unlock()
-			} else {
-				fmt.Printf("failed in runLoadFromZero: %s\n", err)
+				fmt.Printf("\n[Verbose] Pods changed from 0 to %d:\n", len(loadResult.PodResults))
+				fmt.Printf("pods\tready_duration(seconds)\n")
+				for i := 0; i < len(loadResult.PodResults); i++ {
+					fmt.Printf("%4d\t%23.1f\n", i, loadResult.PodResults[i].PodReadyDuration)
+				}
+				fmt.Printf("\n---------------------------------------------------------------------------------\n")
 			}
-		}(i, &m)
-	}
-	wg.Wait()
+			m.Lock()
+			result.Measurment = append(result.Measurment, loadResult)
+			m.Unlock()
+		} else {
+			fmt.Printf("failed in runLoadFromZero: %s\n", err)
+		}
+	})
 
 	return result, nil
 }
@@ -439,16 +441,16 @@ func getPodResults(ctx context.Context, params *pkg.PerfParams, namespace string
 	}
 	for i := 0; i < len(podList); i++ {
 		pod := podList[i]
-		podCreatedTime := pod.GetCreationTimestamp().Rfc3339Copy()
+		podCreatedTime := pod.GetCreationTimestamp()
 		present, PodReadyCondition := getPodCondition(&pod.Status, corev1.PodReady)
 		if present == -1 {
 			log.Println("failed to find Pod Condition PodReady and skip measuring")
 			continue
 		}
-		podReadyTime := PodReadyCondition.LastTransitionTime.Rfc3339Copy()
+		podReadyTime := PodReadyCondition.LastTransitionTime
 		podReadyDuration := podReadyTime.Sub(podCreatedTime.Time).Seconds()
-		r.PodCreateTime = podCreatedTime
-		r.PodReadyTime = podReadyTime
+		r.PodCreateTime = podCreatedTime.Time
+		r.PodReadyTime = podReadyTime.Time
 		r.PodReadyDuration = podReadyDuration
 		podResults = append(podResults, r)
 	}
@@ -93,8 +93,8 @@ func TestNewServiceLoadCommand(t *testing.T) {
 		_, err := testutil.ExecuteCommand(cmd)
 		assert.ErrorContains(t, err, "both namespace and namespace-prefix are empty")
 
-		_, err = testutil.ExecuteCommand(cmd, "--namespace-prefix", FakeNamespace, "--namespace-range", "1200")
-		assert.ErrorContains(t, err, "expected range like 1,500, given 1200")
+		_, err = testutil.ExecuteCommand(cmd, "--namespace-prefix", FakeNamespace, "--namespace-range", "1200-")
+		assert.ErrorContains(t, err, "expected range like 1,500, given 1200-")
 
 		_, err = testutil.ExecuteCommand(cmd, "--namespace-prefix", "ns-1", "--namespace-range", "1,2")
 		assert.ErrorContains(t, err, "no namespace found with prefix ns-1")
@@ -448,12 +448,8 @@ func TestGetPodResults(t *testing.T) {
 			args: fakeArgs,
 			want: []pkg.LoadPodResult{
 				{
-					PodCreateTime: metav1.Time{
-						Time: createTime,
-					}.Rfc3339Copy(),
-					PodReadyTime: metav1.Time{
-						Time: readyTime,
-					}.Rfc3339Copy(),
+					PodCreateTime:    createTime,
+					PodReadyTime:     readyTime,
 					PodReadyDuration: readyDuration.Seconds(),
 				},
 			},
@@ -585,12 +581,8 @@ func TestSetLoadFromZeroResult(t *testing.T) {
 	}
 	fakeLoadPodResult := []pkg.LoadPodResult{
 		{
-			PodCreateTime: metav1.Time{
-				Time: createTime,
-			}.Rfc3339Copy(),
-			PodReadyTime: metav1.Time{
-				Time: readyTime,
-			}.Rfc3339Copy(),
+			PodCreateTime:    createTime,
+			PodReadyTime:     readyTime,
 			PodReadyDuration: readyDuration.Seconds(),
 		},
 	}
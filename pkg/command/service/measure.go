@@ -37,19 +37,65 @@ import (
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 
 	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/target"
 )
 
 const (
-	DateFormatString         = "20060102150405"
-	RawMeasureOutputFilename = "raw_ksvc_creation_time"
-	MeasureOutputFilename    = "ksvc_creation_time"
+	DateFormatString             = "20060102150405"
+	RawMeasureOutputFilename     = "raw_ksvc_creation_time"
+	MeasureOutputFilename        = "ksvc_creation_time"
+	SummaryMeasureOutputFilename = "ksvc_creation_time_summary"
 )
 
+// measurePhaseNames are the names of the phases of the creation lifecycle, in the order of the columns
+var measurePhaseNames = []string{
+	"configuration_ready",
+	"revision_ready",
+	"deployment_created",
+	"pod_scheduled",
+	"containers_ready",
+	"queue-proxy_started",
+	"user-container_started",
+	"route_ready",
+	"kpa_active",
+	"sks_ready",
+	"sks_activator_endpoints_populated",
+	"sks_endpoints_populated",
+	"ingress_ready",
+	"ingress_config_ready",
+	"ingress_lb_ready",
+	"overall_ready",
+}
+
+// summaryPhaseNames are the names of the phases of the summary, the TLS phases being measured for some services only
+var summaryPhaseNames = append(append([]string{}, measurePhaseNames...), tlsPhaseNames...)
+
+// rawTimestampNames are the names of the timestamps of the raw measurement, in the order of the columns
+var rawTimestampNames = []string{
+	"svc_created",
+	"configuration_ready",
+	"revision_created",
+	"revision_ready",
+	"deployment_created",
+	"pod_created",
+	"pod_scheduled",
+	"containers_ready",
+	"queue-proxy_started",
+	"user-container_started",
+	"route_ready",
+	"kpa_created",
+	"kpa_active",
+	"sks_created",
+	"sks_activator_endpoints_populated",
+	"sks_endpoints_populated",
+	"ingress_created",
+	"ingress_config_ready",
+	"ingress_lb_ready",
+}
+
 type MeasureServicesOptions struct {
-	NamespaceChanged       bool
-	NamespaceRangeChanged  bool
-	NamespacePrefixChanged bool
-	VerboseChanged         bool
+	NamespaceChanged bool
+	VerboseChanged   bool
 }
 
 func NewServiceMeasureCommand(p *pkg.PerfParams) *cobra.Command {
@@ -62,6 +108,12 @@ func NewServiceMeasureCommand(p *pkg.PerfParams) *cobra.Command {
 For example:
 # To measure a Knative Service creation time running currently with 20 concurent jobs
 kperf service measure --svc-perfix svc --range 1,200 --namespace ns --concurrency 20
+
+# To measure the creation time of the Knative Services generated by the run 20230101120000-abcde
+kperf service measure --namespace ns --run-id 20230101120000-abcde
+
+# To measure as well the readiness of the auto-TLS Certificates and the DomainMappings of the Knative Services
+kperf service measure --namespace ns --run-id 20230101120000-abcde --tls
 `,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			if cmd.Flags().NFlag() == 0 {
@@ -71,23 +123,24 @@ kperf service measure --svc-perfix svc --range 1,200 --namespace ns --concurrenc
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			options := MeasureServicesOptions{
-				NamespaceChanged:       cmd.Flags().Changed("namespace"),
-				NamespaceRangeChanged:  cmd.Flags().Changed("namespace-range"),
-				NamespacePrefixChanged: cmd.Flags().Changed("namespace-prefix"),
-				VerboseChanged:         cmd.Flags().Changed("verbose"),
+				NamespaceChanged: cmd.Flags().Changed("namespace"),
+				VerboseChanged:   cmd.Flags().Changed("verbose"),
 			}
 			return MeasureServices(p, measureArgs, options)
 		},
 	}
 
-	serviceMeasureCommand.Flags().StringVarP(&measureArgs.SvcRange, "range", "r", "", "Desired service range")
+	serviceMeasureCommand.Flags().StringVarP(&measureArgs.SvcRange, "range", "r", "", "Desired service range like 1,500 or 1-5,8,20-30")
 	serviceMeasureCommand.Flags().StringVarP(&measureArgs.Namespace, "namespace", "", "", "Service namespace")
 	serviceMeasureCommand.Flags().StringVarP(&measureArgs.SvcPrefix, "svc-prefix", "", "", "Service name prefix")
+	serviceMeasureCommand.Flags().StringVarP(&measureArgs.RunID, "run-id", "", "", "Only measure the services created by the kperf run with this ID, range is optional with namespace if set")
 	serviceMeasureCommand.Flags().BoolVarP(&measureArgs.Verbose, "verbose", "v", false, "Service verbose result")
-	serviceMeasureCommand.Flags().StringVarP(&measureArgs.NamespaceRange, "namespace-range", "", "", "Service namespace range")
+	serviceMeasureCommand.Flags().StringVarP(&measureArgs.NamespaceRange, "namespace-range", "", "", "Service namespace range like 1,500 or 1-5,8,20-30")
 	serviceMeasureCommand.Flags().StringVarP(&measureArgs.NamespacePrefix, "namespace-prefix", "", "", "Service namespace prefix")
 	serviceMeasureCommand.Flags().IntVarP(&measureArgs.Concurrency, "concurrency", "c", 10, "Number of workers to do measurement job")
 	serviceMeasureCommand.Flags().StringVarP(&measureArgs.Output, "output", "o", ".", "Measure result location")
+	serviceMeasureCommand.Flags().BoolVarP(&measureArgs.TLS, "tls", "", false, "Measure as well the readiness of the auto-TLS Certificates and the DomainMappings of the services")
+	addTargetFlags(serviceMeasureCommand, &measureArgs.Selector, &measureArgs.SvcRegex, &measureArgs.NamespaceSelector, &measureArgs.NamespaceRegex)
 	return serviceMeasureCommand
 }
 
@@ -96,26 +149,14 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 	var lock sync.Mutex
 	measureFinalResult := pkg.MeasureResult{}
 
-	svcNamespacedName := make([][]string, 0)
-	if options.NamespaceChanged {
-		r := strings.Split(inputs.SvcRange, ",")
-		if len(r) != 2 {
-			return fmt.Errorf("expected range like 1,500, given %s", inputs.SvcRange)
-		}
-
-		start, err := strconv.Atoi(r[0])
-		if err != nil {
-			return err
-		}
-		end, err := strconv.Atoi(r[1])
-		if err != nil {
-			return err
-		}
-
-		for i := start; i <= end; i++ {
-			sName := fmt.Sprintf("%s-%s", inputs.SvcPrefix, strconv.Itoa(i))
-			svcNamespacedName = append(svcNamespacedName, []string{sName, inputs.Namespace})
-		}
+	nsTarget := target.Namespaces{Name: inputs.Namespace, Prefix: inputs.NamespacePrefix, Range: inputs.NamespaceRange,
+		Selector: inputs.NamespaceSelector, Regex: inputs.NamespaceRegex}
+	svcTarget := target.Services{Prefix: inputs.SvcPrefix, Range: inputs.SvcRange, Selector: inputs.Selector,
+		Regex: inputs.SvcRegex, RunID: inputs.RunID}
+	// the criteria are checked before accessing the cluster
+	err := svcTarget.Validate()
+	if err != nil {
+		return err
 	}
 
 	autoscalingClient, err := params.NewAutoscalingClient()
@@ -127,46 +168,71 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 		return fmt.Errorf("failed to create serving client %s", err)
 	}
 
-	if options.NamespaceRangeChanged && options.NamespacePrefixChanged {
-		r := strings.Split(inputs.NamespaceRange, ",")
-		if len(r) != 2 {
-			return fmt.Errorf("expected namespace-range like 1,500, given %s", inputs.NamespaceRange)
-		}
-
-		start, err := strconv.Atoi(r[0])
+	nsNameList, err := nsTarget.Resolve(context.TODO(), params.ClientSet)
+	if err != nil {
+		return err
+	}
+	svcNamespacedName := make([][]string, 0)
+	// the services named by the range in the namespace are measured whether they exist or not, to report the
+	// missing ones
+	var names [][2]string
+	if options.NamespaceChanged {
+		names, err = svcTarget.Names(nsNameList)
 		if err != nil {
 			return err
 		}
-		end, err := strconv.Atoi(r[1])
+	}
+	if names == nil {
+		services, err := svcTarget.Resolve(context.TODO(), servingClient, nsNameList)
 		if err != nil {
 			return err
 		}
-		for i := start; i <= end; i++ {
-			svcNsName := fmt.Sprintf("%s-%s", inputs.NamespacePrefix, strconv.Itoa(i))
-			svcList, err := servingClient.Services(svcNsName).List(context.TODO(), metav1.ListOptions{})
-			if err != nil {
-				return fmt.Errorf("failed to list service under namespace %s error:%v", svcNsName, err)
-			}
-
-			if len(svcList.Items) > 0 {
-				for _, svc := range svcList.Items {
-					if strings.HasPrefix(svc.Name, inputs.SvcPrefix) {
-						svcNamespacedName = append(svcNamespacedName, []string{svc.Name, svcNsName})
-					}
-				}
-			} else {
-				fmt.Printf("no service found under namespace %s and skip\n", svcNsName)
-			}
+		if len(services) == 0 {
+			fmt.Printf("no service found %s in namespaces %v\n", svcTarget, nsNameList)
+		}
+		for _, svc := range services {
+			names = append(names, [2]string{svc.Namespace, svc.Name})
 		}
 	}
+	for _, name := range names {
+		svcNamespacedName = append(svcNamespacedName, []string{name[1], name[0]})
+	}
 
 	rows := make([][]string, 0)
 	rawRows := make([][]string, 0)
+	// timestampSources counts the source of each raw timestamp
+	timestampSources := map[string]map[string]int{}
+	// phases are the durations of each phase of the services in seconds
+	phases := map[string][]float64{}
+	// failures are the services which are not measured
+	failures := make([]pkg.ServiceFailure, 0)
+	addFailure := func(failure pkg.ServiceFailure) {
+		lock.Lock()
+		defer lock.Unlock()
+		failures = append(failures, failure)
+	}
+	// podRows has a row per container of each pod of the services
+	podRows := make([][]string, 0)
+	podMeasurements := make([]pkg.MeasurePodResult, 0)
+	// the durations for the first, median and last pod of each service to be ready
+	firstPodsReady, medianPodsReady, lastPodsReady := []float64{}, []float64{}, []float64{}
 
 	nwclient, err := params.NewNetworkingClient()
 	if err != nil {
 		return fmt.Errorf("failed to create networking client %s", err)
 	}
+	// tlsMeasurements are the Certificates and DomainMappings of the services, with a row per DomainMapping in tlsRows
+	tlsMeasurements := make([]pkg.MeasureTLSResult, 0)
+	tlsRowsList := make([][]string, 0)
+	// the Certificates and DomainMappings are listed once per namespace rather than for every service
+	var tlsObjects map[string]*namespaceTLS
+	if inputs.TLS {
+		dmClient, err := params.NewDomainMappingClient()
+		if err != nil {
+			return fmt.Errorf("failed to create domain mapping client %s", err)
+		}
+		tlsObjects = listNamespaceTLS(context.TODO(), nwclient, dmClient, nsNameList)
+	}
 
 	svcChannel := make(chan []string)
 	group := sync.WaitGroup{}
@@ -186,6 +252,7 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 			for j := range svcChannel {
 				if len(j) != 2 {
 					fmt.Printf("lack of service name or service namespace and skip")
+					addFailure(pkg.ServiceFailure{Status: FailureFail, Reason: "InvalidName", Message: strings.Join(j, "/")})
 					currentMeasureResult.Service.FailCount++
 					workerMeasureResults[index] = currentMeasureResult
 					group.Done()
@@ -197,12 +264,14 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 				if err != nil {
 					fmt.Printf("failed to get Knative Service %s\n", err)
 					if strings.Contains(err.Error(), "not found") {
+						addFailure(newServiceFailure(svc, svcNs, FailureNotFound, failureService, svc, err))
 						currentMeasureResult.Service.NotFoundCount++
 						workerMeasureResults[index] = currentMeasureResult
 						group.Done()
 						continue
 					} else {
 						currentMeasureResult.Service.FailCount++
+						addFailure(newServiceFailure(svc, svcNs, FailureFail, failureService, svc, err))
 						workerMeasureResults[index] = currentMeasureResult
 						group.Done()
 						continue
@@ -210,23 +279,25 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 				}
 				if !svcIns.IsReady() {
 					fmt.Printf("service %s/%s not ready and skip measuring\n", svc, svcNs)
+					addFailure(diagnoseService(context.TODO(), params, servingClient, autoscalingClient, nwclient, svcIns))
 					currentMeasureResult.Service.NotReadyCount++
 					workerMeasureResults[index] = currentMeasureResult
 					group.Done()
 					continue
 				}
 
-				svcCreatedTime := svcIns.GetCreationTimestamp().Rfc3339Copy()
-				svcConfigurationsReady := svcIns.Status.GetCondition(servingv1api.ServiceConditionConfigurationsReady).LastTransitionTime.Inner.Rfc3339Copy()
-				svcRoutesReady := svcIns.Status.GetCondition(servingv1api.ServiceConditionRoutesReady).LastTransitionTime.Inner.Rfc3339Copy()
+				svcCreatedTime := apiTime(svcIns.GetCreationTimestamp())
+				svcConfigurationsReady := conditionTime(svcIns.Status.GetCondition(servingv1api.ServiceConditionConfigurationsReady))
+				svcRoutesReady := conditionTime(svcIns.Status.GetCondition(servingv1api.ServiceConditionRoutesReady))
 
-				svcConfigurationsReadyDuration = svcConfigurationsReady.Sub(svcCreatedTime.Time)
-				svcRoutesReadyDuration = svcRoutesReady.Sub(svcCreatedTime.Time)
-				svcReadyDuration = svcRoutesReady.Sub(svcCreatedTime.Time)
+				svcConfigurationsReadyDuration = svcConfigurationsReady.Sub(svcCreatedTime)
+				svcRoutesReadyDuration = svcRoutesReady.Sub(svcCreatedTime)
+				svcReadyDuration = svcRoutesReady.Sub(svcCreatedTime)
 
 				cfgIns, err := servingClient.Configurations(svcNs).Get(context.TODO(), svc, metav1.GetOptions{})
 				if err != nil {
 					fmt.Printf("failed to get Configuration and skip measuring %s\n", err)
+					addFailure(newServiceFailure(svc, svcNs, FailureNotReady, failureConfiguration, svc, err))
 					currentMeasureResult.Service.NotReadyCount++
 					workerMeasureResults[index] = currentMeasureResult
 					group.Done()
@@ -237,20 +308,22 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 				revisionIns, err := servingClient.Revisions(svcNs).Get(context.TODO(), revisionName, metav1.GetOptions{})
 				if err != nil {
 					fmt.Printf("failed to get Revision and skip measuring %s\n", err)
+					addFailure(newServiceFailure(svc, svcNs, FailureNotReady, failureRevision, revisionName, err))
 					currentMeasureResult.Service.NotReadyCount++
 					workerMeasureResults[index] = currentMeasureResult
 					group.Done()
 					continue
 				}
 
-				revisionCreatedTime := revisionIns.GetCreationTimestamp().Rfc3339Copy()
-				revisionReadyTime := revisionIns.Status.GetCondition(v1.RevisionConditionReady).LastTransitionTime.Inner.Rfc3339Copy()
-				revisionReadyDuration := revisionReadyTime.Sub(revisionCreatedTime.Time)
+				revisionCreatedTime := apiTime(revisionIns.GetCreationTimestamp())
+				revisionReadyTime := conditionTime(revisionIns.Status.GetCondition(v1.RevisionConditionReady))
+				revisionReadyDuration := revisionReadyTime.Sub(revisionCreatedTime)
 
 				label := fmt.Sprintf("serving.knative.dev/revision=%s", revisionName)
 				podList, err := params.ClientSet.CoreV1().Pods(svcNs).List(context.TODO(), metav1.ListOptions{LabelSelector: label})
 				if err != nil {
 					fmt.Printf("list Pods of revision[%s] error :%v", revisionName, err)
+					addFailure(newServiceFailure(svc, svcNs, FailureNotReady, failurePod, revisionName, err))
 					currentMeasureResult.Service.NotReadyCount++
 					workerMeasureResults[index] = currentMeasureResult
 					group.Done()
@@ -261,150 +334,182 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 				deploymentIns, err := params.ClientSet.AppsV1().Deployments(svcNs).Get(context.TODO(), deploymentName, metav1.GetOptions{})
 				if err != nil {
 					fmt.Printf("failed to find deployment of revision[%s] error:%v", revisionName, err)
+					addFailure(newServiceFailure(svc, svcNs, FailureNotReady, failureDeployment, deploymentName, err))
 					currentMeasureResult.Service.NotReadyCount++
 					workerMeasureResults[index] = currentMeasureResult
 					group.Done()
 					continue
 				}
 
-				deploymentCreatedTime := deploymentIns.GetCreationTimestamp().Rfc3339Copy()
-				deploymentCreatedDuration := deploymentCreatedTime.Sub(revisionCreatedTime.Time)
+				deploymentCreatedTime := apiTime(deploymentIns.GetCreationTimestamp())
+				deploymentCreatedDuration := deploymentCreatedTime.Sub(revisionCreatedTime)
 
+				containerNames := revisionContainerNames(revisionIns)
+				pods := measureRevisionPods(context.TODO(), params, podList.Items, containerNames)
 				var podCreatedTime, podScheduledTime, containersReadyTime, queueProxyStartedTime,
-					userContrainerStartedTime metav1.Time
-				if len(podList.Items) > 0 {
-					pod := podList.Items[0]
-					podCreatedTime = pod.GetCreationTimestamp().Rfc3339Copy()
-					present, PodScheduledCdt := getPodCondition(&pod.Status, corev1.PodScheduled)
-					if present == -1 {
-						fmt.Printf("failed to find Pod Condition PodScheduled and skip measuring")
+					userContrainerStartedTime measuredTime
+				// the pod durations of the service are the ones of the first ready pod
+				if len(pods) > 0 {
+					pod := pods[0]
+					if !pod.ready() {
+						fmt.Printf("no ready Pod of revision[%s] and skip measuring\n", revisionName)
+						failure := pkg.ServiceFailure{ServiceName: svc, ServiceNamespace: svcNs, Status: FailureNotReady,
+							Resource: failurePod, ResourceName: pod.name, Reason: "NotReady"}
+						for k := range podList.Items {
+							if reason, message, failing := podFailure(&podList.Items[k]); failing {
+								failure.ResourceName, failure.Reason, failure.Message = podList.Items[k].Name, reason, message
+								break
+							}
+						}
+						addFailure(failure)
 						currentMeasureResult.Service.NotReadyCount++
 						workerMeasureResults[index] = currentMeasureResult
 						group.Done()
 						continue
 					}
-					podScheduledTime = PodScheduledCdt.LastTransitionTime.Rfc3339Copy()
-					present, containersReadyCdt := getPodCondition(&pod.Status, corev1.ContainersReady)
-					if present == -1 {
-						fmt.Printf("failed to find Pod Condition ContainersReady and skip measuring")
+					podCreatedTime = pod.created
+					podScheduledTime = pod.scheduled
+					containersReadyTime = pod.containersReady
+					queueProxyStartedTime = pod.lastStarted([]string{queueProxyContainerName})
+					// the containers of the revision are started once the last of them is
+					userContrainerStartedTime = pod.lastStarted(containerNames)
+					if queueProxyStartedTime.Time.IsZero() || userContrainerStartedTime.Time.IsZero() {
+						fmt.Printf("failed to get the container statuses of Pod %s and skip measuring\n", pod.name)
+						addFailure(pkg.ServiceFailure{ServiceName: svc, ServiceNamespace: svcNs, Status: FailureNotReady,
+							Resource: failurePod, ResourceName: pod.name, Reason: "ContainerStatusMissing"})
 						currentMeasureResult.Service.NotReadyCount++
 						workerMeasureResults[index] = currentMeasureResult
 						group.Done()
 						continue
 					}
-					containersReadyTime = containersReadyCdt.LastTransitionTime.Rfc3339Copy()
-					podScheduledDuration = podScheduledTime.Sub(podCreatedTime.Time)
-					containersReadyDuration = containersReadyTime.Sub(podCreatedTime.Time)
-
-					queueProxyStatus, found := getContainerStatus(pod.Status.ContainerStatuses, "queue-proxy")
-					if !found {
-						fmt.Printf("failed to get queue-proxy container status and skip, error:%v", err)
-						currentMeasureResult.Service.NotReadyCount++
-						workerMeasureResults[index] = currentMeasureResult
-						group.Done()
-						continue
-					}
-					queueProxyStartedTime = queueProxyStatus.State.Running.StartedAt.Rfc3339Copy()
-
-					userContrainerStatus, found := getContainerStatus(pod.Status.ContainerStatuses, "user-container")
-					if !found {
-						fmt.Printf("failed to get user-container container status and skip, error:%v", err)
-						currentMeasureResult.Service.NotReadyCount++
-						workerMeasureResults[index] = currentMeasureResult
-						group.Done()
-						continue
-					}
-					userContrainerStartedTime = userContrainerStatus.State.Running.StartedAt.Rfc3339Copy()
-
-					queueProxyStartedDuration = queueProxyStartedTime.Sub(podCreatedTime.Time)
-					userContrainerStartedDuration = userContrainerStartedTime.Sub(podCreatedTime.Time)
+					podScheduledDuration = podScheduledTime.Sub(podCreatedTime)
+					containersReadyDuration = containersReadyTime.Sub(podCreatedTime)
+					queueProxyStartedDuration = queueProxyStartedTime.Sub(podCreatedTime)
+					userContrainerStartedDuration = userContrainerStartedTime.Sub(podCreatedTime)
 				}
+				firstPodReady, medianPodReady, lastPodReady, podReady := podReadyDurations(pods, revisionCreatedTime)
+				svcPodResults, svcPodRows := podResults(svc, svcNs, pods, revisionCreatedTime)
 				// TODO: Need to figure out a better way to measure PA time as its status keeps changing even after service creation.
 
 				kpaIns, err := autoscalingClient.PodAutoscalers(svcNs).Get(context.TODO(), revisionName, metav1.GetOptions{})
 				if err != nil {
 					fmt.Printf("failed to get PodAutoscaler %s\n", err)
+					addFailure(newServiceFailure(svc, svcNs, FailureNotReady, failurePodAutoscaler, revisionName, err))
 					currentMeasureResult.Service.NotReadyCount++
 					workerMeasureResults[index] = currentMeasureResult
 					group.Done()
 					continue
 				}
-				kpaCreatedTime := kpaIns.GetCreationTimestamp().Rfc3339Copy()
-				kpaActiveTime := kpaIns.Status.GetCondition(autoscalingv1api.PodAutoscalerConditionActive).LastTransitionTime.Inner.Rfc3339Copy()
-				kpaActiveDuration := kpaActiveTime.Sub(kpaCreatedTime.Time)
+				kpaCreatedTime := apiTime(kpaIns.GetCreationTimestamp())
+				kpaActiveTime := conditionTime(kpaIns.Status.GetCondition(autoscalingv1api.PodAutoscalerConditionActive))
+				kpaActiveDuration := kpaActiveTime.Sub(kpaCreatedTime)
 
 				sksIns, err := nwclient.ServerlessServices(svcNs).Get(context.TODO(), revisionName, metav1.GetOptions{})
 				if err != nil {
 					fmt.Printf("failed to get ServerlessService %s\n", err)
+					addFailure(newServiceFailure(svc, svcNs, FailureNotReady, failureServerlessService, revisionName, err))
 					currentMeasureResult.Service.NotReadyCount++
 					workerMeasureResults[index] = currentMeasureResult
 					group.Done()
 					continue
 				}
-				sksCreatedTime := sksIns.GetCreationTimestamp().Rfc3339Copy()
-				sksActivatorEndpointsPopulatedTime := sksIns.Status.GetCondition(networkingv1api.ActivatorEndpointsPopulated).LastTransitionTime.Inner.Rfc3339Copy()
-				sksEndpointsPopulatedTime := sksIns.Status.GetCondition(networkingv1api.ServerlessServiceConditionEndspointsPopulated).LastTransitionTime.Inner.Rfc3339Copy()
-				sksReadyTime := sksIns.Status.GetCondition(networkingv1api.ServerlessServiceConditionReady).LastTransitionTime.Inner.Rfc3339Copy()
-				sksActivatorEndpointsPopulatedDuration := sksActivatorEndpointsPopulatedTime.Sub(sksCreatedTime.Time)
-				sksEndpointsPopulatedDuration := sksEndpointsPopulatedTime.Sub(sksCreatedTime.Time)
-				sksReadyDuration := sksReadyTime.Sub(sksCreatedTime.Time)
+				sksCreatedTime := apiTime(sksIns.GetCreationTimestamp())
+				sksActivatorEndpointsPopulatedTime := conditionTime(sksIns.Status.GetCondition(networkingv1api.ActivatorEndpointsPopulated))
+				sksEndpointsPopulatedTime := conditionTime(sksIns.Status.GetCondition(networkingv1api.ServerlessServiceConditionEndspointsPopulated))
+				sksReadyTime := conditionTime(sksIns.Status.GetCondition(networkingv1api.ServerlessServiceConditionReady))
+				sksActivatorEndpointsPopulatedDuration := sksActivatorEndpointsPopulatedTime.Sub(sksCreatedTime)
+				sksEndpointsPopulatedDuration := sksEndpointsPopulatedTime.Sub(sksCreatedTime)
+				sksReadyDuration := sksReadyTime.Sub(sksCreatedTime)
 
 				ingressIns, err := nwclient.Ingresses(svcNs).Get(context.TODO(), svc, metav1.GetOptions{})
 				if err != nil {
 					fmt.Printf("failed to get Ingress %s\n", err)
+					addFailure(newServiceFailure(svc, svcNs, FailureNotReady, failureIngress, svc, err))
 					currentMeasureResult.Service.NotReadyCount++
 					workerMeasureResults[index] = currentMeasureResult
 					group.Done()
 					continue
 				}
-				ingressCreatedTime := ingressIns.GetCreationTimestamp().Rfc3339Copy()
-				ingressNetworkConfiguredTime := ingressIns.Status.GetCondition(networkingv1api.IngressConditionNetworkConfigured).LastTransitionTime.Inner.Rfc3339Copy()
-				ingressLoadBalancerReadyTime := ingressIns.Status.GetCondition(networkingv1api.IngressConditionLoadBalancerReady).LastTransitionTime.Inner.Rfc3339Copy()
-				ingressNetworkConfiguredDuration := ingressNetworkConfiguredTime.Sub(ingressCreatedTime.Time)
-				ingressLoadBalancerReadyDuration := ingressLoadBalancerReadyTime.Sub(ingressNetworkConfiguredTime.Time)
-				ingressReadyDuration := ingressLoadBalancerReadyTime.Sub(ingressCreatedTime.Time)
+				ingressCreatedTime := apiTime(ingressIns.GetCreationTimestamp())
+				ingressNetworkConfiguredTime := conditionTime(ingressIns.Status.GetCondition(networkingv1api.IngressConditionNetworkConfigured))
+				ingressLoadBalancerReadyTime := conditionTime(ingressIns.Status.GetCondition(networkingv1api.IngressConditionLoadBalancerReady))
+				ingressNetworkConfiguredDuration := ingressNetworkConfiguredTime.Sub(ingressCreatedTime)
+				ingressLoadBalancerReadyDuration := ingressLoadBalancerReadyTime.Sub(ingressNetworkConfiguredTime)
+				ingressReadyDuration := ingressLoadBalancerReadyTime.Sub(ingressCreatedTime)
+
+				var tlsResult *pkg.MeasureTLSResult
+				if nsTLS, ok := tlsObjects[svcNs]; ok {
+					tlsResult = measureServiceTLS(nsTLS, svcIns)
+				}
 
 				lock.Lock()
 				currentMeasureResult.Service.ReadyCount++
-				rows = append(rows, []string{svc, svcNs,
-					fmt.Sprintf("%d", int(svcConfigurationsReadyDuration.Seconds())),
-					fmt.Sprintf("%d", int(revisionReadyDuration.Seconds())),
-					fmt.Sprintf("%d", int(deploymentCreatedDuration.Seconds())),
-					fmt.Sprintf("%d", int(podScheduledDuration.Seconds())),
-					fmt.Sprintf("%d", int(containersReadyDuration.Seconds())),
-					fmt.Sprintf("%d", int(queueProxyStartedDuration.Seconds())),
-					fmt.Sprintf("%d", int(userContrainerStartedDuration.Seconds())),
-					fmt.Sprintf("%d", int(svcRoutesReadyDuration.Seconds())),
-					fmt.Sprintf("%d", int(kpaActiveDuration.Seconds())),
-					fmt.Sprintf("%d", int(sksReadyDuration.Seconds())),
-					fmt.Sprintf("%d", int(sksActivatorEndpointsPopulatedDuration.Seconds())),
-					fmt.Sprintf("%d", int(sksEndpointsPopulatedDuration.Seconds())),
-					fmt.Sprintf("%d", int(ingressReadyDuration.Seconds())),
-					fmt.Sprintf("%d", int(ingressNetworkConfiguredDuration.Seconds())),
-					fmt.Sprintf("%d", int(ingressLoadBalancerReadyDuration.Seconds())),
-					fmt.Sprintf("%d", int(svcReadyDuration.Seconds())),
-				})
-
-				rawRows = append(rawRows, []string{svc, svcNs,
-					svcCreatedTime.String(),
-					svcConfigurationsReady.Rfc3339Copy().String(),
-					revisionIns.GetCreationTimestamp().Rfc3339Copy().String(),
-					revisionReadyTime.String(),
-					deploymentCreatedTime.String(),
-					podCreatedTime.String(),
-					podScheduledTime.String(),
-					containersReadyTime.String(),
-					queueProxyStartedTime.String(),
-					userContrainerStartedTime.String(),
-					svcRoutesReady.String(),
-					kpaCreatedTime.String(),
-					kpaActiveTime.String(),
-					sksCreatedTime.String(),
-					sksActivatorEndpointsPopulatedTime.String(),
-					sksEndpointsPopulatedTime.String(),
-					ingressCreatedTime.String(),
-					ingressNetworkConfiguredTime.String(),
-					ingressLoadBalancerReadyTime.String()})
+				phaseDurations := []time.Duration{
+					svcConfigurationsReadyDuration,
+					revisionReadyDuration,
+					deploymentCreatedDuration,
+					podScheduledDuration,
+					containersReadyDuration,
+					queueProxyStartedDuration,
+					userContrainerStartedDuration,
+					svcRoutesReadyDuration,
+					kpaActiveDuration,
+					sksReadyDuration,
+					sksActivatorEndpointsPopulatedDuration,
+					sksEndpointsPopulatedDuration,
+					ingressReadyDuration,
+					ingressNetworkConfiguredDuration,
+					ingressLoadBalancerReadyDuration,
+					svcReadyDuration,
+				}
+				row := []string{svc, svcNs}
+				for i, d := range phaseDurations {
+					row = append(row, formatSeconds(d.Seconds()))
+					phases[measurePhaseNames[i]] = append(phases[measurePhaseNames[i]], d.Seconds())
+				}
+				rows = append(rows, append(row,
+					strconv.Itoa(len(pods)),
+					formatPodReady(firstPodReady, podReady),
+					formatPodReady(medianPodReady, podReady),
+					formatPodReady(lastPodReady, podReady),
+				))
+				podRows = append(podRows, svcPodRows...)
+				podMeasurements = append(podMeasurements, svcPodResults...)
+				if tlsResult != nil {
+					tlsMeasurements = append(tlsMeasurements, *tlsResult)
+					tlsRowsList = append(tlsRowsList, tlsRows(tlsResult)...)
+					for name, durations := range tlsPhaseDurations(tlsResult) {
+						phases[name] = append(phases[name], durations...)
+					}
+				}
+				if podReady {
+					firstPodsReady = append(firstPodsReady, firstPodReady)
+					medianPodsReady = append(medianPodsReady, medianPodReady)
+					lastPodsReady = append(lastPodsReady, lastPodReady)
+				}
+
+				rawTimes := []measuredTime{
+					svcCreatedTime,
+					svcConfigurationsReady,
+					revisionCreatedTime,
+					revisionReadyTime,
+					deploymentCreatedTime,
+					podCreatedTime,
+					podScheduledTime,
+					containersReadyTime,
+					queueProxyStartedTime,
+					userContrainerStartedTime,
+					svcRoutesReady,
+					kpaCreatedTime,
+					kpaActiveTime,
+					sksCreatedTime,
+					sksActivatorEndpointsPopulatedTime,
+					sksEndpointsPopulatedTime,
+					ingressCreatedTime,
+					ingressNetworkConfiguredTime,
+					ingressLoadBalancerReadyTime}
+				rawRows = append(rawRows, append([]string{svc, svcNs}, timestampCells(rawTimes...)...))
+				countTimestampSources(timestampSources, rawTimestampNames, rawTimes)
 
 				if options.VerboseChanged {
 					fmt.Printf("[Verbose] Service %s: Service Configuration Ready Duration is %s/%fs\n",
@@ -421,6 +526,10 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 						svc, queueProxyStartedDuration, queueProxyStartedDuration.Seconds())
 					fmt.Printf("[Verbose] Service %s:       - Service Pod user-container Started Duration is %s/%fs\n",
 						svc, userContrainerStartedDuration, userContrainerStartedDuration.Seconds())
+					if podReady {
+						fmt.Printf("[Verbose] Service %s:     - Service Pods Ready Duration of %d Pods is first %fs, median %fs, last %fs\n",
+							svc, len(pods), firstPodReady, medianPodReady, lastPodReady)
+					}
 					fmt.Printf("[Verbose] Service %s:   - Service PodAutoscaler Active Duration is %s/%fs\n",
 						svc, kpaActiveDuration, kpaActiveDuration.Seconds())
 					fmt.Printf("[Verbose] Service %s:     - Service ServerlessService Ready Duration is %s/%fs\n",
@@ -502,33 +611,43 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 
 	sortSlice(rows)
 	sortSlice(rawRows)
+	// the rows of the containers of a pod stay in the order of the pod
+	sort.SliceStable(podRows, func(i, j int) bool {
+		return podRows[i][1]+"/"+podRows[i][0] < podRows[j][1]+"/"+podRows[j][0]
+	})
+
+	header := append([]string{"svc_name", "svc_namespace"}, measurePhaseNames...)
+	rows = append([][]string{append(header, "pod_count", "first_pod_ready", "median_pod_ready", "last_pod_ready")}, rows...)
 
-	rows = append([][]string{{"svc_name", "svc_namespace", "configuration_ready", "revision_ready",
-		"deployment_created", "pod_scheduled", "containers_ready", "queue-proxy_started", "user-container_started",
-		"route_ready", "kpa_active", "sks_ready", "sks_activator_endpoints_populated", "sks_endpoints_populated",
-		"ingress_ready", "ingress_config_ready", "ingress_lb_ready", "overall_ready"}}, rows...)
-
-	rawRows = append([][]string{{"svc_name", "svc_namespace",
-		"svc_created",
-		"configuration_ready",
-		"revision_created",
-		"revision_ready",
-		"deployment_created",
-		"pod_created",
-		"pod_scheduled",
-		"containers_ready",
-		"queue-proxy_started",
-		"user-container_started",
-		"route_ready",
-		"kpa_created",
-		"kpa_active",
-		"sks_created",
-		"sks_activator_endpoints_populated",
-		"sks_endpoints_populated",
-		"ingress_created",
-		"ingress_config_ready",
-		"ingress_lb_ready"}}, rawRows...)
+	rawRows = append([][]string{append([]string{"svc_name", "svc_namespace"}, timestampHeader(rawTimestampNames...)...)}, rawRows...)
+	if len(timestampSources) > 0 {
+		measureFinalResult.TimestampSources = timestampSources
+	}
+	if len(podMeasurements) > 0 {
+		sort.SliceStable(podMeasurements, func(i, j int) bool {
+			return podMeasurements[i].ServiceNamespace+"/"+podMeasurements[i].ServiceName <
+				podMeasurements[j].ServiceNamespace+"/"+podMeasurements[j].ServiceName
+		})
+		measureFinalResult.Pods = podMeasurements
+	}
+	if len(tlsMeasurements) > 0 {
+		sort.SliceStable(tlsMeasurements, func(i, j int) bool {
+			return tlsMeasurements[i].ServiceNamespace+"/"+tlsMeasurements[i].ServiceName <
+				tlsMeasurements[j].ServiceNamespace+"/"+tlsMeasurements[j].ServiceName
+		})
+		sort.SliceStable(tlsRowsList, func(i, j int) bool {
+			return tlsRowsList[i][1]+"/"+tlsRowsList[i][0] < tlsRowsList[j][1]+"/"+tlsRowsList[j][0]
+		})
+		measureFinalResult.TLS = tlsMeasurements
+	}
 	total := measureFinalResult.Service.ReadyCount + measureFinalResult.Service.NotReadyCount + measureFinalResult.Service.NotFoundCount + measureFinalResult.Service.FailCount
+	if len(failures) > 0 {
+		sort.SliceStable(failures, func(i, j int) bool {
+			return failures[i].ServiceNamespace+"/"+failures[i].ServiceName < failures[j].ServiceNamespace+"/"+failures[j].ServiceName
+		})
+		measureFinalResult.Failures = failures
+		measureFinalResult.FailureGroups = failureGroups(failures)
+	}
 
 	knativeVersion := GetKnativeVersion(params)
 	ingressInfo := GetIngressController(params)
@@ -602,6 +721,22 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 		measureFinalResult.Result.AverageSksEndpointsPopulatedSum = measureFinalResult.Sums.SksEndpointsPopulatedSum / float64(measureFinalResult.Service.ReadyCount)
 		fmt.Printf("        Average: %fs\n", measureFinalResult.Result.AverageSksEndpointsPopulatedSum)
 
+		startups := make([]*pkg.PodStartup, 0, len(podMeasurements))
+		for _, pod := range podMeasurements {
+			startups = append(startups, pod.Startup)
+		}
+		measureFinalResult.Startup = startupResultHandler(startups)
+
+		if len(firstPodsReady) > 0 {
+			measureFinalResult.PodReady = &pkg.PodReadyResult{}
+			fmt.Printf("  - Service First Pod Ready Duration:\n")
+			measureFinalResult.PodReady.First = latencyResultHandler(firstPodsReady)
+			fmt.Printf("  - Service Median Pod Ready Duration:\n")
+			measureFinalResult.PodReady.Median = latencyResultHandler(medianPodsReady)
+			fmt.Printf("  - Service Last Pod Ready Duration:\n")
+			measureFinalResult.PodReady.Last = latencyResultHandler(lastPodsReady)
+		}
+
 		fmt.Printf("\nService Route Ready Duration:\n")
 		fmt.Printf("Total: %fs\n", measureFinalResult.Sums.SvcRoutesReadySum)
 		measureFinalResult.Result.AverageSvcRoutesReadySum = measureFinalResult.Sums.SvcRoutesReadySum / float64(measureFinalResult.Service.ReadyCount)
@@ -658,19 +793,58 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 		measureFinalResult.Result.P99, _ = stats.Percentile(measureFinalResult.SvcReadyTime, 99)
 		fmt.Printf("Percentile99: %fs\n", measureFinalResult.Result.P99)
 
+		fmt.Printf("\n-----------------------------\n")
+		fmt.Printf("Phase Latency Distribution:\n")
+		measureFinalResult.Phases = phaseDistributionHandler(phases)
+
 		// generate CSV output of raw timestamp from rawRows
 		rawOutputFilename, err := GenerateOutputPathPrefix(inputs.Output, RawMeasureOutputFilename)
 		if err != nil {
 			fmt.Printf("failed to generate raw output filename: %s", err)
 			return err
 		}
-		rawCSVPath, err := GenerateCSVOutput(rows, rawOutputFilename)
+		rawCSVPath, err := GenerateCSVOutput(rawRows, rawOutputFilename)
 		if err != nil {
 			fmt.Printf("failed to save Raw Timestamp: %s\n", err)
 			return err
 		}
 		fmt.Printf("Raw Timestamp saved in CSV file %s\n", rawCSVPath)
 
+		// generate CSV output of the phase distributions
+		err = GenerateOutput(inputs.Output, SummaryMeasureOutputFilename, true, false, false,
+			phaseSummaryRows(measureFinalResult.Phases), nil)
+		if err != nil {
+			fmt.Printf("failed to generate summary output: %s\n", err)
+			return err
+		}
+
+		// generate CSV output of the failures with a row per service
+		if len(failures) > 0 {
+			err = GenerateOutput(inputs.Output, FailureOutputFilename, true, false, false, failureRows(failures), nil)
+			if err != nil {
+				fmt.Printf("failed to generate failure output: %s\n", err)
+				return err
+			}
+		}
+
+		// generate CSV output of the Certificates and DomainMappings of the services
+		if len(tlsMeasurements) > 0 {
+			err = GenerateOutput(inputs.Output, TLSMeasureOutputFilename, true, false, false,
+				append([][]string{tlsHeader}, tlsRowsList...), nil)
+			if err != nil {
+				fmt.Printf("failed to generate TLS output: %s\n", err)
+				return err
+			}
+		}
+
+		// generate CSV output of the pods from podRows
+		err = GenerateOutput(inputs.Output, PodMeasureOutputFilename, true, false, false,
+			append([][]string{podHeader}, podRows...), nil)
+		if err != nil {
+			fmt.Printf("failed to generate pod output: %s\n", err)
+			return err
+		}
+
 		// generate CSV, HTML and JSON outputs from rows and measureFinalResult
 		err = GenerateOutput(inputs.Output, MeasureOutputFilename, true, true, true, rows, measureFinalResult)
 		if err != nil {
@@ -688,11 +862,56 @@ func MeasureServices(params *pkg.PerfParams, inputs pkg.MeasureArgs, options Mea
 		fmt.Printf("    Version: %v\n", measureFinalResult.KnativeInfo.IngressVersion)
 		fmt.Printf("Service Ready Measurement:\n")
 		fmt.Printf("Total: %d | Ready: %d NotReady: %d NotFound: %d Fail: %d\n", total, measureFinalResult.Service.ReadyCount, measureFinalResult.Service.NotReadyCount, measureFinalResult.Service.NotFoundCount, measureFinalResult.Service.FailCount)
+
+		// without any ready service there is nothing measured, the failures are saved in the failure CSV and the
+		// JSON outputs as far as possible, not failing the measurement
+		if len(failures) > 0 {
+			if err := GenerateOutput(inputs.Output, FailureOutputFilename, true, false, false, failureRows(failures), nil); err != nil {
+				fmt.Printf("failed to generate failure output: %s\n", err)
+			} else if err := GenerateOutput(inputs.Output, MeasureOutputFilename, false, false, true, nil, measureFinalResult); err != nil {
+				fmt.Printf("failed to generate output: %s\n", err)
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		fmt.Printf("\n-----------------------------\n")
+		printFailureGroups(measureFinalResult.FailureGroups)
 	}
 
 	return nil
 }
 
+// phaseDistributionHandler returns the latency distribution of each phase of the creation lifecycle
+func phaseDistributionHandler(phases map[string][]float64) map[string]pkg.LatencyResult {
+	result := map[string]pkg.LatencyResult{}
+	for _, name := range summaryPhaseNames {
+		if len(phases[name]) == 0 {
+			continue
+		}
+		fmt.Printf("%s latency result:\n", name)
+		result[name] = latencyResultHandler(phases[name])
+	}
+	return result
+}
+
+// phaseSummaryRows returns the rows of the summary CSV with a row per phase in the order of the columns
+func phaseSummaryRows(phases map[string]pkg.LatencyResult) [][]string {
+	rows := [][]string{{"phase", "average", "min", "max", "p50", "p90", "p95", "p99", "stddev"}}
+	for _, name := range summaryPhaseNames {
+		latency, ok := phases[name]
+		if !ok {
+			continue
+		}
+		row := []string{name}
+		for _, value := range []float64{latency.Average, latency.Min, latency.Max, latency.P50, latency.P90, latency.P95, latency.P99, latency.StdDev} {
+			row = append(row, formatSeconds(value))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
 func sortSlice(rows [][]string) {
 	sort.Slice(rows, func(i, j int) bool {
 		a := strings.Split(rows[i][0], "-")
@@ -16,22 +16,39 @@ package service
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	clienttesting "k8s.io/client-go/testing"
 	"knative.dev/kperf/pkg"
 	"knative.dev/kperf/pkg/testutil"
+	networkingv1api "knative.dev/networking/pkg/apis/networking/v1alpha1"
 	networkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1"
 	fakenetworkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1/fake"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	autoscalingv1api "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
 	autoscalingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/autoscaling/v1alpha1"
 	autoscalingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/autoscaling/v1alpha1/fake"
 
 	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
 	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
+	servingv1beta1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1beta1"
+	servingv1beta1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1beta1/fake"
 )
 
 func TestNewServiceMeasureCommand(t *testing.T) {
@@ -47,11 +64,11 @@ func TestNewServiceMeasureCommand(t *testing.T) {
 		_, err := testutil.ExecuteCommand(cmd)
 		assert.ErrorContains(t, err, "'service measure' requires flag(s)")
 
-		_, err = testutil.ExecuteCommand(cmd, "--range", "1200", "--namespace", "ns")
-		assert.ErrorContains(t, err, "expected range like 1,500, given 1200")
+		_, err = testutil.ExecuteCommand(cmd, "--range", "1200-", "--namespace", "ns")
+		assert.ErrorContains(t, err, "expected range like 1,500, given 1200-")
 
-		_, err = testutil.ExecuteCommand(cmd, "--range", "1200", "--namespace-prefix", "ns", "--namespace-range", "1,2")
-		assert.ErrorContains(t, err, "expected range like 1,500, given 1200")
+		_, err = testutil.ExecuteCommand(cmd, "--range", "1200-", "--namespace-prefix", "ns", "--namespace-range", "1,2")
+		assert.ErrorContains(t, err, "expected range like 1,500, given 1200-")
 
 		_, err = testutil.ExecuteCommand(cmd, "--range", "x,y", "--namespace", "ns")
 		assert.ErrorContains(t, err, "strconv.Atoi: parsing \"x\": invalid syntax")
@@ -96,7 +113,8 @@ func TestNewServiceMeasureCommand(t *testing.T) {
 		}
 
 		cmd := NewServiceMeasureCommand(p)
-		_, err := testutil.ExecuteCommand(cmd, "--svc-prefix", "svc", "--namespace", "ns1", "--range", "1,1")
+		// the failure of the service not found is saved in the output
+		_, err := testutil.ExecuteCommand(cmd, "--svc-prefix", "svc", "--namespace", "ns1", "--range", "1,1", "--output", t.TempDir())
 		assert.NilError(t, err)
 	})
 
@@ -170,6 +188,259 @@ func TestNewServiceMeasureCommand(t *testing.T) {
 	})
 }
 
+// newMeasureParams returns the params of fake clients with a ready Knative Service ksvc-1 in namespace ns1 and all its
+// objects, the revision having the containers and the pods, the times being offsets in seconds from start. The pod i is
+// created at 0.3+i and ready at 2.2+i, its containers being started from 1.5+i every 0.1 second
+func newMeasureParams(start time.Time, pods int, containers ...string) *pkg.PerfParams {
+	at := func(seconds float64) metav1.Time {
+		return metav1.NewTime(start.Add(time.Duration(seconds * float64(time.Second))))
+	}
+	conditions := func(seconds float64, types ...apis.ConditionType) duckv1.Status {
+		status := duckv1.Status{}
+		for _, t := range types {
+			status.Conditions = append(status.Conditions, apis.Condition{Type: t, Status: corev1.ConditionTrue, LastTransitionTime: apis.VolatileTime{Inner: at(seconds)}})
+		}
+		return status
+	}
+	meta := func(name string, seconds float64, labels map[string]string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "ns1", CreationTimestamp: at(seconds), Labels: labels}
+	}
+	revisionLabels := map[string]string{serving.RevisionLabelKey: "ksvc-1-00001", serving.ServiceLabelKey: "ksvc-1"}
+
+	svc := &servingv1.Service{ObjectMeta: meta("ksvc-1", 0, nil)}
+	svc.Status.Status = conditions(3.25, apis.ConditionReady, servingv1.ServiceConditionConfigurationsReady, servingv1.ServiceConditionRoutesReady)
+	cfg := &servingv1.Configuration{ObjectMeta: meta("ksvc-1", 0.01, nil)}
+	cfg.Status.LatestReadyRevisionName = "ksvc-1-00001"
+	revision := &servingv1.Revision{ObjectMeta: meta("ksvc-1-00001", 0.02, revisionLabels)}
+	for _, name := range containers {
+		revision.Spec.Containers = append(revision.Spec.Containers, corev1.Container{Name: name})
+	}
+	revision.Status.Status = conditions(2.5, apis.ConditionReady)
+	pa := &autoscalingv1api.PodAutoscaler{ObjectMeta: meta("ksvc-1-00001", 0.1, revisionLabels)}
+	pa.Status.Status = conditions(2.4, autoscalingv1api.PodAutoscalerConditionActive)
+	sks := &networkingv1api.ServerlessService{ObjectMeta: meta("ksvc-1-00001", 0.15, revisionLabels)}
+	sks.Status.Status = conditions(2.3, apis.ConditionReady, networkingv1api.ActivatorEndpointsPopulated, networkingv1api.ServerlessServiceConditionEndspointsPopulated)
+	ingress := &networkingv1api.Ingress{ObjectMeta: meta("ksvc-1", 2.6, nil)}
+	ingress.Status.Status = conditions(3.1, apis.ConditionReady, networkingv1api.IngressConditionNetworkConfigured, networkingv1api.IngressConditionLoadBalancerReady)
+	knativeFake := testutil.NewKnativeFake(svc, cfg, revision, pa, sks, ingress)
+
+	deployment := &appsv1.Deployment{ObjectMeta: meta("ksvc-1-00001-deployment", 0.05, revisionLabels)}
+	client := k8sfake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1"}}, deployment)
+	for i := 0; i < pods; i++ {
+		offset := float64(i)
+		pod := &corev1.Pod{ObjectMeta: meta(fmt.Sprintf("ksvc-1-00001-deployment-%d", i), 0.3+offset, revisionLabels)}
+		pod.Status.Conditions = []corev1.PodCondition{
+			{Type: corev1.PodScheduled, Status: corev1.ConditionTrue, LastTransitionTime: at(0.3 + offset)},
+			{Type: corev1.ContainersReady, Status: corev1.ConditionTrue, LastTransitionTime: at(2.2 + offset)},
+		}
+		for j, name := range append([]string{"queue-proxy"}, containers...) {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+				Name:  name,
+				Ready: true,
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: at(1.5 + offset + 0.1*float64(j))}},
+			})
+		}
+		scheduled := &corev1.Event{
+			ObjectMeta:     meta(pod.Name+".scheduled", 0.35+offset, nil),
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "ns1", Name: pod.Name},
+			Reason:         "Scheduled",
+			EventTime:      metav1.NewMicroTime(at(0.35 + offset).Time),
+		}
+		client.Tracker().Add(pod)
+		client.Tracker().Add(scheduled)
+	}
+
+	return &pkg.PerfParams{
+		ClientSet: client,
+		NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+			return &servingv1fake.FakeServingV1{Fake: knativeFake}, nil
+		},
+		NewAutoscalingClient: func() (autoscalingv1client.AutoscalingV1alpha1Interface, error) {
+			return &autoscalingv1fake.FakeAutoscalingV1alpha1{Fake: knativeFake}, nil
+		},
+		NewNetworkingClient: func() (networkingv1alpha1.NetworkingV1alpha1Interface, error) {
+			return &fakenetworkingv1alpha1.FakeNetworkingV1alpha1{Fake: knativeFake}, nil
+		},
+		NewDomainMappingClient: func() (servingv1beta1client.ServingV1beta1Interface, error) {
+			return &servingv1beta1fake.FakeServingV1beta1{Fake: knativeFake}, nil
+		},
+	}
+}
+
+// readMeasureOutput returns the rows of the CSV file and the JSON result saved with the name in the output directory
+func readMeasureOutput(t *testing.T, output, name string) ([][]string, pkg.MeasureResult) {
+	// the files are prefixed with a timestamp
+	outputFile := func(ext string) string {
+		entries, err := os.ReadDir(output)
+		assert.NilError(t, err)
+		for _, entry := range entries {
+			if strings.TrimLeft(entry.Name(), "0123456789") == "_"+name+ext {
+				return filepath.Join(output, entry.Name())
+			}
+		}
+		return ""
+	}
+	f, err := os.Open(outputFile(".csv"))
+	assert.NilError(t, err)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	assert.NilError(t, err)
+
+	result := pkg.MeasureResult{}
+	if path := outputFile(".json"); path != "" {
+		data, err := os.ReadFile(path)
+		assert.NilError(t, err)
+		assert.NilError(t, json.Unmarshal(data, &result))
+	}
+	return rows, result
+}
+
+// column returns the cell of the row in the column named in the header
+func column(rows [][]string, row int, name string) string {
+	for i, header := range rows[0] {
+		if header == name {
+			return rows[row][i]
+		}
+	}
+	return ""
+}
+
+func TestMeasureServicesPrecision(t *testing.T) {
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := newMeasureParams(start, 1, "user-container")
+
+	output := t.TempDir()
+	err := MeasureServices(p, pkg.MeasureArgs{Namespace: "ns1", SvcPrefix: "ksvc", SvcRange: "1,1", Concurrency: 1, Output: output},
+		MeasureServicesOptions{NamespaceChanged: true})
+	assert.NilError(t, err)
+
+	rows, result := readMeasureOutput(t, output, MeasureOutputFilename)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "2.480", column(rows, 1, "revision_ready"))
+	assert.Equal(t, "0.030", column(rows, 1, "deployment_created"))
+	// the pod is scheduled at the time of the Scheduled Event rather than of its condition
+	assert.Equal(t, "0.050", column(rows, 1, "pod_scheduled"))
+	assert.Equal(t, "3.250", column(rows, 1, "overall_ready"))
+	assert.Equal(t, 1, result.TimestampSources["pod_scheduled"][TimestampSourceEvent])
+	assert.Equal(t, 1, result.TimestampSources["pod_created"][TimestampSourceAPI])
+	assert.Equal(t, 2.48, math.Round(result.Result.AverageRevisionReadySum*1000)/1000)
+
+	summaryRows, _ := readMeasureOutput(t, output, SummaryMeasureOutputFilename)
+	assert.Equal(t, len(measurePhaseNames)+1, len(summaryRows))
+	assert.DeepEqual(t, []string{"pod_scheduled", "0.050", "0.050", "0.050", "0.050", "0.050", "0.050", "0.050", "0.000"}, summaryRows[4])
+	assert.Equal(t, 2.15, math.Round(result.Phases["sks_endpoints_populated"].P99*1000)/1000)
+
+	rawRows, _ := readMeasureOutput(t, output, RawMeasureOutputFilename)
+	assert.Equal(t, "2023-01-01T12:00:00.35Z", column(rawRows, 1, "pod_scheduled"))
+	assert.Equal(t, TimestampSourceEvent, column(rawRows, 1, "pod_scheduled_source"))
+	assert.Equal(t, TimestampSourceAPI, column(rawRows, 1, "revision_ready_source"))
+}
+
+func TestMeasureServicesPods(t *testing.T) {
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := newMeasureParams(start, 3, "app", "sidecar")
+
+	output := t.TempDir()
+	err := MeasureServices(p, pkg.MeasureArgs{Namespace: "ns1", SvcPrefix: "ksvc", SvcRange: "1,1", Concurrency: 1, Output: output},
+		MeasureServicesOptions{NamespaceChanged: true})
+	assert.NilError(t, err)
+
+	rows, result := readMeasureOutput(t, output, MeasureOutputFilename)
+	assert.Equal(t, 1, result.Service.ReadyCount)
+	assert.Equal(t, "3", column(rows, 1, "pod_count"))
+	assert.Equal(t, "2.180", column(rows, 1, "first_pod_ready"))
+	assert.Equal(t, "3.180", column(rows, 1, "median_pod_ready"))
+	assert.Equal(t, "4.180", column(rows, 1, "last_pod_ready"))
+	// the containers of the revision are started when the last of them is
+	assert.Equal(t, "1.200", column(rows, 1, "queue-proxy_started"))
+	assert.Equal(t, "1.400", column(rows, 1, "user-container_started"))
+
+	assert.Equal(t, 3, len(result.Pods))
+	assert.Equal(t, "ksvc-1-00001-deployment-2", result.Pods[2].PodName)
+	assert.Equal(t, 3, len(result.Pods[2].Containers))
+	assert.Equal(t, "sidecar", result.Pods[2].Containers[2].Name)
+	assert.Equal(t, 4.18, math.Round(result.PodReady.Last.Max*1000)/1000)
+
+	podRows, _ := readMeasureOutput(t, output, PodMeasureOutputFilename)
+	assert.Equal(t, 10, len(podRows))
+	assert.Equal(t, "app", column(podRows, 2, "container"))
+	assert.Equal(t, "1.300", column(podRows, 2, "container_started"))
+	assert.Equal(t, "2.280", column(podRows, 9, "pod_created"))
+}
+
+func TestMeasureServicesFailures(t *testing.T) {
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := newMeasureParams(start, 1, "user-container")
+
+	output := t.TempDir()
+	err := MeasureServices(p, pkg.MeasureArgs{Namespace: "ns1", SvcPrefix: "ksvc", SvcRange: "1,2", Concurrency: 2, Output: output},
+		MeasureServicesOptions{NamespaceChanged: true})
+	assert.NilError(t, err)
+
+	_, result := readMeasureOutput(t, output, MeasureOutputFilename)
+	assert.Equal(t, 1, result.Service.NotFoundCount)
+	assert.DeepEqual(t, []pkg.FailureGroup{{Status: FailureNotFound, Resource: failureService, Reason: "NotFound", Count: 1}}, result.FailureGroups)
+
+	failureRows, _ := readMeasureOutput(t, output, FailureOutputFilename)
+	assert.Equal(t, 2, len(failureRows))
+	assert.Equal(t, "ksvc-2", column(failureRows, 1, "svc_name"))
+	assert.Equal(t, FailureNotFound, column(failureRows, 1, "status"))
+}
+
+func TestMeasureServicesAllFailed(t *testing.T) {
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := newMeasureParams(start, 1, "user-container")
+
+	output := t.TempDir()
+	err := MeasureServices(p, pkg.MeasureArgs{Namespace: "ns1", SvcPrefix: "ksvc", SvcRange: "2,3", Concurrency: 2, Output: output},
+		MeasureServicesOptions{NamespaceChanged: true})
+	assert.NilError(t, err)
+
+	failureRows, _ := readMeasureOutput(t, output, FailureOutputFilename)
+	assert.Equal(t, 3, len(failureRows))
+	assert.Equal(t, "ksvc-2", column(failureRows, 1, "svc_name"))
+	assert.Equal(t, "ksvc-3", column(failureRows, 2, "svc_name"))
+
+	// only the JSON output is written for the measurement, with the failures
+	entries, err := os.ReadDir(output)
+	assert.NilError(t, err)
+	var measureFiles []string
+	for _, entry := range entries {
+		if name := strings.TrimLeft(entry.Name(), "0123456789"); strings.HasPrefix(name, "_"+MeasureOutputFilename+".") {
+			measureFiles = append(measureFiles, entry.Name())
+		}
+	}
+	assert.Equal(t, 1, len(measureFiles))
+	assert.Assert(t, strings.HasSuffix(measureFiles[0], ".json"))
+	data, err := os.ReadFile(filepath.Join(output, measureFiles[0]))
+	assert.NilError(t, err)
+	result := pkg.MeasureResult{}
+	assert.NilError(t, json.Unmarshal(data, &result))
+	assert.Equal(t, 0, result.Service.ReadyCount)
+	assert.Equal(t, 2, result.Service.NotFoundCount)
+	assert.Equal(t, 2, len(result.Failures))
+	assert.DeepEqual(t, []pkg.FailureGroup{{Status: FailureNotFound, Resource: failureService, Reason: "NotFound", Count: 2}}, result.FailureGroups)
+}
+
+func TestPhaseDistributionHandler(t *testing.T) {
+	phases := phaseDistributionHandler(map[string][]float64{"kpa_active": {1, 3, 2, 2}, "unknown": {1}})
+	assert.Equal(t, 1, len(phases))
+	assert.Equal(t, 3.0, phases["kpa_active"].Max)
+	assert.Equal(t, math.Sqrt(0.5), phases["kpa_active"].StdDev)
+
+	rows := phaseSummaryRows(phases)
+	assert.DeepEqual(t, [][]string{
+		{"phase", "average", "min", "max", "p50", "p90", "p95", "p99", "stddev"},
+		{"kpa_active", "2.000", "1.000", "3.000", "2.000", "2.500", "2.500", "2.500", "0.707"},
+	}, rows)
+}
+
+func TestRevisionContainerNames(t *testing.T) {
+	revision := &servingv1.Revision{}
+	revision.Spec.Containers = []corev1.Container{{Name: "app"}, {}}
+	assert.DeepEqual(t, []string{"app", "user-container"}, revisionContainerNames(revision))
+}
+
 func TestSortSlice(t *testing.T) {
 	rows := [][]string{{"test-2"}, {"test-1"}}
 	sortSlice(rows)
@@ -0,0 +1,103 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"knative.dev/kperf/pkg"
+)
+
+// namespacePollInterval is the interval to check deleted namespaces are terminated
+const namespacePollInterval = time.Second
+
+// ensureNamespaces checks the namespaces exist, creating the missing ones with the labels of the run if create is set
+func ensureNamespaces(ctx context.Context, params *pkg.PerfParams, nsNameList []string, create bool, runID string) error {
+	for _, ns := range nsNameList {
+		_, err := params.ClientSet.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get namespace: %w", err)
+		}
+		if !create {
+			return fmt.Errorf("namespace %s not found, please create one or use --create-namespaces", ns)
+		}
+		fmt.Printf("Creating namespace %s\n", ns)
+		_, err = params.ClientSet.CoreV1().Namespaces().Create(ctx, newNamespace(ns, runID), metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create namespace %s: %w", ns, err)
+		}
+	}
+	return nil
+}
+
+// newNamespace returns the namespace labeled with the run
+func newNamespace(ns string, runID string) *corev1.Namespace {
+	return &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   ns,
+			Labels: pkg.RunLabels(runID),
+		},
+	}
+}
+
+// deleteNamespaces deletes the namespaces created by kperf, or by the kperf run runID if set, and waits for them to
+// be terminated, the other namespaces are kept
+func deleteNamespaces(ctx context.Context, params *pkg.PerfParams, nsNameList []string, runID string, timeout time.Duration) error {
+	deleted := []string{}
+	for _, ns := range nsNameList {
+		namespace, err := params.ClientSet.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to get namespace: %w", err)
+		}
+		if namespace.Labels[pkg.ManagedByLabelKey] != pkg.ManagedByLabelValue {
+			fmt.Printf("Skip deleting namespace %s not created by kperf\n", ns)
+			continue
+		}
+		if runID != "" && namespace.Labels[pkg.RunIDLabelKey] != runID {
+			fmt.Printf("Skip deleting namespace %s not created by kperf run %s\n", ns, runID)
+			continue
+		}
+		fmt.Printf("Delete namespace %s\n", ns)
+		err = params.ClientSet.CoreV1().Namespaces().Delete(ctx, ns, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete namespace %s: %w", ns, err)
+		}
+		deleted = append(deleted, ns)
+	}
+
+	for _, ns := range deleted {
+		err := wait.PollImmediateWithContext(ctx, namespacePollInterval, timeout, func(ctx context.Context) (bool, error) {
+			_, err := params.ClientSet.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+			return apierrors.IsNotFound(err), nil
+		})
+		if err != nil {
+			return fmt.Errorf("namespace %s not terminated after %s", ns, timeout)
+		}
+	}
+	return nil
+}
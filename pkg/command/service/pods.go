@@ -0,0 +1,249 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/montanaflynn/stats"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/serving/pkg/apis/config"
+	servingv1api "knative.dev/serving/pkg/apis/serving/v1"
+
+	"knative.dev/kperf/pkg"
+)
+
+const (
+	PodMeasureOutputFilename = "ksvc_pod_creation_time"
+
+	// queueProxyContainerName is the name of the sidecar injected by Knative Serving in every pod of a revision
+	queueProxyContainerName = "queue-proxy"
+)
+
+// revisionContainerNames returns the names of the containers of the revision spec, the names being defaulted by the
+// webhook of Knative Serving when the revision is created
+func revisionContainerNames(revision *servingv1api.Revision) []string {
+	names := make([]string, 0, len(revision.Spec.Containers))
+	for _, container := range revision.Spec.Containers {
+		if container.Name == "" {
+			names = append(names, config.DefaultUserContainerName)
+			continue
+		}
+		names = append(names, container.Name)
+	}
+	return names
+}
+
+// measuredPod is the measurement of a pod of a revision
+type measuredPod struct {
+	name            string
+	created         measuredTime
+	scheduled       measuredTime
+	containersReady measuredTime
+	// containers are the start times of the queue-proxy and of the containers of the revision, in this order
+	containers []podContainer
+	// startup is the breakdown of the startup from the Events, nil without Events
+	startup *pkg.PodStartup
+}
+
+// podContainer is the start of a container of a pod, Kubernetes does not record when each container becomes ready so
+// the readiness of the containers is the ContainersReady condition of the pod
+type podContainer struct {
+	name         string
+	started      measuredTime
+	ready        bool
+	restartCount int32
+}
+
+// ready reports whether all the containers of the pod are ready
+func (p measuredPod) ready() bool {
+	return !p.containersReady.Time.IsZero()
+}
+
+// lastStarted returns the last start time of the named containers, zero if one of them is not started
+func (p measuredPod) lastStarted(names []string) measuredTime {
+	last := measuredTime{Source: TimestampSourceAPI}
+	for _, name := range names {
+		for _, container := range p.containers {
+			if container.name != name {
+				continue
+			}
+			if container.started.Time.IsZero() {
+				return measuredTime{Source: TimestampSourceAPI}
+			}
+			if container.started.Time.After(last.Time) {
+				last = container.started
+			}
+		}
+	}
+	return last
+}
+
+// measureRevisionPods returns the measurement of every pod of the revision sorted by readiness, the ready pods first
+// from the first to the last to be ready
+func measureRevisionPods(ctx context.Context, params *pkg.PerfParams, pods []corev1.Pod, containerNames []string) []measuredPod {
+	names := append([]string{queueProxyContainerName}, containerNames...)
+	result := make([]measuredPod, 0, len(pods))
+	for i := range pods {
+		pod := &pods[i]
+		measured := measuredPod{name: pod.Name, created: apiTime(pod.GetCreationTimestamp())}
+		measured.scheduled.Source = TimestampSourceAPI
+		if present, scheduled := getPodCondition(&pod.Status, corev1.PodScheduled); present != -1 && scheduled.Status == corev1.ConditionTrue {
+			measured.scheduled = apiTime(scheduled.LastTransitionTime)
+		}
+		// the Scheduled Event of the scheduler is more precise than the condition
+		events, err := listPodEvents(ctx, params, pod)
+		if err != nil {
+			fmt.Printf("%s, use the PodScheduled condition\n", err)
+		} else {
+			if scheduled, ok := podEventTimes(events)[eventScheduled]; ok {
+				measured.scheduled = scheduled
+			}
+			measured.startup = podStartup(pod, events)
+		}
+		measured.containersReady.Source = TimestampSourceAPI
+		if present, containersReady := getPodCondition(&pod.Status, corev1.ContainersReady); present != -1 && containersReady.Status == corev1.ConditionTrue {
+			measured.containersReady = apiTime(containersReady.LastTransitionTime)
+		}
+		for _, name := range names {
+			container := podContainer{name: name, started: measuredTime{Source: TimestampSourceAPI}}
+			if status, found := getContainerStatus(pod.Status.ContainerStatuses, name); found {
+				container.started = containerStartedTime(status)
+				container.ready = status.Ready
+				container.restartCount = status.RestartCount
+			}
+			measured.containers = append(measured.containers, container)
+		}
+		result = append(result, measured)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].ready() != result[j].ready() {
+			return result[i].ready()
+		}
+		return result[i].containersReady.Time.Before(result[j].containersReady.Time)
+	})
+	return result
+}
+
+// containerStartedTime returns the start time of the running container, or of the terminated container if it exited
+func containerStartedTime(status *corev1.ContainerStatus) measuredTime {
+	switch {
+	case status.State.Running != nil:
+		return apiTime(status.State.Running.StartedAt)
+	case status.State.Terminated != nil:
+		return apiTime(status.State.Terminated.StartedAt)
+	}
+	return measuredTime{Source: TimestampSourceAPI}
+}
+
+// podReadyDurations returns the durations from the revision creation for the first, the median and the last ready pod,
+// false if no pod is ready
+func podReadyDurations(pods []measuredPod, revisionCreated measuredTime) (first, median, last float64, ok bool) {
+	durations := []float64{}
+	for _, pod := range pods {
+		if pod.ready() {
+			durations = append(durations, pod.containersReady.Sub(revisionCreated).Seconds())
+		}
+	}
+	if len(durations) == 0 {
+		return 0, 0, 0, false
+	}
+	median, _ = stats.Median(durations)
+	return durations[0], median, durations[len(durations)-1], true
+}
+
+// formatPodReady returns the pod ready duration, empty if no pod is ready
+func formatPodReady(seconds float64, ready bool) string {
+	if !ready {
+		return ""
+	}
+	return formatSeconds(seconds)
+}
+
+// podResults returns the per pod results in seconds from the pod creation, and the rows of the pod CSV with a row per
+// container
+func podResults(svc, svcNs string, pods []measuredPod, revisionCreated measuredTime) ([]pkg.MeasurePodResult, [][]string) {
+	results := make([]pkg.MeasurePodResult, 0, len(pods))
+	rows := make([][]string, 0)
+	for _, pod := range pods {
+		result := pkg.MeasurePodResult{
+			ServiceName:      svc,
+			ServiceNamespace: svcNs,
+			PodName:          pod.name,
+			PodCreated:       pod.created.Sub(revisionCreated).Seconds(),
+			PodScheduled:     durationFrom(pod.scheduled, pod.created),
+			ContainersReady:  durationFrom(pod.containersReady, pod.created),
+			Ready:            pod.ready(),
+			Startup:          pod.startup,
+		}
+		for _, container := range pod.containers {
+			result.Containers = append(result.Containers, pkg.MeasureContainerResult{
+				Name:         container.name,
+				Started:      durationFrom(container.started, pod.created),
+				Ready:        container.ready,
+				RestartCount: container.restartCount,
+			})
+			rows = append(rows, append([]string{svc, svcNs, pod.name, container.name,
+				formatSeconds(pod.created.Sub(revisionCreated).Seconds()),
+				formatDurationFrom(pod.scheduled, pod.created),
+				formatDurationFrom(container.started, pod.created),
+				formatDurationFrom(pod.containersReady, pod.created),
+				fmt.Sprintf("%t", container.ready),
+				fmt.Sprintf("%d", container.restartCount),
+			}, startupCells(pod.startup)...))
+		}
+		results = append(results, result)
+	}
+	return results, rows
+}
+
+// podHeader is the header of the rows returned by podResults
+var podHeader = []string{"svc_name", "svc_namespace", "pod_name", "container", "pod_created", "pod_scheduled",
+	"container_started", "containers_ready", "container_ready", "restart_count", "scheduling", "image_pull",
+	"container_creation", "container_start", "readiness_probe_wait", "probe_failures"}
+
+// startupCells returns the cells of the startup phases of a pod, empty without Events
+func startupCells(startup *pkg.PodStartup) []string {
+	if startup == nil {
+		return []string{"", "", "", "", "", ""}
+	}
+	return []string{
+		formatSeconds(startup.Scheduling),
+		formatSeconds(startup.ImagePull),
+		formatSeconds(startup.ContainerCreation),
+		formatSeconds(startup.ContainerStart),
+		formatSeconds(startup.ReadinessProbeWait),
+		strconv.Itoa(int(startup.ProbeFailures)),
+	}
+}
+
+// durationFrom returns the seconds from the start to the time, zero if the time is not set
+func durationFrom(t, start measuredTime) float64 {
+	if t.Time.IsZero() {
+		return 0
+	}
+	return t.Sub(start).Seconds()
+}
+
+// formatDurationFrom returns the seconds from the start to the time, empty if the time is not set
+func formatDurationFrom(t, start measuredTime) string {
+	if t.Time.IsZero() {
+		return ""
+	}
+	return formatSeconds(t.Sub(start).Seconds())
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"knative.dev/kperf/pkg"
+)
+
+// runServicePool runs run for each service with at most concurrency services at a time, in the order of objs, each
+// run starting at least stagger after the previous one, and returns the schedule of the runs. The services not
+// started yet when ctx is done are skipped
+func runServicePool(ctx context.Context, objs []ServicesToScale, concurrency int, stagger time.Duration, run func(obj ServicesToScale)) pkg.ScheduleResult {
+	schedule := pkg.ScheduleResult{
+		Concurrency: concurrency,
+		Stagger:     stagger.Seconds(),
+		Services:    len(objs),
+		Waves:       (len(objs) + concurrency - 1) / concurrency,
+		Runs:        []pkg.ScheduledRun{},
+	}
+	fmt.Printf("Running %d services with %d workers, started %s apart, in %d waves\n", len(objs), concurrency, stagger, schedule.Waves)
+
+	start := time.Now()
+	svcChannel := make(chan ServicesToScale)
+	var wg sync.WaitGroup
+	var m sync.Mutex
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for obj := range svcChannel {
+				runStart := time.Since(start)
+				run(obj)
+				m.Lock()
+				schedule.Runs = append(schedule.Runs, pkg.ScheduledRun{
+					ServiceName:      obj.Service.Name,
+					ServiceNamespace: obj.Namespace,
+					Worker:           worker,
+					Start:            runStart.Seconds(),
+					End:              time.Since(start).Seconds(),
+				})
+				m.Unlock()
+			}
+		}(i)
+	}
+
+dispatch:
+	for i, obj := range objs {
+		if i > 0 && stagger > 0 {
+			select {
+			case <-time.After(stagger):
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		select {
+		case svcChannel <- obj:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(svcChannel)
+	wg.Wait()
+
+	schedule.Duration = time.Since(start).Seconds()
+	sort.Slice(schedule.Runs, func(i, j int) bool {
+		return schedule.Runs[i].Start < schedule.Runs[j].Start
+	})
+	if len(schedule.Runs) < len(objs) {
+		fmt.Printf("%d of %d services not run: %s\n", len(objs)-len(schedule.Runs), len(objs), ctx.Err())
+	}
+	return schedule
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func poolServices(n int) []ServicesToScale {
+	objs := []ServicesToScale{}
+	for i := 0; i < n; i++ {
+		objs = append(objs, ServicesToScale{Namespace: "ns-1",
+			Service: &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("ksvc-%d", i), Namespace: "ns-1"}}})
+	}
+	return objs
+}
+
+func TestRunServicePool(t *testing.T) {
+	t.Run("run at most concurrency services at a time", func(t *testing.T) {
+		var m sync.Mutex
+		running, maxRunning := 0, 0
+		schedule := runServicePool(context.Background(), poolServices(5), 2, 0, func(obj ServicesToScale) {
+			m.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			m.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			m.Lock()
+			running--
+			m.Unlock()
+		})
+		assert.Equal(t, 2, maxRunning)
+		assert.Equal(t, 5, schedule.Services)
+		assert.Equal(t, 3, schedule.Waves)
+		assert.Equal(t, 5, len(schedule.Runs))
+		workers := map[int]bool{}
+		for _, run := range schedule.Runs {
+			workers[run.Worker] = true
+			assert.Assert(t, run.End >= run.Start)
+		}
+		assert.Equal(t, 2, len(workers))
+	})
+
+	t.Run("stagger the starts", func(t *testing.T) {
+		schedule := runServicePool(context.Background(), poolServices(3), 3, 30*time.Millisecond, func(ServicesToScale) {})
+		assert.Equal(t, 0.03, schedule.Stagger)
+		assert.Equal(t, 3, len(schedule.Runs))
+		for i := 1; i < len(schedule.Runs); i++ {
+			assert.Assert(t, schedule.Runs[i].Start-schedule.Runs[i-1].Start >= 0.03, "runs %v", schedule.Runs)
+		}
+		assert.Equal(t, "ksvc-0", schedule.Runs[0].ServiceName)
+	})
+
+	t.Run("skip the services not started when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		schedule := runServicePool(ctx, poolServices(4), 1, time.Second, func(ServicesToScale) {
+			cancel()
+		})
+		assert.Equal(t, 4, schedule.Services)
+		assert.Equal(t, 1, len(schedule.Runs))
+	})
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+)
+
+// serviceReadinessWatcher keeps track of the Knative Services of the namespaces with an informer per namespace, so
+// that waiting for many Knative Services to be ready costs a single watch per namespace instead of polling each one
+type serviceReadinessWatcher struct {
+	informers map[string]cache.SharedIndexInformer
+
+	lock sync.Mutex
+	// readyAt is the time each Knative Service was first observed ready, by namespace/name key
+	readyAt map[string]time.Time
+	// changed is closed and replaced whenever a Knative Service changes to wake up the waiters
+	changed chan struct{}
+}
+
+// newServiceReadinessWatcher starts watching the Knative Services matching labelSelector in the namespaces until ctx
+// is done, and returns once the informers are synced
+func newServiceReadinessWatcher(ctx context.Context, ksvcClient servingv1client.ServingV1Interface, nsNameList []string, labelSelector string) (*serviceReadinessWatcher, error) {
+	w := &serviceReadinessWatcher{
+		informers: make(map[string]cache.SharedIndexInformer, len(nsNameList)),
+		readyAt:   map[string]time.Time{},
+		changed:   make(chan struct{}),
+	}
+	synced := make([]cache.InformerSynced, 0, len(nsNameList))
+	for _, ns := range nsNameList {
+		if _, exists := w.informers[ns]; exists {
+			continue
+		}
+		services := ksvcClient.Services(ns)
+		listWatch := &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = labelSelector
+				return services.List(ctx, options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = labelSelector
+				return services.Watch(ctx, options)
+			},
+		}
+		informer := cache.NewSharedIndexInformer(listWatch, &servingv1.Service{}, 0, cache.Indexers{})
+		_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: w.update,
+			UpdateFunc: func(_, obj interface{}) {
+				w.update(obj)
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to watch Knative Services in namespace %s: %w", ns, err)
+		}
+		w.informers[ns] = informer
+		synced = append(synced, informer.HasSynced)
+		go informer.Run(ctx.Done())
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return nil, fmt.Errorf("failed to sync Knative Services: %w", ctx.Err())
+	}
+	return w, nil
+}
+
+func (w *serviceReadinessWatcher) update(obj interface{}) {
+	svc, ok := obj.(*servingv1.Service)
+	if !ok {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	key := svc.Namespace + "/" + svc.Name
+	if _, seen := w.readyAt[key]; !seen && svc.IsReady() {
+		w.readyAt[key] = time.Now()
+	}
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// waitReady waits for the Knative Service to be ready and returns the time it was first observed ready. If the
+// Knative Service is not ready after timeout, the error tells why from its Ready condition
+func (w *serviceReadinessWatcher) waitReady(ctx context.Context, ns, name string, timeout time.Duration) (time.Time, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	key := ns + "/" + name
+	for {
+		w.lock.Lock()
+		readyAt, ready := w.readyAt[key]
+		changed := w.changed
+		w.lock.Unlock()
+		if ready {
+			return readyAt, nil
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return time.Time{}, fmt.Errorf("Knative Service %s in namespace %s is not ready after %s: %s", name, ns, timeout, w.notReadyReason(ns, name))
+		case <-ctx.Done():
+			return time.Time{}, fmt.Errorf("stopped waiting for Knative Service %s in namespace %s to be ready: %w", name, ns, ctx.Err())
+		}
+	}
+}
+
+// notReadyReason returns the reason and message of the Ready condition of the Knative Service
+func (w *serviceReadinessWatcher) notReadyReason(ns, name string) string {
+	informer, exists := w.informers[ns]
+	if !exists {
+		return "namespace not watched"
+	}
+	obj, exists, err := informer.GetStore().GetByKey(ns + "/" + name)
+	if err != nil || !exists {
+		return "not found"
+	}
+	svc := obj.(*servingv1.Service)
+	if svc.Status.ObservedGeneration != svc.Generation {
+		return fmt.Sprintf("generation %d not observed yet", svc.Generation)
+	}
+	condition := svc.Status.GetCondition(servingv1.ServiceConditionReady)
+	if condition == nil {
+		return "no Ready condition"
+	}
+	if condition.Reason == "" && condition.Message == "" {
+		return fmt.Sprintf("Ready condition is %s", condition.Status)
+	}
+	return fmt.Sprintf("%s: %s", condition.Reason, condition.Message)
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/testutil"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
+)
+
+func readyService(svc *servingv1.Service, status corev1.ConditionStatus, reason, message string) *servingv1.Service {
+	svc = svc.DeepCopy()
+	svc.Status.ObservedGeneration = svc.Generation
+	svc.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionReady, Status: status, Reason: reason, Message: message}}
+	return svc
+}
+
+func TestServiceReadinessWatcher(t *testing.T) {
+	restoreClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-0", Namespace: "ns-1", Labels: pkg.RunLabels("test-run")}}
+	other := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-0", Namespace: "ns-2"}}
+	fakeServing := &servingv1fake.FakeServingV1{Fake: testutil.NewKnativeFake(svc, other)}
+
+	watcher, err := newServiceReadinessWatcher(ctx, fakeServing, []string{"ns-1", "ns-2"}, pkg.RunSelector("test-run"))
+	assert.NilError(t, err)
+
+	t.Run("report why the service is not ready", func(t *testing.T) {
+		_, err := watcher.waitReady(ctx, "ns-1", "ksvc-0", 10*time.Millisecond)
+		assert.ErrorContains(t, err, "Knative Service ksvc-0 in namespace ns-1 is not ready after 10ms: no Ready condition")
+
+		_, err = fakeServing.Services("ns-1").UpdateStatus(ctx, readyService(svc, corev1.ConditionFalse, "RevisionMissing", "Configuration does not have any ready Revision."), metav1.UpdateOptions{})
+		assert.NilError(t, err)
+		_, err = watcher.waitReady(ctx, "ns-1", "ksvc-0", 100*time.Millisecond)
+		assert.ErrorContains(t, err, "RevisionMissing: Configuration does not have any ready Revision.")
+
+		// the services without the labels of the run are not watched
+		_, err = watcher.waitReady(ctx, "ns-2", "ksvc-0", 10*time.Millisecond)
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	t.Run("wait for the service to be ready", func(t *testing.T) {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			fakeServing.Services("ns-1").UpdateStatus(ctx, readyService(svc, corev1.ConditionTrue, "", ""), metav1.UpdateOptions{})
+		}()
+		start := time.Now()
+		readyAt, err := watcher.waitReady(ctx, "ns-1", "ksvc-0", 5*time.Second)
+		assert.NilError(t, err)
+		assert.Assert(t, readyAt.After(start))
+
+		// the time the service was first observed ready is kept
+		again, err := watcher.waitReady(ctx, "ns-1", "ksvc-0", time.Millisecond)
+		assert.NilError(t, err)
+		assert.Equal(t, readyAt, again)
+	})
+
+	t.Run("stop waiting when cancelled", func(t *testing.T) {
+		waitCtx, waitCancel := context.WithCancel(ctx)
+		waitCancel()
+		_, err := watcher.waitReady(waitCtx, "ns-1", "ksvc-1", 5*time.Second)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
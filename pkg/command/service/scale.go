@@ -21,6 +21,9 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"sync"
 	"time"
 
@@ -35,10 +38,11 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 
 	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/mutation"
+	"knative.dev/kperf/pkg/target"
 
 	"knative.dev/serving/pkg/apis/serving"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
-	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
 )
 
 const (
@@ -68,6 +72,9 @@ kperf service scale --svc-perfix svc --range 1,200 --namespace ns --concurrency
 			if cmd.Flags().NFlag() == 0 {
 				return fmt.Errorf("'service scale' requires flag(s)")
 			}
+			if cmd.Flags().Changed("time-interval") {
+				fmt.Printf("Warning: --time-interval is deprecated and ignored, each scale up waits for the service to be scaled to zero, use --scale-to-zero-timeout\n")
+			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -76,13 +83,16 @@ kperf service scale --svc-perfix svc --range 1,200 --namespace ns --concurrency
 	}
 
 	serviceScaleCommand.Flags().StringVarP(&scaleArgs.Svc, "svc", "", "", "Service name")
-	serviceScaleCommand.Flags().StringVarP(&scaleArgs.SvcRange, "range", "r", "", "Desired service range")
+	serviceScaleCommand.Flags().StringVarP(&scaleArgs.SvcRange, "range", "r", "", "Desired service range like 1,500 or 1-5,8,20-30")
 	serviceScaleCommand.Flags().StringVarP(&scaleArgs.Namespace, "namespace", "", "", "Service namespace")
 	serviceScaleCommand.Flags().StringVarP(&scaleArgs.SvcPrefix, "svc-prefix", "", "", "Service name prefix")
+	serviceScaleCommand.Flags().StringVarP(&scaleArgs.RunID, "run-id", "", "", "Only select the services created by the kperf run with this ID, svc and svc-prefix are optional if set")
 	serviceScaleCommand.Flags().BoolVarP(&scaleArgs.Verbose, "verbose", "v", false, "Service verbose result")
-	serviceScaleCommand.Flags().StringVarP(&scaleArgs.NamespaceRange, "namespace-range", "", "", "Service namespace range")
+	serviceScaleCommand.Flags().StringVarP(&scaleArgs.NamespaceRange, "namespace-range", "", "", "Service namespace range like 1,500 or 1-5,8,20-30")
 	serviceScaleCommand.Flags().StringVarP(&scaleArgs.NamespacePrefix, "namespace-prefix", "", "", "Service namespace prefix")
-	serviceScaleCommand.Flags().IntVarP(&scaleArgs.Concurrency, "concurrency", "c", 10, "Number of workers to do measurement job")
+	serviceScaleCommand.Flags().IntVarP(&scaleArgs.Concurrency, "concurrency", "c", 10, "Number of services scaled at the same time")
+	serviceScaleCommand.Flags().DurationVarP(&scaleArgs.Stagger, "stagger", "", 0, "Minimum time between the starts of two services")
+	serviceScaleCommand.Flags().BoolVarP(&scaleArgs.Herd, "herd", "", false, "Scale all the services from zero at the same instant in each iteration, once all of them are at zero. The requests are prepared with at most --concurrency at a time, each service holding a Deployment watch until the release, so at most "+strconv.Itoa(maxHerdServices)+" services are accepted")
 	serviceScaleCommand.Flags().StringVarP(&scaleArgs.Output, "output", "o", ".", "Measure result location")
 	serviceScaleCommand.Flags().BoolVarP(&scaleArgs.ResolvableDomain, "resolvable", "", false, "If Service endpoint resolvable url")
 	serviceScaleCommand.Flags().IntVarP(&scaleArgs.MaxRetries, "MaxRetries", "", 10, "Maximum number of trying to poll the service")
@@ -90,14 +100,27 @@ kperf service scale --svc-perfix svc --range 1,200 --namespace ns --concurrency
 	serviceScaleCommand.Flags().DurationVarP(&scaleArgs.RequestTimeout, "timeout", "", 2*time.Second, "Duration to wait for Knative Service to be ready")
 	serviceScaleCommand.Flags().BoolVarP(&scaleArgs.Https, "https", "", false, "Use https with TLS")
 	serviceScaleCommand.Flags().IntVarP(&scaleArgs.Iterations, "iterations", "i", 1, "Number of iterations to invoke the service")
-	serviceScaleCommand.Flags().DurationVarP(&scaleArgs.TimeInterval, "time-interval", "T", 10*time.Second, "The time interval of each scale up, recommend to set it no less than the sum of the stable window and cold startup time")
+	serviceScaleCommand.Flags().DurationVarP(&scaleArgs.ScaleToZeroTimeout, "scale-to-zero-timeout", "", 2*time.Minute, "Duration to wait for the service to be scaled to zero before each scale up, the iteration being warm and excluded from the latencies if it is not")
+	var timeInterval time.Duration
+	serviceScaleCommand.Flags().DurationVarP(&timeInterval, "time-interval", "T", 10*time.Second, "The time interval of each scale up")
+	// the flag is kept for the existing scripts, with a warning when set
+	serviceScaleCommand.Flags().MarkHidden("time-interval")
 	serviceScaleCommand.Flags().StringVarP(&scaleArgs.StableWindow, "stable-window", "s", "6s", "stable window per revision")
+	serviceScaleCommand.Flags().StringVarP(&scaleArgs.JournalDir, "journal-dir", "", mutation.DefaultDir(), "Directory of the journal recording the changes to the cluster until they are restored")
+	addTargetFlags(serviceScaleCommand, &scaleArgs.Selector, &scaleArgs.SvcRegex, &scaleArgs.NamespaceSelector, &scaleArgs.NamespaceRegex)
 	return serviceScaleCommand
 }
 
 func ScaleServicesUpFromZero(params *pkg.PerfParams, inputs pkg.ScaleArgs) error {
-	ctx := context.Background()
-	nsNameList, err := GetNamespaces(ctx, params, inputs.Namespace, inputs.NamespaceRange, inputs.NamespacePrefix)
+	// The first interrupt stops the scale and restores the changes to the cluster, a second one exits
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	defer stop()
+	nsNameList, err := target.Namespaces{Name: inputs.Namespace, Prefix: inputs.NamespacePrefix, Range: inputs.NamespaceRange,
+		Selector: inputs.NamespaceSelector, Regex: inputs.NamespaceRegex}.Resolve(ctx, params.ClientSet)
 	if err != nil {
 		return err
 	}
@@ -120,16 +143,18 @@ func ScaleServicesUpFromZero(params *pkg.PerfParams, inputs pkg.ScaleArgs) error
 		"svc_latency_avg", "svc_latency_min", "svc_latency_max",
 		"svc_latency_p50", "svc_latency_p90", "svc_latency_p95", "svc_latency_p99",
 		"deployment_latency_avg", "deployment_latency_min", "deployment_latency_max",
-		"deployment_latency_p50", "deployment_latency_p90", "deployment_latency_p95", "deployment_latency_p99"}},
+		"deployment_latency_p50", "deployment_latency_p90", "deployment_latency_p95", "deployment_latency_p99",
+		"cold_iterations", "warm_iterations"}},
 		rows...)
 
 	for _, m := range scaleFromZeroResult.Measurment {
 		rows = append(rows, []string{
 			m.ServiceName, m.ServiceNamespace,
-			fmt.Sprintf("%f", m.ServiceLatency.Average), fmt.Sprintf("%f", m.ServiceLatency.Min), fmt.Sprintf("%f", m.ServiceLatency.Max),
-			fmt.Sprintf("%f", m.ServiceLatency.P50), fmt.Sprintf("%f", m.ServiceLatency.P90), fmt.Sprintf("%f", m.ServiceLatency.P95), fmt.Sprintf("%f", m.ServiceLatency.P99),
-			fmt.Sprintf("%f", m.DeploymentLatency.Average), fmt.Sprintf("%f", m.DeploymentLatency.Min), fmt.Sprintf("%f", m.DeploymentLatency.Max),
-			fmt.Sprintf("%f", m.DeploymentLatency.P50), fmt.Sprintf("%f", m.DeploymentLatency.P90), fmt.Sprintf("%f", m.DeploymentLatency.P95), fmt.Sprintf("%f", m.DeploymentLatency.P99)})
+			formatSeconds(m.ServiceLatency.Average), formatSeconds(m.ServiceLatency.Min), formatSeconds(m.ServiceLatency.Max),
+			formatSeconds(m.ServiceLatency.P50), formatSeconds(m.ServiceLatency.P90), formatSeconds(m.ServiceLatency.P95), formatSeconds(m.ServiceLatency.P99),
+			formatSeconds(m.DeploymentLatency.Average), formatSeconds(m.DeploymentLatency.Min), formatSeconds(m.DeploymentLatency.Max),
+			formatSeconds(m.DeploymentLatency.P50), formatSeconds(m.DeploymentLatency.P90), formatSeconds(m.DeploymentLatency.P95), formatSeconds(m.DeploymentLatency.P99),
+			fmt.Sprintf("%d", m.ColdIterations), fmt.Sprintf("%d", m.WarmIterations)})
 	}
 
 	// generate CSV, HTML and JSON outputs from rows and scaleFromZeroResult
@@ -138,86 +163,185 @@ func ScaleServicesUpFromZero(params *pkg.PerfParams, inputs pkg.ScaleArgs) error
 		fmt.Printf("failed to generate output: %s\n", err)
 		return err
 	}
+	if len(scaleFromZeroResult.Herd) > 0 {
+		err = GenerateOutput(inputs.Output, HerdOutputFilename, true, false, false, herdRows(scaleFromZeroResult.Herd), nil)
+		if err != nil {
+			fmt.Printf("failed to generate output: %s\n", err)
+			return err
+		}
+	}
 
 	return nil
 }
 
-func scaleAndMeasure(ctx context.Context, params *pkg.PerfParams, inputs pkg.ScaleArgs, nsNameList []string, servicesListFunc func(context.Context, servingv1client.ServingV1Interface, []string, string, string, string) ([]ServicesToScale, error)) (pkg.ScaleResult, error) {
+func scaleAndMeasure(ctx context.Context, params *pkg.PerfParams, inputs pkg.ScaleArgs, nsNameList []string, listServices servicesListFunc) (pkg.ScaleResult, error) {
 	result := pkg.ScaleResult{}
+	if inputs.Concurrency <= 0 {
+		return result, fmt.Errorf("concurrency must be positive, given %d", inputs.Concurrency)
+	}
 	ksvcClient, err := params.NewServingClient()
 	if err != nil {
 		return result, err
 	}
-	objs, err := servicesListFunc(ctx, ksvcClient, nsNameList, inputs.SvcPrefix, inputs.SvcRange, inputs.Svc)
+	objs, err := listServices(ctx, ksvcClient, nsNameList, target.Services{Name: inputs.Svc, Prefix: inputs.SvcPrefix, Range: inputs.SvcRange,
+		Selector: inputs.Selector, Regex: inputs.SvcRegex, RunID: inputs.RunID})
 	if err != nil {
 		return result, err
 	}
-	count := len(objs)
+	// the herd is checked before changing anything in the cluster
+	if inputs.Herd && len(objs) > maxHerdServices {
+		return result, fmt.Errorf("--herd scales at most %d services at once, given %d", maxHerdServices, len(objs))
+	}
+
+	// every change to the cluster is recorded before being made, and restored even if the scale fails or is
+	// interrupted, or by 'kperf restore' if kperf is killed
+	runID := inputs.RunID
+	if runID == "" {
+		runID = NewRunID()
+	}
+	journal, err := mutation.Open(inputs.JournalDir, runID)
+	if err != nil {
+		return result, err
+	}
+	fmt.Printf("Recording the changes to the cluster with run ID %s, restore them with 'kperf restore --run-id %s' if kperf is killed\n", runID, runID)
+	defer func() {
+		// the changes are restored even if ctx is cancelled by an interrupt
+		if err := journal.Restore(context.Background(), mutation.NewPatcher(params)); err != nil {
+			fmt.Printf("%s, restore them with 'kperf restore --run-id %s'\n", err, runID)
+		}
+	}()
+
 	// update configmap-autosacle, set allow-zero-initial-scale to true
-	origin, err := updateAllowZeroInitialScale(ctx, params, "knative-serving", "true")
+	err = updateAllowZeroInitialScale(ctx, params, journal, "knative-serving", "true")
 	if err != nil {
 		fmt.Printf("failed to set allow-zero-initial-scale: %s", err)
 		return result, err
 	}
-	// restore configmap
-	defer updateAllowZeroInitialScale(ctx, params, "knative-serving", origin)
 
-	var wg sync.WaitGroup
 	var m sync.Mutex
-	wg.Add(count)
-	for i := 0; i < count; i++ {
-		go func(ndx int, m *sync.Mutex) {
-			defer wg.Done()
-			// set stable window and initial scale to speed up scaling to zero
-			err := updateKsvc(ctx, ksvcClient, objs[ndx].Namespace, objs[ndx].Service.Name, inputs.StableWindow, InitialScale)
-			if err != nil {
+	if inputs.Herd {
+		// the services are prepared by the pool of workers, then scaled from zero all at once
+		prepared := []ServicesToScale{}
+		result.Schedule = runServicePool(ctx, objs, inputs.Concurrency, inputs.Stagger, func(obj ServicesToScale) {
+			if err := updateKsvc(ctx, params, journal, obj.Service, inputs.StableWindow, InitialScale); err != nil {
 				fmt.Printf("failed to set stable window: %s", err)
 				return
 			}
+			m.Lock()
+			prepared = append(prepared, obj)
+			m.Unlock()
+		})
+		result.Herd, result.Measurment = herdScaleAndMeasure(ctx, params, inputs, prepared)
+		return result, nil
+	}
 
-			fmt.Printf("scale up service %s/%s in %d iterations:\n", objs[ndx].Namespace, objs[ndx].Service.Name, inputs.Iterations)
-			var svcLatencyList, dpLatencyList []float64
-
-			// Iterate inputs.Iterations times to get latency(average, max, min, p50...) of scaling service up from zero
-			for j := 0; j < inputs.Iterations; j++ {
-				time.Sleep(inputs.TimeInterval)
-				sdur, ddur, err := runScaleFromZero(ctx, params, inputs, objs[ndx].Namespace, objs[ndx].Service)
-				if err == nil {
-					svcLatencyList = append(svcLatencyList, sdur.Seconds())
-					dpLatencyList = append(dpLatencyList, ddur.Seconds())
-				} else {
-					fmt.Printf("result of scale is error: %s", err)
-					return
-				}
+	// the services are scaled by a bounded pool of workers, not all at once
+	result.Schedule = runServicePool(ctx, objs, inputs.Concurrency, inputs.Stagger, func(obj ServicesToScale) {
+		// set stable window and initial scale to speed up scaling to zero
+		err := updateKsvc(ctx, params, journal, obj.Service, inputs.StableWindow, InitialScale)
+		if err != nil {
+			fmt.Printf("failed to set stable window: %s", err)
+			return
+		}
+
+		fmt.Printf("scale up service %s/%s in %d iterations:\n", obj.Namespace, obj.Service.Name, inputs.Iterations)
+		var svcLatencyList, dpLatencyList []float64
+		var iterations []pkg.ScaleIterationResult
+		coldIterations := 0
+
+		// Iterate inputs.Iterations times to get latency(average, max, min, p50...) of scaling service up from zero
+		for j := 0; j < inputs.Iterations; j++ {
+			if ctx.Err() != nil {
+				fmt.Printf("scale of service %s/%s interrupted\n", obj.Namespace, obj.Service.Name)
+				return
+			}
+			// a request served before the service is scaled to zero is not a cold start, so it is not measured
+			wait, cold, err := waitScaledToZero(ctx, params, obj.Namespace, obj.Service.Name, inputs.ScaleToZeroTimeout)
+			if err != nil {
+				fmt.Printf("failed to wait for service %s/%s to scale to zero: %s\n", obj.Namespace, obj.Service.Name, err)
+				return
 			}
-			fmt.Printf("====================== service %s/%s result =====================\n", objs[ndx].Namespace, objs[ndx].Service.Name)
-			if inputs.Verbose {
-				for k := 0; k < len(svcLatencyList); k++ {
-					fmt.Printf("iteration %4d, service latency: %f s, deployment latency: %f s\n", k, svcLatencyList[k], dpLatencyList[k])
+			if !cold {
+				fmt.Printf("service %s/%s is not scaled to zero after %s, iteration %d is warm and excluded from the latencies\n",
+					obj.Namespace, obj.Service.Name, inputs.ScaleToZeroTimeout, j)
+			}
+			start := time.Now()
+			sdur, ddur, err := runScaleFromZero(ctx, params, inputs, obj.Namespace, obj.Service)
+			if err != nil {
+				fmt.Printf("result of scale is error: %s", err)
+				return
+			}
+			if cold {
+				svcLatencyList = append(svcLatencyList, sdur.Seconds())
+				dpLatencyList = append(dpLatencyList, ddur.Seconds())
+				coldIterations++
+			}
+			iteration := pkg.ScaleIterationResult{Iteration: j, Cold: cold, ScaleToZeroWait: wait.Seconds(),
+				ServiceLatency: sdur.Seconds(), DeploymentLatency: ddur.Seconds()}
+			iteration.PodName, iteration.PodStartup, err = scaledPodStartup(ctx, params, obj.Namespace, obj.Service.Name, start)
+			if err != nil {
+				fmt.Printf("failed to get the startup of the pod: %s\n", err)
+			}
+			iterations = append(iterations, iteration)
+		}
+		fmt.Printf("====================== service %s/%s result =====================\n", obj.Namespace, obj.Service.Name)
+		if inputs.Verbose {
+			for _, iteration := range iterations {
+				temperature := "cold"
+				if !iteration.Cold {
+					temperature = "warm"
+				}
+				fmt.Printf("iteration %4d (%s), service latency: %f s, deployment latency: %f s, waited %f s for scale to zero\n",
+					iteration.Iteration, temperature, iteration.ServiceLatency, iteration.DeploymentLatency, iteration.ScaleToZeroWait)
+				if startup := iteration.PodStartup; startup != nil {
+					fmt.Printf("               pod %s scheduling: %f s, image pull: %f s, container creation: %f s, container start: %f s, readiness probe wait: %f s, probe failures: %d\n",
+						iteration.PodName, startup.Scheduling, startup.ImagePull, startup.ContainerCreation, startup.ContainerStart, startup.ReadinessProbeWait, startup.ProbeFailures)
 				}
 			}
-			fmt.Printf("service latency result:\n")
-			svcLatencyResult := latencyResultHandler(svcLatencyList)
-			fmt.Printf("deployment latency result:\n")
-			dpLatencyResult := latencyResultHandler(dpLatencyList)
-
-			m.Lock()
-			result.Measurment = append(result.Measurment, pkg.ScaleFromZeroResult{
-				ServiceName:       objs[ndx].Service.Name,
-				ServiceNamespace:  objs[ndx].Service.Namespace,
-				ServiceLatency:    svcLatencyResult,
-				DeploymentLatency: dpLatencyResult,
-			})
-			m.Unlock()
-		}(i, &m)
-	}
-	wg.Wait()
+		}
+		fmt.Printf("service latency result:\n")
+		svcLatencyResult := latencyResultHandler(svcLatencyList)
+		fmt.Printf("deployment latency result:\n")
+		dpLatencyResult := latencyResultHandler(dpLatencyList)
+
+		m.Lock()
+		result.Measurment = append(result.Measurment, pkg.ScaleFromZeroResult{
+			ServiceName:       obj.Service.Name,
+			ServiceNamespace:  obj.Service.Namespace,
+			ServiceLatency:    svcLatencyResult,
+			DeploymentLatency: dpLatencyResult,
+			Iterations:        iterations,
+			ColdIterations:    coldIterations,
+			WarmIterations:    len(iterations) - coldIterations,
+		})
+		m.Unlock()
+	})
 
 	return result, nil
 }
 
 func runScaleFromZero(ctx context.Context, params *pkg.PerfParams, inputs pkg.ScaleArgs, namespace string, svc *servingv1.Service) (
 	time.Duration, time.Duration, error) {
+	scale, err := prepareScaleFromZero(ctx, params, inputs, namespace, svc)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer scale.stop()
+	return scale.run(inputs)
+}
+
+// scaleFromZero is a request to a Knative Service at zero, with the watch of its deployment, ready to be sent
+type scaleFromZero struct {
+	svc      *servingv1.Service
+	watcher  watch.Interface
+	client   http.Client
+	req      *http.Request
+	endpoint string
+}
+
+// prepareScaleFromZero watches the deployment of the Knative Service and builds the request to its endpoint, so that
+// sending the request takes no other call to the cluster
+func prepareScaleFromZero(ctx context.Context, params *pkg.PerfParams, inputs pkg.ScaleArgs, namespace string, svc *servingv1.Service) (*scaleFromZero, error) {
 	selector := labels.SelectorFromSet(labels.Set{
 		serving.ServiceLabelKey: svc.Name,
 	})
@@ -227,28 +351,36 @@ func runScaleFromZero(ctx context.Context, params *pkg.PerfParams, inputs pkg.Sc
 	if err != nil {
 		m := fmt.Sprintf("unable to watch the deployment for the service: %v", err)
 		log.Println(m)
-		return 0, 0, errors.New(m)
+		return nil, errors.New(m)
 	}
-	defer watcher.Stop()
-
-	ddch := watcher.ResultChan()
-	sdch := make(chan struct{})
-	errch := make(chan error)
 
 	endpoint, err := resolveEndpoint(ctx, params, inputs.ResolvableDomain, inputs.Https, svc)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get the cluster endpoint: %w", err)
+		watcher.Stop()
+		return nil, fmt.Errorf("failed to get the cluster endpoint: %w", err)
 	}
 
-	client := http.Client{}
 	req, _ := http.NewRequest("GET", endpoint, nil)
 	req.Host = svc.Status.RouteStatusFields.URL.URL().Host
+	return &scaleFromZero{svc: svc, watcher: watcher, client: http.Client{}, req: req, endpoint: endpoint}, nil
+}
+
+func (s *scaleFromZero) stop() {
+	s.watcher.Stop()
+}
+
+// run sends the request until it is served, and returns the durations from the request to the response and to the
+// change of the replicas of the deployment
+func (s *scaleFromZero) run(inputs pkg.ScaleArgs) (time.Duration, time.Duration, error) {
+	ddch := s.watcher.ResultChan()
+	sdch := make(chan struct{})
+	errch := make(chan error)
 
 	start := time.Now()
 	go func() {
-		_, err = Poll(client, req, inputs.MaxRetries, inputs.RequestInterval, inputs.RequestTimeout, endpoint)
+		_, err := Poll(s.client, s.req, inputs.MaxRetries, inputs.RequestInterval, inputs.RequestTimeout, s.endpoint)
 		if err != nil {
-			m := fmt.Sprintf("the endpoint for Route %q at %q didn't serve the expected text %v", svc.Name, endpoint, err)
+			m := fmt.Sprintf("the endpoint for Route %q at %q didn't serve the expected text %v", s.svc.Name, s.endpoint, err)
 			log.Println(m)
 			errch <- errors.New(m)
 			return
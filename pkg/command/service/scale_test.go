@@ -16,6 +16,7 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -24,6 +25,9 @@ import (
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	clienttesting "k8s.io/client-go/testing"
 	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/mutation"
+	"knative.dev/kperf/pkg/target"
+	"knative.dev/kperf/pkg/testutil"
 	networkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1"
 	fakenetworkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1/fake"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
@@ -40,7 +44,11 @@ func TestScaleServces(t *testing.T) {
 		},
 	}
 
-	client := k8sfake.NewSimpleClientset(ns)
+	autoscalerConfig := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "config-autoscaler", Namespace: "knative-serving"},
+		Data:       map[string]string{"allow-zero-initial-scale": "false"},
+	}
+	client := k8sfake.NewSimpleClientset(ns, autoscalerConfig)
 	fakeAutoscaling := &autoscalingv1fake.FakeAutoscalingV1alpha1{Fake: &clienttesting.Fake{}}
 	autoscalingClient := func() (autoscalingv1client.AutoscalingV1alpha1Interface, error) {
 		return fakeAutoscaling, nil
@@ -65,12 +73,15 @@ func TestScaleServces(t *testing.T) {
 
 	//"--svc-prefix", "svc", "--namespace", "ns1", "--range", "1,1")
 	scaleArgs := pkg.ScaleArgs{
-		SvcPrefix: "ksvc",
-		Namespace: "ns-1",
-		SvcRange:  "1,1",
+		SvcPrefix:   "ksvc",
+		Namespace:   "ns-1",
+		SvcRange:    "1,1",
+		RunID:       "run-1",
+		Concurrency: 1,
+		JournalDir:  t.TempDir(),
 	}
 
-	getFakeServices := func(context.Context, servingv1client.ServingV1Interface, []string, string, string, string) ([]ServicesToScale, error) {
+	getFakeServices := func(context.Context, servingv1client.ServingV1Interface, []string, target.Services) ([]ServicesToScale, error) {
 		objs := []ServicesToScale{}
 		svc := ServicesToScale{
 			Service: &servingv1.Service{
@@ -91,6 +102,75 @@ func TestScaleServces(t *testing.T) {
 		return objs, nil
 	}
 
-	_, err := scaleAndMeasure(context.TODO(), p, scaleArgs, []string{"ns-1"}, getFakeServices)
+	result, err := scaleAndMeasure(context.TODO(), p, scaleArgs, []string{"ns-1"}, getFakeServices)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, result.Schedule.Services)
+	assert.Equal(t, 1, len(result.Schedule.Runs))
+
+	scaleArgs.Concurrency = 0
+	_, err = scaleAndMeasure(context.TODO(), p, scaleArgs, []string{"ns-1"}, getFakeServices)
+	assert.ErrorContains(t, err, "concurrency must be positive, given 0")
+
+	// a herd too large fails before changing the cluster
+	scaleArgs.Concurrency = 1
+	scaleArgs.Herd = true
+	getHerdServices := func(context.Context, servingv1client.ServingV1Interface, []string, target.Services) ([]ServicesToScale, error) {
+		return make([]ServicesToScale, maxHerdServices+1), nil
+	}
+	_, err = scaleAndMeasure(context.TODO(), p, scaleArgs, []string{"ns-1"}, getHerdServices)
+	assert.ErrorContains(t, err, fmt.Sprintf("--herd scales at most %d services at once, given %d", maxHerdServices, maxHerdServices+1))
+
+	// the changes to the cluster are restored and the journal removed
+	cfgm, err := client.CoreV1().ConfigMaps("knative-serving").Get(context.TODO(), "config-autoscaler", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, "false", cfgm.Data["allow-zero-initial-scale"])
+	_, err = mutation.Load(scaleArgs.JournalDir, "run-1")
+	assert.ErrorIs(t, err, mutation.ErrNotFound)
+}
+
+func TestGetServices(t *testing.T) {
+	runService := func(name, runID string) *servingv1.Service {
+		svc := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns-1"}}
+		if runID != "" {
+			svc.Labels = pkg.RunLabels(runID)
+		}
+		return svc
+	}
+	fakeServing := &servingv1fake.FakeServingV1{Fake: testutil.NewKnativeFake(
+		runService("ksvc-1", "run-1"), runService("ksvc-2", "run-2"), runService("other-1", "run-1"), runService("ksvc-3", ""))}
+	ctx := context.Background()
+	names := func(objs []ServicesToScale) []string {
+		result := []string{}
+		for _, obj := range objs {
+			result = append(result, obj.Service.Name)
+		}
+		return result
+	}
+
+	objs, err := getServices(ctx, fakeServing, []string{"ns-1"}, target.Services{Prefix: "ksvc", Range: "1,3"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"ksvc-1", "ksvc-2", "ksvc-3"}, names(objs))
+
+	objs, err = getServices(ctx, fakeServing, []string{"ns-1"}, target.Services{Prefix: "ksvc", Range: "1,3", RunID: "run-1"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"ksvc-1"}, names(objs))
+
+	objs, err = getServices(ctx, fakeServing, []string{"ns-1"}, target.Services{RunID: "run-1"})
 	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"ksvc-1", "other-1"}, names(objs))
+
+	// the services are selected by prefix only without range
+	objs, err = getServices(ctx, fakeServing, []string{"ns-1"}, target.Services{Prefix: "ksvc"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"ksvc-1", "ksvc-2", "ksvc-3"}, names(objs))
+
+	objs, err = getServices(ctx, fakeServing, []string{"ns-1"}, target.Services{Selector: pkg.RunIDLabelKey + " in (run-1,run-2)", Regex: "^ksvc-"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"ksvc-1", "ksvc-2"}, names(objs))
+
+	_, err = getServices(ctx, fakeServing, []string{"ns-1"}, target.Services{Name: "ksvc-3", RunID: "run-1"})
+	assert.ErrorContains(t, err, "no ksvc found with run ID run-1")
+
+	_, err = getServices(ctx, fakeServing, []string{"ns-1"}, target.Services{})
+	assert.ErrorContains(t, err, "both svc and svc-prefix are empty")
 }
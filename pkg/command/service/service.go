@@ -33,6 +33,8 @@ kperf service measure --range 1,10, --name perf - to measure 10 Knative service
 	serviceCmd.AddCommand(NewServiceCleanCommand(p))
 	serviceCmd.AddCommand(NewServiceScaleCommand(p))
 	serviceCmd.AddCommand(NewServiceLoadCommand(p))
+	serviceCmd.AddCommand(NewServiceUpdateCommand(p))
+	serviceCmd.AddCommand(NewServiceTrafficCommand(p))
 
 	serviceCmd.InitDefaultHelpCmd()
 	return serviceCmd
@@ -35,4 +35,10 @@ func TestNewServiceCmd(t *testing.T) {
 
 	_, _, err = cmd.Find([]string{"load"})
 	assert.NilError(t, err, "service command should have load subcommand")
+
+	_, _, err = cmd.Find([]string{"update"})
+	assert.NilError(t, err, "service command should have update subcommand")
+
+	_, _, err = cmd.Find([]string{"traffic"})
+	assert.NilError(t, err, "service command should have traffic subcommand")
 }
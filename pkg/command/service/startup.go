@@ -0,0 +1,205 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/serving/pkg/apis/serving"
+
+	"knative.dev/kperf/pkg"
+)
+
+// The reasons of the Events of the scheduler and of the kubelet for the startup of a pod
+const (
+	eventScheduled = "Scheduled"
+	eventPulling   = "Pulling"
+	eventPulled    = "Pulled"
+	eventCreated   = "Created"
+	eventStarted   = "Started"
+	// eventUnhealthy is reported by the kubelet for the failures of the liveness, readiness and startup probes
+	eventUnhealthy = "Unhealthy"
+)
+
+// podEvent is a core/v1 Event of a pod
+type podEvent struct {
+	reason string
+	// time is the eventTime, or the first timestamp with a second precision for the Events of the kubelet
+	time  measuredTime
+	count int32
+}
+
+// listPodEvents returns the Events of the pod
+func listPodEvents(ctx context.Context, params *pkg.PerfParams, pod *corev1.Pod) ([]podEvent, error) {
+	events, err := params.ClientSet.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=Pod,involvedObject.name=%s", pod.Name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Events of Pod %s: %w", pod.Name, err)
+	}
+	result := make([]podEvent, 0, len(events.Items))
+	for _, event := range events.Items {
+		if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Name != pod.Name {
+			continue
+		}
+		podEvent := podEvent{reason: event.Reason, count: event.Count}
+		switch {
+		case !event.EventTime.IsZero():
+			podEvent.time = measuredTime{Time: event.EventTime.Time, Source: TimestampSourceEvent}
+		case !event.FirstTimestamp.IsZero():
+			podEvent.time = apiTime(event.FirstTimestamp)
+		default:
+			podEvent.time = apiTime(event.GetCreationTimestamp())
+		}
+		if podEvent.count == 0 {
+			podEvent.count = 1
+		}
+		result = append(result, podEvent)
+	}
+	return result, nil
+}
+
+// podStartup returns the breakdown of the startup of the pod from its Events, nil if the kubelet reported no
+// container started, like when the Events expired. As the containers are pulled, created and started one after the
+// other, the phases run from the first to the last Event of each reason:
+//   - Scheduling from the pod creation to the Scheduled Event
+//   - ImagePull from the first Pulling to the last Pulled Event, zero if the images are present on the node
+//   - ContainerCreation from the last Pulled Event, or the Scheduled Event without any, to the last Created Event
+//   - ContainerStart from the last Created to the last Started Event
+//   - ReadinessProbeWait from the last Started Event to the ContainersReady condition
+func podStartup(pod *corev1.Pod, events []podEvent) *pkg.PodStartup {
+	first := map[string]measuredTime{}
+	last := map[string]measuredTime{}
+	startup := &pkg.PodStartup{}
+	for _, event := range events {
+		if event.reason == eventUnhealthy {
+			startup.ProbeFailures += event.count
+			continue
+		}
+		if t, exists := first[event.reason]; !exists || event.time.Time.Before(t.Time) {
+			first[event.reason] = event.time
+		}
+		if t, exists := last[event.reason]; !exists || event.time.Time.After(t.Time) {
+			last[event.reason] = event.time
+		}
+	}
+	if _, started := last[eventStarted]; !started {
+		return nil
+	}
+
+	created := apiTime(pod.GetCreationTimestamp())
+	scheduled, found := first[eventScheduled]
+	if !found {
+		if present, condition := getPodCondition(&pod.Status, corev1.PodScheduled); present != -1 {
+			scheduled = apiTime(condition.LastTransitionTime)
+		}
+	}
+	pulled, found := last[eventPulled]
+	if !found {
+		pulled = scheduled
+	}
+	containersReady := measuredTime{}
+	if present, condition := getPodCondition(&pod.Status, corev1.ContainersReady); present != -1 && condition.Status == corev1.ConditionTrue {
+		containersReady = apiTime(condition.LastTransitionTime)
+	}
+
+	startup.Scheduling = phaseSeconds(created, scheduled)
+	startup.ImagePull = phaseSeconds(first[eventPulling], last[eventPulled])
+	startup.ContainerCreation = phaseSeconds(pulled, last[eventCreated])
+	startup.ContainerStart = phaseSeconds(last[eventCreated], last[eventStarted])
+	startup.ReadinessProbeWait = phaseSeconds(last[eventStarted], containersReady)
+	return startup
+}
+
+// phaseSeconds returns the seconds from the start to the end of a phase, zero if one of them is not set or if the end
+// is before the start because of the second precision of the Events of the kubelet
+func phaseSeconds(start, end measuredTime) float64 {
+	if start.Time.IsZero() || end.Time.IsZero() || end.Time.Before(start.Time) {
+		return 0
+	}
+	return end.Sub(start).Seconds()
+}
+
+// startupResultHandler aggregates the startup phases of the pods
+func startupResultHandler(startups []*pkg.PodStartup) *pkg.PodStartupResult {
+	scheduling, imagePull, containerCreation, containerStart, readinessProbeWait := []float64{}, []float64{}, []float64{}, []float64{}, []float64{}
+	result := &pkg.PodStartupResult{}
+	for _, startup := range startups {
+		if startup == nil {
+			continue
+		}
+		scheduling = append(scheduling, startup.Scheduling)
+		imagePull = append(imagePull, startup.ImagePull)
+		containerCreation = append(containerCreation, startup.ContainerCreation)
+		containerStart = append(containerStart, startup.ContainerStart)
+		readinessProbeWait = append(readinessProbeWait, startup.ReadinessProbeWait)
+		result.ProbeFailures += startup.ProbeFailures
+	}
+	if len(scheduling) == 0 {
+		return nil
+	}
+	fmt.Printf("pod scheduling latency result:\n")
+	result.Scheduling = latencyResultHandler(scheduling)
+	fmt.Printf("pod image pull latency result:\n")
+	result.ImagePull = latencyResultHandler(imagePull)
+	fmt.Printf("pod container creation latency result:\n")
+	result.ContainerCreation = latencyResultHandler(containerCreation)
+	fmt.Printf("pod container start latency result:\n")
+	result.ContainerStart = latencyResultHandler(containerStart)
+	fmt.Printf("pod readiness probe wait latency result:\n")
+	result.ReadinessProbeWait = latencyResultHandler(readinessProbeWait)
+	fmt.Printf("pod probe failures: %d\n", result.ProbeFailures)
+	return result
+}
+
+// scaledPodStartup returns the name and the startup of the first pod of the service to be ready among the pods created
+// since the start of a scale from zero
+func scaledPodStartup(ctx context.Context, params *pkg.PerfParams, namespace, svcName string, since time.Time) (string, *pkg.PodStartup, error) {
+	podList, err := params.ClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", serving.ServiceLabelKey, svcName),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list Pods of service %s/%s: %w", namespace, svcName, err)
+	}
+	// the creation timestamp has a second precision
+	since = since.Truncate(time.Second)
+	var first *corev1.Pod
+	var firstReady time.Time
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.CreationTimestamp.Time.Before(since) {
+			continue
+		}
+		present, condition := getPodCondition(&pod.Status, corev1.ContainersReady)
+		if present == -1 || condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		if first == nil || condition.LastTransitionTime.Time.Before(firstReady) {
+			first, firstReady = pod, condition.LastTransitionTime.Time
+		}
+	}
+	if first == nil {
+		return "", nil, fmt.Errorf("no ready Pod of service %s/%s created since %s", namespace, svcName, since.Format(time.RFC3339))
+	}
+	events, err := listPodEvents(ctx, params, first)
+	if err != nil {
+		return first.Name, nil, err
+	}
+	return first.Name, podStartup(first, events), nil
+}
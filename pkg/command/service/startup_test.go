@@ -0,0 +1,118 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"knative.dev/serving/pkg/apis/serving"
+
+	"knative.dev/kperf/pkg"
+)
+
+// newStartupPod returns a pod of the service ksvc-1 created and ready at the seconds from start, with the Events of its
+// startup at the seconds from start by reason
+func newStartupPod(start time.Time, name string, created, ready float64, events map[string]float64) []runtime.Object {
+	at := func(seconds float64) metav1.Time {
+		return metav1.NewTime(start.Add(time.Duration(seconds * float64(time.Second))))
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns-1", CreationTimestamp: at(created),
+			Labels: map[string]string{serving.ServiceLabelKey: "ksvc-1"}},
+		Status: corev1.PodStatus{Conditions: []corev1.PodCondition{
+			{Type: corev1.ContainersReady, Status: corev1.ConditionTrue, LastTransitionTime: at(ready)},
+		}},
+	}
+	objects := []runtime.Object{pod}
+	for reason, seconds := range events {
+		event := &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name + "." + reason, Namespace: "ns-1"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "ns-1", Name: name},
+			Reason:         reason,
+			FirstTimestamp: at(seconds),
+		}
+		switch reason {
+		case eventScheduled:
+			// the scheduler reports the Events with an eventTime
+			event.FirstTimestamp = metav1.Time{}
+			event.EventTime = metav1.NewMicroTime(at(seconds).Time)
+		case eventUnhealthy:
+			event.Count = 3
+		}
+		objects = append(objects, event)
+	}
+	return objects
+}
+
+func TestPodStartup(t *testing.T) {
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	client := k8sfake.NewSimpleClientset(newStartupPod(start, "pod-1", 0, 9, map[string]float64{
+		eventScheduled: 0.25, eventPulling: 1, eventPulled: 4, eventCreated: 5, eventStarted: 6, eventUnhealthy: 7,
+	})...)
+	params := &pkg.PerfParams{ClientSet: client}
+	pod, err := client.CoreV1().Pods("ns-1").Get(context.Background(), "pod-1", metav1.GetOptions{})
+	assert.NilError(t, err)
+
+	events, err := listPodEvents(context.Background(), params, pod)
+	assert.NilError(t, err)
+	assert.Equal(t, 6, len(events))
+	assert.Equal(t, TimestampSourceEvent, podEventTimes(events)[eventScheduled].Source)
+	_, found := podEventTimes(events)[eventPulled]
+	assert.Assert(t, !found)
+
+	assert.DeepEqual(t, &pkg.PodStartup{
+		Scheduling:         0.25,
+		ImagePull:          3,
+		ContainerCreation:  1,
+		ContainerStart:     1,
+		ReadinessProbeWait: 3,
+		ProbeFailures:      3,
+	}, podStartup(pod, events))
+
+	// the images are present on the node
+	startup := podStartup(pod, []podEvent{
+		{reason: eventScheduled, time: apiTime(metav1.NewTime(start.Add(time.Second)))},
+		{reason: eventCreated, time: apiTime(metav1.NewTime(start.Add(3 * time.Second)))},
+		{reason: eventStarted, time: apiTime(metav1.NewTime(start.Add(4 * time.Second)))},
+	})
+	assert.Equal(t, 0.0, startup.ImagePull)
+	assert.Equal(t, 2.0, startup.ContainerCreation)
+
+	// the Events expired
+	assert.Assert(t, podStartup(pod, nil) == nil)
+}
+
+func TestScaledPodStartup(t *testing.T) {
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	objects := newStartupPod(start, "pod-old", -60, -55, map[string]float64{eventStarted: -56})
+	objects = append(objects, newStartupPod(start, "pod-2", 0.5, 5, map[string]float64{eventStarted: 4})...)
+	objects = append(objects, newStartupPod(start, "pod-1", 0.5, 3, map[string]float64{eventStarted: 2})...)
+	params := &pkg.PerfParams{ClientSet: k8sfake.NewSimpleClientset(objects...)}
+
+	name, startup, err := scaledPodStartup(context.Background(), params, "ns-1", "ksvc-1", start.Add(300*time.Millisecond))
+	assert.NilError(t, err)
+	assert.Equal(t, "pod-1", name)
+	assert.Equal(t, 1.0, startup.ReadinessProbeWait)
+
+	_, _, err = scaledPodStartup(context.Background(), params, "ns-1", "ksvc-1", start.Add(time.Minute))
+	assert.ErrorContains(t, err, "no ready Pod of service ns-1/ksvc-1")
+}
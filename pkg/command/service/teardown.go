@@ -0,0 +1,332 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"knative.dev/kperf/pkg"
+)
+
+// TeardownOutputFilename is the name of the output files of the teardown measurement of clean
+const TeardownOutputFilename = "ksvc_teardown_time"
+
+// teardownPhases are the kinds whose objects are measured until they are all deleted, by the name of their phase
+var teardownPhases = []struct {
+	Name string
+	Kind string
+}{
+	{Name: "service_deleted", Kind: "Service"},
+	{Name: "revisions_deleted", Kind: "Revision"},
+	{Name: "deployments_deleted", Kind: "Deployment"},
+	{Name: "pods_deleted", Kind: "Pod"},
+	{Name: "ingress_deleted", Kind: "Ingress"},
+}
+
+// measureTeardown waits for the objects of the deleted Knative Services to be deleted, and saves their teardown
+// measured from the timeline recorded since before their deletion
+func measureTeardown(params *pkg.PerfParams, inputs pkg.CleanArgs, sources []timelineSource, recorder *timelineRecorder, deleted map[string]time.Time) error {
+	err := waitTeardown(context.Background(), recorder, deleted, inputs.Timeout)
+	if err != nil {
+		fmt.Printf("Stopped measuring the teardown before all the objects were deleted: %s\n", err)
+	}
+	remaining := remainingObjects(context.Background(), sources, deletedNamespaces(deleted))
+	services, namespaces := teardownResults(recorder.timeline(), deleted, remaining)
+
+	fmt.Printf("-------- Teardown Measurement --------\n")
+	result := teardownResultHandler(services, namespaces)
+	fmt.Printf("Total: %d | Torn down: %d\n", result.Total, result.TornDown)
+	knativeVersion := GetKnativeVersion(params)
+	ingressInfo := GetIngressController(params)
+	result.KnativeInfo.ServingVersion = knativeVersion["serving"]
+	result.KnativeInfo.EventingVersion = knativeVersion["eventing"]
+	result.KnativeInfo.IngressController = ingressInfo["ingressController"]
+	result.KnativeInfo.IngressVersion = ingressInfo["version"]
+
+	err = GenerateOutput(inputs.Output, TeardownOutputFilename, true, true, true, teardownRows(services), result)
+	if err != nil {
+		fmt.Printf("failed to generate output: %s\n", err)
+		return err
+	}
+	return nil
+}
+
+// pendingObjects returns the number of objects of the deleted Knative Services, by namespace/name key, seen in the
+// timeline and not deleted yet
+func pendingObjects(events []timelineEvent, deleted map[string]time.Time) int {
+	alive := map[string]bool{}
+	for _, event := range events {
+		if _, ok := deleted[event.Namespace+"/"+event.Service]; !ok {
+			continue
+		}
+		key := event.Kind + "/" + event.Namespace + "/" + event.Name
+		switch event.Condition {
+		case timelineCreated:
+			alive[key] = true
+		case timelineDeleted:
+			alive[key] = false
+		}
+	}
+	pending := 0
+	for _, a := range alive {
+		if a {
+			pending++
+		}
+	}
+	return pending
+}
+
+// waitTeardown waits for all the objects of the deleted Knative Services to be deleted
+func waitTeardown(ctx context.Context, recorder *timelineRecorder, deleted map[string]time.Time, timeout time.Duration) error {
+	pending := 0
+	err := wait.PollImmediateWithContext(ctx, timelinePollInterval, timeout, func(ctx context.Context) (bool, error) {
+		pending = pendingObjects(recorder.timeline(), deleted)
+		return pending == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%d objects of the Knative Services are not deleted: %w", pending, err)
+	}
+	return nil
+}
+
+// deletedNamespaces returns the namespaces of the deleted Knative Services by namespace/name key
+func deletedNamespaces(deleted map[string]time.Time) []string {
+	namespaces := []string{}
+	for key := range deleted {
+		ns, _, _ := strings.Cut(key, "/")
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return uniqueStrings(namespaces)
+}
+
+// remainingObjects returns the kind/name of the objects of the teardown kinds left in each namespace, whatever their
+// Knative Service or run. A namespace failing to be listed is left out, so that it is not reported empty
+func remainingObjects(ctx context.Context, sources []timelineSource, namespaces []string) map[string][]string {
+	kinds := map[string]bool{}
+	for _, phase := range teardownPhases {
+		kinds[phase.Kind] = true
+	}
+	remaining := map[string][]string{}
+	for _, ns := range namespaces {
+		objects, err := listObjects(ctx, sources, kinds, ns)
+		if err != nil {
+			fmt.Printf("failed to list the objects left in namespace %s: %s\n", ns, err)
+			continue
+		}
+		remaining[ns] = objects
+	}
+	return remaining
+}
+
+// listObjects returns the kind/name of the objects of the kinds in the namespace
+func listObjects(ctx context.Context, sources []timelineSource, kinds map[string]bool, ns string) ([]string, error) {
+	objects := []string{}
+	for _, source := range sources {
+		if !kinds[source.kind] {
+			continue
+		}
+		list, err := source.list(ctx, ns, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", source.kind, err)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if object, ok := item.(metav1.Object); ok {
+				objects = append(objects, source.kind+"/"+object.GetName())
+			}
+		}
+	}
+	return objects, nil
+}
+
+// teardownResults returns the teardown of each Knative Service deleted at the time by namespace/name key, from the
+// deletion to the time the last object of each kind is seen deleted, and of each namespace, from its first Knative
+// Service deleted to its last object seen deleted. A namespace is only complete if it is listed in remaining without
+// any object left, its other Knative Services keeping it from being empty
+func teardownResults(events []timelineEvent, deleted map[string]time.Time, remaining map[string][]string) ([]pkg.ServiceTeardownResult, []pkg.NamespaceTeardownResult) {
+	// lastDeleted is the time the last object of each kind is deleted by service key, pending the objects not deleted
+	lastDeleted := map[string]map[string]time.Time{}
+	pending := map[string]map[string]bool{}
+	for _, event := range events {
+		svcKey := event.Namespace + "/" + event.Service
+		if _, ok := deleted[svcKey]; !ok {
+			continue
+		}
+		key := event.Kind + "/" + event.Name
+		if pending[svcKey] == nil {
+			pending[svcKey], lastDeleted[svcKey] = map[string]bool{}, map[string]time.Time{}
+		}
+		switch event.Condition {
+		case timelineCreated:
+			pending[svcKey][key] = true
+		case timelineDeleted:
+			pending[svcKey][key] = false
+			if event.Time.After(lastDeleted[svcKey][event.Kind]) {
+				lastDeleted[svcKey][event.Kind] = event.Time
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(deleted))
+	for key := range deleted {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	services := make([]pkg.ServiceTeardownResult, 0, len(keys))
+	namespaceStart, namespaceEnd, namespaceComplete := map[string]time.Time{}, map[string]time.Time{}, map[string]bool{}
+	namespaces := []string{}
+	for _, key := range keys {
+		start := deleted[key]
+		ns, name, _ := strings.Cut(key, "/")
+		result := pkg.ServiceTeardownResult{ServiceName: name, ServiceNamespace: ns, Phases: map[string]float64{}, Complete: true}
+		end := start
+		for objKey, alive := range pending[key] {
+			if alive {
+				result.Complete = false
+				result.Pending = append(result.Pending, objKey)
+			}
+		}
+		sort.Strings(result.Pending)
+		for _, phase := range teardownPhases {
+			at, ok := lastDeleted[key][phase.Kind]
+			if !ok || kindPending(pending[key], phase.Kind) {
+				continue
+			}
+			result.Phases[phase.Name] = at.Sub(start).Seconds()
+			if at.After(end) {
+				end = at
+			}
+		}
+		for _, at := range lastDeleted[key] {
+			if at.After(end) {
+				end = at
+			}
+		}
+		if result.Complete {
+			result.TornDown = end.Sub(start).Seconds()
+		}
+		services = append(services, result)
+
+		if _, ok := namespaceComplete[ns]; !ok {
+			namespaces = append(namespaces, ns)
+			namespaceStart[ns], namespaceComplete[ns] = start, true
+		}
+		if start.Before(namespaceStart[ns]) {
+			namespaceStart[ns] = start
+		}
+		if end.After(namespaceEnd[ns]) {
+			namespaceEnd[ns] = end
+		}
+		namespaceComplete[ns] = namespaceComplete[ns] && result.Complete
+	}
+	sort.Strings(namespaces)
+	results := make([]pkg.NamespaceTeardownResult, 0, len(namespaces))
+	for _, ns := range namespaces {
+		objects, listed := remaining[ns]
+		result := pkg.NamespaceTeardownResult{Namespace: ns, Complete: namespaceComplete[ns] && listed && len(objects) == 0}
+		if len(objects) > 0 {
+			result.Remaining = append([]string{}, objects...)
+			sort.Strings(result.Remaining)
+		}
+		if result.Complete {
+			result.Empty = namespaceEnd[ns].Sub(namespaceStart[ns]).Seconds()
+		}
+		results = append(results, result)
+	}
+	return services, results
+}
+
+// kindPending returns whether an object of the kind is not deleted
+func kindPending(pending map[string]bool, kind string) bool {
+	for key, alive := range pending {
+		if alive && strings.HasPrefix(key, kind+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// teardownResultHandler aggregates the teardown of the Knative Services and of the namespaces
+func teardownResultHandler(services []pkg.ServiceTeardownResult, namespaces []pkg.NamespaceTeardownResult) pkg.CleanResult {
+	result := pkg.CleanResult{Total: len(services), Phases: map[string]pkg.LatencyResult{}, Measurment: services, Namespaces: namespaces}
+	tornDown := []float64{}
+	for _, svc := range services {
+		if svc.Complete {
+			result.TornDown++
+			tornDown = append(tornDown, svc.TornDown)
+		}
+	}
+	for _, phase := range teardownPhases {
+		durations := []float64{}
+		for _, svc := range services {
+			if d, ok := svc.Phases[phase.Name]; ok {
+				durations = append(durations, d)
+			}
+		}
+		if len(durations) == 0 {
+			continue
+		}
+		fmt.Printf("%s latency result:\n", phase.Name)
+		result.Phases[phase.Name] = latencyResultHandler(durations)
+	}
+	fmt.Printf("teardown latency result:\n")
+	result.TornDownLatency = latencyResultHandler(tornDown)
+	empty := []float64{}
+	for _, ns := range namespaces {
+		if ns.Complete {
+			empty = append(empty, ns.Empty)
+		}
+	}
+	fmt.Printf("namespace empty latency result:\n")
+	result.NamespaceEmptyLatency = latencyResultHandler(empty)
+	return result
+}
+
+// teardownRows returns the CSV rows of the teardown of the Knative Services, the phases not complete being empty
+func teardownRows(services []pkg.ServiceTeardownResult) [][]string {
+	header := []string{"svc_name", "svc_namespace"}
+	for _, phase := range teardownPhases {
+		header = append(header, phase.Name)
+	}
+	rows := [][]string{append(header, "torn_down")}
+	for _, svc := range services {
+		row := []string{svc.ServiceName, svc.ServiceNamespace}
+		for _, phase := range teardownPhases {
+			cell := ""
+			if d, ok := svc.Phases[phase.Name]; ok {
+				cell = formatSeconds(d)
+			}
+			row = append(row, cell)
+		}
+		cell := ""
+		if svc.Complete {
+			cell = formatSeconds(svc.TornDown)
+		}
+		rows = append(rows, append(row, cell))
+	}
+	return rows
+}
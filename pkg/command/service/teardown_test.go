@@ -0,0 +1,151 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	networkingv1alpha1 "knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/testutil"
+)
+
+func TestTeardownResults(t *testing.T) {
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds float64) time.Time {
+		return start.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	event := func(seconds float64, kind, name, svc, condition string) timelineEvent {
+		return timelineEvent{Time: at(seconds), Kind: kind, Namespace: "ns-1", Name: name, Service: svc, Condition: condition}
+	}
+	events := []timelineEvent{
+		event(-10, "Service", "ksvc-1", "ksvc-1", timelineCreated),
+		event(-10, "Revision", "ksvc-1-00001", "ksvc-1", timelineCreated),
+		event(-10, "Pod", "ksvc-1-pod", "ksvc-1", timelineCreated),
+		event(-10, "Service", "ksvc-2", "ksvc-2", timelineCreated),
+		event(-10, "Pod", "ksvc-2-pod", "ksvc-2", timelineCreated),
+		event(-10, "Service", "ksvc-3", "ksvc-3", timelineCreated),
+		event(0.5, "Service", "ksvc-1", "ksvc-1", timelineDeleted),
+		event(1, "Revision", "ksvc-1-00001", "ksvc-1", timelineDeleted),
+		event(1.5, "Service", "ksvc-2", "ksvc-2", timelineDeleted),
+		event(3, "Pod", "ksvc-1-pod", "ksvc-1", timelineDeleted),
+	}
+	deleted := map[string]time.Time{"ns-1/ksvc-1": start, "ns-1/ksvc-2": at(1)}
+	assert.Equal(t, 1, pendingObjects(events, deleted))
+
+	services, namespaces := teardownResults(events, deleted, map[string][]string{"ns-1": {"Service/ksvc-3", "Pod/ksvc-2-pod"}})
+	assert.DeepEqual(t, []pkg.ServiceTeardownResult{
+		{ServiceName: "ksvc-1", ServiceNamespace: "ns-1", Complete: true, TornDown: 3,
+			Phases: map[string]float64{"service_deleted": 0.5, "revisions_deleted": 1, "pods_deleted": 3}},
+		// the pod of the service is still terminating
+		{ServiceName: "ksvc-2", ServiceNamespace: "ns-1", Pending: []string{"Pod/ksvc-2-pod"},
+			Phases: map[string]float64{"service_deleted": 0.5}},
+	}, services)
+	assert.DeepEqual(t, []pkg.NamespaceTeardownResult{{Namespace: "ns-1", Remaining: []string{"Pod/ksvc-2-pod", "Service/ksvc-3"}}}, namespaces)
+	// the namespace is empty once no object is left in it, and is not reported empty if it could not be listed
+	_, empty := teardownResults(events, map[string]time.Time{"ns-1/ksvc-1": start}, map[string][]string{"ns-1": {}})
+	assert.DeepEqual(t, []pkg.NamespaceTeardownResult{{Namespace: "ns-1", Empty: 3, Complete: true}}, empty)
+	_, unlisted := teardownResults(events, map[string]time.Time{"ns-1/ksvc-1": start}, map[string][]string{})
+	assert.DeepEqual(t, []pkg.NamespaceTeardownResult{{Namespace: "ns-1"}}, unlisted)
+
+	result := teardownResultHandler(services, namespaces)
+	assert.Equal(t, 2, result.Total)
+	assert.Equal(t, 1, result.TornDown)
+	assert.Equal(t, 0.5, result.Phases["service_deleted"].Average)
+	assert.Equal(t, 3.0, result.TornDownLatency.Max)
+
+	rows := teardownRows(services)
+	assert.DeepEqual(t, []string{"ksvc-2", "ns-1", "0.500", "", "", "", "", ""}, rows[2])
+}
+
+func TestCleanServicesWait(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-kperf-1"}}
+	params, client, fakeServing := newTimelineParams(ns)
+	ctx := context.Background()
+	svcLabels := map[string]string{serving.ServiceLabelKey: "testksvc-1"}
+	_, err := fakeServing.Services("test-kperf-1").Create(ctx, &servingv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "testksvc-1", Namespace: "test-kperf-1"},
+	}, metav1.CreateOptions{})
+	assert.NilError(t, err)
+	_, err = fakeServing.Revisions("test-kperf-1").Create(ctx, &servingv1.Revision{
+		ObjectMeta: metav1.ObjectMeta{Name: "testksvc-1-00001", Namespace: "test-kperf-1", Labels: svcLabels},
+	}, metav1.CreateOptions{})
+	assert.NilError(t, err)
+	networkingClient, err := params.NewNetworkingClient()
+	assert.NilError(t, err)
+	_, err = networkingClient.Ingresses("test-kperf-1").Create(ctx, &networkingv1alpha1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "testksvc-1", Namespace: "test-kperf-1",
+			Labels: map[string]string{serving.RouteLabelKey: "testksvc-1"}},
+	}, metav1.CreateOptions{})
+	assert.NilError(t, err)
+	_, err = client.AppsV1().Deployments("test-kperf-1").Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "testksvc-1-00001-deployment", Namespace: "test-kperf-1", Labels: svcLabels},
+	}, metav1.CreateOptions{})
+	assert.NilError(t, err)
+	_, err = client.CoreV1().Pods("test-kperf-1").Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "testksvc-1-00001-deployment-abcde", Namespace: "test-kperf-1", Labels: svcLabels},
+	}, metav1.CreateOptions{})
+	assert.NilError(t, err)
+
+	// the objects of the Knative Service are garbage collected after it is deleted
+	fakeServing.PrependReactor("delete", "services", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			fakeServing.Revisions("test-kperf-1").Delete(ctx, "testksvc-1-00001", metav1.DeleteOptions{})
+			networkingClient.Ingresses("test-kperf-1").Delete(ctx, "testksvc-1", metav1.DeleteOptions{})
+			client.AppsV1().Deployments("test-kperf-1").Delete(ctx, "testksvc-1-00001-deployment", metav1.DeleteOptions{})
+			time.Sleep(50 * time.Millisecond)
+			client.CoreV1().Pods("test-kperf-1").Delete(ctx, "testksvc-1-00001-deployment-abcde", metav1.DeleteOptions{})
+		}()
+		return false, nil, nil
+	})
+
+	output := t.TempDir()
+	cmd := NewServiceCleanCommand(params)
+	_, err = testutil.ExecuteCommand(cmd, "--namespace", "test-kperf-1", "--wait", "--output", output, "--timeout", "5s")
+	assert.NilError(t, err)
+
+	rows, _ := readMeasureOutput(t, output, TeardownOutputFilename)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "testksvc-1", column(rows, 1, "svc_name"))
+	for _, phase := range teardownPhases {
+		assert.Assert(t, column(rows, 1, phase.Name) != "", "phase %s not measured", phase.Name)
+	}
+	// the namespace is empty once the objects of its only Knative Service are deleted
+	matches, err := filepath.Glob(filepath.Join(output, "*_"+TeardownOutputFilename+".json"))
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(matches))
+	data, err := os.ReadFile(matches[0])
+	assert.NilError(t, err)
+	result := pkg.CleanResult{}
+	assert.NilError(t, json.Unmarshal(data, &result))
+	assert.Equal(t, 1, len(result.Namespaces))
+	assert.Assert(t, result.Namespaces[0].Complete)
+	assert.Equal(t, 0, len(result.Namespaces[0].Remaining))
+}
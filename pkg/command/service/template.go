@@ -0,0 +1,93 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// ServiceTemplateData is the data the --template file is rendered with for each generated Knative Service
+type ServiceTemplateData struct {
+	Index     int
+	Namespace string
+	Name      string
+	RunID     string
+}
+
+var (
+	templateRandLock sync.Mutex
+	templateRand     = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// templateFuncs are the helper functions available in the --template file
+var templateFuncs = template.FuncMap{
+	// choice returns one of the values at random, e.g. {{ choice "256Mi" "512Mi" }}
+	"choice": func(values ...interface{}) (interface{}, error) {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("choice expects at least one value")
+		}
+		templateRandLock.Lock()
+		defer templateRandLock.Unlock()
+		return values[templateRand.Intn(len(values))], nil
+	},
+	// mod returns a % b, e.g. {{ if eq (mod .Index 2) 0 }}
+	"mod": func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, fmt.Errorf("mod by zero")
+		}
+		return a % b, nil
+	},
+	// add returns a + b, e.g. {{ add .Index 1 }}
+	"add": func(a, b int) int {
+		return a + b
+	},
+}
+
+// parseServiceTemplate parses the Knative Service template file
+func parseServiceTemplate(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open template file: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template file: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderService renders the template with data and decodes the result into a Knative Service
+func renderService(tmpl *template.Template, data ServiceTemplateData) (*servingv1.Service, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	service := &servingv1.Service{}
+	decoder := yaml.NewYAMLOrJSONDecoder(&buf, 64)
+	if err := decoder.Decode(service); err != nil {
+		return nil, fmt.Errorf("failed to decode YAML content: %w", err)
+	}
+	return service, nil
+}
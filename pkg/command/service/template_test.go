@@ -0,0 +1,97 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const variedTemplateYaml = `apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: {{ .Name }}
+  annotations:
+    kperf.knative.dev/run: {{ .RunID }}
+spec:
+  template:
+    spec:
+      containers:
+      - image: {{ if eq (mod .Index 2) 0 }}gcr.io/knative-samples/helloworld-go{{ else }}gcr.io/knative-samples/helloworld-rust{{ end }}
+        env:
+        - name: INDEX
+          value: "{{ add .Index 1 }}"
+        - name: NAMESPACE
+          value: {{ .Namespace }}
+        resources:
+          requests:
+            memory: {{ choice "64Mi" "128Mi" }}`
+
+func writeTemplate(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "template.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRenderService(t *testing.T) {
+	t.Run("render service with variables and functions", func(t *testing.T) {
+		tmpl, err := parseServiceTemplate(writeTemplate(t, variedTemplateYaml))
+		assert.NilError(t, err)
+
+		svc, err := renderService(tmpl, ServiceTemplateData{Index: 0, Namespace: "ns-1", Name: "ksvc-0", RunID: "run-1"})
+		assert.NilError(t, err)
+		assert.Equal(t, "ksvc-0", svc.Name)
+		assert.Equal(t, "run-1", svc.Annotations["kperf.knative.dev/run"])
+		container := svc.Spec.Template.Spec.Containers[0]
+		assert.Equal(t, "gcr.io/knative-samples/helloworld-go", container.Image)
+		assert.Equal(t, "1", container.Env[0].Value)
+		assert.Equal(t, "ns-1", container.Env[1].Value)
+		memory := container.Resources.Requests.Memory().String()
+		assert.Assert(t, memory == "64Mi" || memory == "128Mi", "unexpected memory %s", memory)
+
+		svc, err = renderService(tmpl, ServiceTemplateData{Index: 1, Namespace: "ns-1", Name: "ksvc-1", RunID: "run-1"})
+		assert.NilError(t, err)
+		assert.Equal(t, "gcr.io/knative-samples/helloworld-rust", svc.Spec.Template.Spec.Containers[0].Image)
+		assert.Equal(t, "2", svc.Spec.Template.Spec.Containers[0].Env[0].Value)
+	})
+
+	t.Run("invalid templates", func(t *testing.T) {
+		_, err := parseServiceTemplate(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.ErrorContains(t, err, "failed to open template file")
+
+		_, err = parseServiceTemplate(writeTemplate(t, "metadata:\n  name: {{ .Name "))
+		assert.ErrorContains(t, err, "failed to parse template file")
+
+		tmpl, err := parseServiceTemplate(writeTemplate(t, "metadata:\n  name: {{ .Unknown }}"))
+		assert.NilError(t, err)
+		_, err = renderService(tmpl, ServiceTemplateData{})
+		assert.ErrorContains(t, err, "failed to render template")
+
+		tmpl, err = parseServiceTemplate(writeTemplate(t, "metadata:\n  name: {{ mod .Index 0 }}"))
+		assert.NilError(t, err)
+		_, err = renderService(tmpl, ServiceTemplateData{})
+		assert.ErrorContains(t, err, "mod by zero")
+
+		tmpl, err = parseServiceTemplate(writeTemplate(t, "metadata: [name"))
+		assert.NilError(t, err)
+		_, err = renderService(tmpl, ServiceTemplateData{})
+		assert.ErrorContains(t, err, "failed to decode YAML content")
+	})
+}
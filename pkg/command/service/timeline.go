@@ -0,0 +1,438 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	networkingv1api "knative.dev/networking/pkg/apis/networking/v1alpha1"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	autoscalingv1api "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1api "knative.dev/serving/pkg/apis/serving/v1"
+
+	"knative.dev/kperf/pkg"
+)
+
+const (
+	// TimelineOutputFilename is the name of the CSV file of the timeline events
+	TimelineOutputFilename = "ksvc_timeline"
+
+	// timelinePollInterval is the interval to check the Knative Services are seen ready in the timeline
+	timelinePollInterval = 100 * time.Millisecond
+
+	// timelineCreated and timelineDeleted are the conditions of the events recorded when an object is first seen
+	// and deleted
+	timelineCreated = "Created"
+	timelineDeleted = "Deleted"
+)
+
+// timelineEvent is a change of an object of a Knative Service, timed when the change is received by the client
+type timelineEvent struct {
+	Time      time.Time
+	Kind      string
+	Namespace string
+	Name      string
+	// Service is the Knative Service the object belongs to
+	Service   string
+	Condition string
+	Status    string
+	Reason    string
+}
+
+// timelinePhase is the first time an object of the kind has the condition True, from the first time the Knative
+// Service is seen
+type timelinePhase struct {
+	Name      string
+	Kind      string
+	Condition string
+}
+
+// timelinePhases are the phases of the creation of a Knative Service computed from the timeline, in their usual order
+var timelinePhases = []timelinePhase{
+	{Name: "ConfigurationReady", Kind: "Configuration", Condition: "Ready"},
+	{Name: "RevisionReady", Kind: "Revision", Condition: "Ready"},
+	{Name: "DeploymentCreated", Kind: "Deployment", Condition: timelineCreated},
+	{Name: "PodCreated", Kind: "Pod", Condition: timelineCreated},
+	{Name: "PodScheduled", Kind: "Pod", Condition: string(corev1.PodScheduled)},
+	{Name: "ContainersReady", Kind: "Pod", Condition: string(corev1.ContainersReady)},
+	{Name: "PodAutoscalerActive", Kind: "PodAutoscaler", Condition: string(autoscalingv1api.PodAutoscalerConditionActive)},
+	{Name: "ServerlessServiceReady", Kind: "ServerlessService", Condition: "Ready"},
+	{Name: "IngressReady", Kind: "Ingress", Condition: "Ready"},
+	{Name: "RoutesReady", Kind: "Service", Condition: string(servingv1api.ServiceConditionRoutesReady)},
+	{Name: "ServiceReady", Kind: "Service", Condition: "Ready"},
+}
+
+// timelineSource lists and watches the objects of a kind in a namespace
+type timelineSource struct {
+	kind    string
+	objType runtime.Object
+	list    func(ctx context.Context, ns string, opts metav1.ListOptions) (runtime.Object, error)
+	watch   func(ctx context.Context, ns string, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// timelineRecorder records the condition transitions of the objects of the Knative Services as they are received
+// from informers, so that the phases are timed when first seen rather than from LastTransitionTime which is
+// overwritten whenever a condition flaps
+type timelineRecorder struct {
+	lock   sync.Mutex
+	events []timelineEvent
+	// conditions is the last status of the conditions of each object by kind/namespace/name key
+	conditions map[string]map[string]string
+}
+
+// timelineSources returns the sources of the resources recorded in the timeline
+func timelineSources(params *pkg.PerfParams) ([]timelineSource, error) {
+	servingClient, err := params.NewServingClient()
+	if err != nil {
+		return nil, err
+	}
+	autoscalingClient, err := params.NewAutoscalingClient()
+	if err != nil {
+		return nil, err
+	}
+	networkingClient, err := params.NewNetworkingClient()
+	if err != nil {
+		return nil, err
+	}
+	return []timelineSource{
+		{
+			kind: "Service", objType: &servingv1api.Service{},
+			list: func(ctx context.Context, ns string, opts metav1.ListOptions) (runtime.Object, error) {
+				return servingClient.Services(ns).List(ctx, opts)
+			},
+			watch: func(ctx context.Context, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+				return servingClient.Services(ns).Watch(ctx, opts)
+			},
+		},
+		{
+			kind: "Configuration", objType: &servingv1api.Configuration{},
+			list: func(ctx context.Context, ns string, opts metav1.ListOptions) (runtime.Object, error) {
+				return servingClient.Configurations(ns).List(ctx, opts)
+			},
+			watch: func(ctx context.Context, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+				return servingClient.Configurations(ns).Watch(ctx, opts)
+			},
+		},
+		{
+			kind: "Revision", objType: &servingv1api.Revision{},
+			list: func(ctx context.Context, ns string, opts metav1.ListOptions) (runtime.Object, error) {
+				return servingClient.Revisions(ns).List(ctx, opts)
+			},
+			watch: func(ctx context.Context, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+				return servingClient.Revisions(ns).Watch(ctx, opts)
+			},
+		},
+		{
+			kind: "Deployment", objType: &appsv1.Deployment{},
+			list: func(ctx context.Context, ns string, opts metav1.ListOptions) (runtime.Object, error) {
+				return params.ClientSet.AppsV1().Deployments(ns).List(ctx, opts)
+			},
+			watch: func(ctx context.Context, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+				return params.ClientSet.AppsV1().Deployments(ns).Watch(ctx, opts)
+			},
+		},
+		{
+			kind: "Pod", objType: &corev1.Pod{},
+			list: func(ctx context.Context, ns string, opts metav1.ListOptions) (runtime.Object, error) {
+				return params.ClientSet.CoreV1().Pods(ns).List(ctx, opts)
+			},
+			watch: func(ctx context.Context, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+				return params.ClientSet.CoreV1().Pods(ns).Watch(ctx, opts)
+			},
+		},
+		{
+			kind: "PodAutoscaler", objType: &autoscalingv1api.PodAutoscaler{},
+			list: func(ctx context.Context, ns string, opts metav1.ListOptions) (runtime.Object, error) {
+				return autoscalingClient.PodAutoscalers(ns).List(ctx, opts)
+			},
+			watch: func(ctx context.Context, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+				return autoscalingClient.PodAutoscalers(ns).Watch(ctx, opts)
+			},
+		},
+		{
+			kind: "ServerlessService", objType: &networkingv1api.ServerlessService{},
+			list: func(ctx context.Context, ns string, opts metav1.ListOptions) (runtime.Object, error) {
+				return networkingClient.ServerlessServices(ns).List(ctx, opts)
+			},
+			watch: func(ctx context.Context, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+				return networkingClient.ServerlessServices(ns).Watch(ctx, opts)
+			},
+		},
+		{
+			kind: "Ingress", objType: &networkingv1api.Ingress{},
+			list: func(ctx context.Context, ns string, opts metav1.ListOptions) (runtime.Object, error) {
+				return networkingClient.Ingresses(ns).List(ctx, opts)
+			},
+			watch: func(ctx context.Context, ns string, opts metav1.ListOptions) (watch.Interface, error) {
+				return networkingClient.Ingresses(ns).Watch(ctx, opts)
+			},
+		},
+	}, nil
+}
+
+// newTimelineRecorder starts recording the objects matching labelSelector in the namespaces until ctx is done, and
+// returns once the informers are synced. The run labels of the Knative Services are propagated by Knative Serving to
+// all their objects, from the Configurations and Routes down to the Pods and Ingresses
+func newTimelineRecorder(ctx context.Context, sources []timelineSource, nsNameList []string, labelSelector string) (*timelineRecorder, error) {
+	r := &timelineRecorder{
+		conditions: map[string]map[string]string{},
+	}
+	synced := []cache.InformerSynced{}
+	for _, ns := range uniqueStrings(nsNameList) {
+		for _, source := range sources {
+			ns, source := ns, source
+			listWatch := &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					options.LabelSelector = labelSelector
+					return source.list(ctx, ns, options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					options.LabelSelector = labelSelector
+					return source.watch(ctx, ns, options)
+				},
+			}
+			informer := cache.NewSharedIndexInformer(listWatch, source.objType, 0, cache.Indexers{})
+			_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+				AddFunc: func(obj interface{}) {
+					r.update(source.kind, obj)
+				},
+				UpdateFunc: func(_, obj interface{}) {
+					r.update(source.kind, obj)
+				},
+				DeleteFunc: func(obj interface{}) {
+					r.delete(source.kind, obj)
+				},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to watch %s in namespace %s: %w", source.kind, ns, err)
+			}
+			synced = append(synced, informer.HasSynced)
+			go informer.Run(ctx.Done())
+		}
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return nil, fmt.Errorf("failed to sync timeline informers: %w", ctx.Err())
+	}
+	return r, nil
+}
+
+// update records the object as created the first time it is seen, and each condition whose status changed
+func (r *timelineRecorder) update(kind string, obj interface{}) {
+	now := time.Now()
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+	event := timelineEvent{
+		Time:      now,
+		Kind:      kind,
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+		Service:   timelineServiceName(kind, object),
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key := kind + "/" + object.GetNamespace() + "/" + object.GetName()
+	last, seen := r.conditions[key]
+	if !seen {
+		last = map[string]string{}
+		r.conditions[key] = last
+		created := event
+		created.Condition, created.Status = timelineCreated, string(corev1.ConditionTrue)
+		r.events = append(r.events, created)
+	}
+	for _, c := range objectConditions(obj) {
+		if last[c.Condition] == c.Status {
+			continue
+		}
+		last[c.Condition] = c.Status
+		transition := event
+		transition.Condition, transition.Status, transition.Reason = c.Condition, c.Status, c.Reason
+		r.events = append(r.events, transition)
+	}
+}
+
+func (r *timelineRecorder) delete(kind string, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.conditions, kind+"/"+object.GetNamespace()+"/"+object.GetName())
+	r.events = append(r.events, timelineEvent{
+		Time:      time.Now(),
+		Kind:      kind,
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+		Service:   timelineServiceName(kind, object),
+		Condition: timelineDeleted,
+		Status:    string(corev1.ConditionTrue),
+	})
+}
+
+// timeline returns the events recorded so far in the order they were received
+func (r *timelineRecorder) timeline() []timelineEvent {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]timelineEvent{}, r.events...)
+}
+
+// ready returns whether the Knative Service was seen ready
+func (r *timelineRecorder) ready(ns, name string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.conditions["Service/"+ns+"/"+name]["Ready"] == string(corev1.ConditionTrue)
+}
+
+// phases returns the duration in seconds of the phases of each Knative Service by namespace/name key, from the first
+// time the Knative Service is seen to the first time each phase is reached
+func (r *timelineRecorder) phases() map[string]map[string]float64 {
+	start := map[string]time.Time{}
+	reached := map[string]map[string]time.Time{}
+	for _, event := range r.timeline() {
+		key := event.Namespace + "/" + event.Service
+		if event.Kind == "Service" && event.Condition == timelineCreated {
+			if _, exists := start[key]; !exists {
+				start[key] = event.Time
+			}
+		}
+		if event.Status != string(corev1.ConditionTrue) {
+			continue
+		}
+		for _, phase := range timelinePhases {
+			if phase.Kind != event.Kind || phase.Condition != event.Condition {
+				continue
+			}
+			if reached[key] == nil {
+				reached[key] = map[string]time.Time{}
+			}
+			if _, exists := reached[key][phase.Name]; !exists {
+				reached[key][phase.Name] = event.Time
+			}
+		}
+	}
+	result := map[string]map[string]float64{}
+	for key, startTime := range start {
+		durations := map[string]float64{}
+		for name, at := range reached[key] {
+			durations[name] = at.Sub(startTime).Seconds()
+		}
+		result[key] = durations
+	}
+	return result
+}
+
+// timelineRows returns the CSV rows of the timeline events
+func timelineRows(events []timelineEvent) [][]string {
+	rows := [][]string{{"time", "time_source", "kind", "namespace", "name", "service", "condition", "status", "reason"}}
+	for _, event := range events {
+		rows = append(rows, []string{
+			event.Time.Format(time.RFC3339Nano), TimestampSourceWatch, event.Kind, event.Namespace, event.Name, event.Service,
+			event.Condition, event.Status, event.Reason,
+		})
+	}
+	return rows
+}
+
+// phaseResultHandler aggregates the durations of each phase of the Knative Services in the order of timelinePhases
+func phaseResultHandler(phases map[string]map[string]float64) map[string]pkg.LatencyResult {
+	keys := make([]string, 0, len(phases))
+	for key := range phases {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := map[string]pkg.LatencyResult{}
+	for _, phase := range timelinePhases {
+		durations := []float64{}
+		for _, key := range keys {
+			if d, ok := phases[key][phase.Name]; ok {
+				durations = append(durations, d)
+			}
+		}
+		if len(durations) == 0 {
+			continue
+		}
+		fmt.Printf("%s latency result:\n", phase.Name)
+		result[phase.Name] = latencyResultHandler(durations)
+	}
+	return result
+}
+
+type timelineCondition struct {
+	Condition string
+	Status    string
+	Reason    string
+}
+
+// objectConditions returns the conditions of the Knative, Deployment and Pod objects
+func objectConditions(obj interface{}) []timelineCondition {
+	conditions := []timelineCondition{}
+	switch o := obj.(type) {
+	case interface{ GetStatus() *duckv1.Status }:
+		for _, c := range o.GetStatus().Conditions {
+			conditions = append(conditions, timelineCondition{Condition: string(c.Type), Status: string(c.Status), Reason: c.Reason})
+		}
+	case *appsv1.Deployment:
+		for _, c := range o.Status.Conditions {
+			conditions = append(conditions, timelineCondition{Condition: string(c.Type), Status: string(c.Status), Reason: c.Reason})
+		}
+	case *corev1.Pod:
+		for _, c := range o.Status.Conditions {
+			conditions = append(conditions, timelineCondition{Condition: string(c.Type), Status: string(c.Status), Reason: c.Reason})
+		}
+	}
+	return conditions
+}
+
+// timelineServiceName returns the name of the Knative Service of the object from the labels set by Knative Serving
+func timelineServiceName(kind string, object metav1.Object) string {
+	if kind == "Service" {
+		return object.GetName()
+	}
+	labels := object.GetLabels()
+	if name, ok := labels[serving.ServiceLabelKey]; ok {
+		return name
+	}
+	// the Ingresses are named after their Route, itself named after its Knative Service
+	return labels[serving.RouteLabelKey]
+}
+
+// uniqueStrings returns the strings without duplicates, keeping the first occurrences in order
+func uniqueStrings(in []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	networkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1"
+	fakenetworkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1/fake"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	autoscalingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/autoscaling/v1alpha1"
+	autoscalingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/autoscaling/v1alpha1/fake"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
+
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/testutil"
+)
+
+// newTimelineParams returns the params of fake clients able to watch the Kubernetes and Knative objects
+func newTimelineParams(objects ...*corev1.Namespace) (*pkg.PerfParams, *k8sfake.Clientset, *servingv1fake.FakeServingV1) {
+	client := k8sfake.NewSimpleClientset()
+	for _, obj := range objects {
+		client.Tracker().Add(obj)
+	}
+	knativeFake := testutil.NewKnativeFake()
+	fakeServing := &servingv1fake.FakeServingV1{Fake: knativeFake}
+	return &pkg.PerfParams{
+		ClientSet: client,
+		NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+			return fakeServing, nil
+		},
+		NewAutoscalingClient: func() (autoscalingv1client.AutoscalingV1alpha1Interface, error) {
+			return &autoscalingv1fake.FakeAutoscalingV1alpha1{Fake: knativeFake}, nil
+		},
+		NewNetworkingClient: func() (networkingv1alpha1.NetworkingV1alpha1Interface, error) {
+			return &fakenetworkingv1alpha1.FakeNetworkingV1alpha1{Fake: knativeFake}, nil
+		},
+	}, client, fakeServing
+}
+
+func TestTimelineRecorder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	params, client, fakeServing := newTimelineParams()
+	sources, err := timelineSources(params)
+	assert.NilError(t, err)
+	recorder, err := newTimelineRecorder(ctx, sources, []string{"ns-1", "ns-1"}, pkg.RunSelector("test-run"))
+	assert.NilError(t, err)
+	waitEvents := func(n int) {
+		err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+			return len(recorder.timeline()) >= n, nil
+		})
+		assert.NilError(t, err, "%d events recorded, expected %d", len(recorder.timeline()), n)
+	}
+	setConditions := func(svc *servingv1.Service, conditions ...apis.Condition) *servingv1.Service {
+		svc = svc.DeepCopy()
+		svc.Status.Conditions = conditions
+		svc, err := fakeServing.Services("ns-1").UpdateStatus(ctx, svc, metav1.UpdateOptions{})
+		assert.NilError(t, err)
+		return svc
+	}
+
+	svc, err := fakeServing.Services("ns-1").Create(ctx, &servingv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "ksvc-0", Namespace: "ns-1", Labels: pkg.RunLabels("test-run")},
+	}, metav1.CreateOptions{})
+	assert.NilError(t, err)
+	waitEvents(1)
+
+	// the first time the Knative Service is seen ready is kept when the condition flaps
+	svc = setConditions(svc, apis.Condition{Type: apis.ConditionReady, Status: corev1.ConditionTrue})
+	waitEvents(2)
+	svc = setConditions(svc, apis.Condition{Type: apis.ConditionReady, Status: corev1.ConditionFalse, Reason: "RevisionFailed"})
+	waitEvents(3)
+	setConditions(svc, apis.Condition{Type: apis.ConditionReady, Status: corev1.ConditionTrue})
+	waitEvents(4)
+
+	_, err = client.CoreV1().Pods("ns-1").Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "ksvc-0-pod", Namespace: "ns-1", Labels: map[string]string{serving.ServiceLabelKey: "ksvc-0"}},
+		Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionTrue}}},
+	}, metav1.CreateOptions{})
+	assert.NilError(t, err)
+	waitEvents(6)
+
+	events := recorder.timeline()
+	assert.Equal(t, timelineCreated, events[0].Condition)
+	assert.Equal(t, "RevisionFailed", events[2].Reason)
+	assert.Equal(t, "Pod", events[4].Kind)
+	assert.Equal(t, "ksvc-0", events[4].Service)
+	assert.Assert(t, recorder.ready("ns-1", "ksvc-0"))
+	assert.Equal(t, 7, len(timelineRows(events)))
+
+	phases := recorder.phases()["ns-1/ksvc-0"]
+	assert.Equal(t, events[1].Time.Sub(events[0].Time).Seconds(), phases["ServiceReady"])
+	assert.Equal(t, events[5].Time.Sub(events[0].Time).Seconds(), phases["PodScheduled"])
+	_, exists := phases["RevisionReady"]
+	assert.Assert(t, !exists)
+
+	err = fakeServing.Services("ns-1").Delete(ctx, "ksvc-0", metav1.DeleteOptions{})
+	assert.NilError(t, err)
+	waitEvents(7)
+	assert.Equal(t, timelineDeleted, recorder.timeline()[6].Condition)
+	assert.Assert(t, !recorder.ready("ns-1", "ksvc-0"))
+}
+
+func TestObjectConditions(t *testing.T) {
+	svc := &servingv1.Service{}
+	svc.Status.Status = duckv1.Status{Conditions: duckv1.Conditions{{Type: apis.ConditionReady, Status: corev1.ConditionUnknown, Reason: "Deploying"}}}
+	assert.DeepEqual(t, []timelineCondition{{Condition: "Ready", Status: "Unknown", Reason: "Deploying"}}, objectConditions(svc))
+
+	pod := &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.ContainersReady, Status: corev1.ConditionFalse}}}}
+	assert.DeepEqual(t, []timelineCondition{{Condition: "ContainersReady", Status: "False"}}, objectConditions(pod))
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+)
+
+const (
+	// TimestampSourceAPI is a timestamp of an object set by the API server or a controller, like the creation
+	// timestamp or the last transition time of a condition. It is serialized with a second precision, as are the
+	// times of the managedFields
+	TimestampSourceAPI = "api"
+	// TimestampSourceEvent is the eventTime of a Kubernetes Event, with a microsecond precision
+	TimestampSourceEvent = "event"
+	// TimestampSourceWatch is the time a change is received by a watch of kperf, with the precision of the local clock
+	TimestampSourceWatch = "watch"
+)
+
+// measuredTime is a timestamp along with the source it is taken from
+type measuredTime struct {
+	Time   time.Time
+	Source string
+}
+
+// apiTime returns the timestamp of an object
+func apiTime(t metav1.Time) measuredTime {
+	return measuredTime{Time: t.Time, Source: TimestampSourceAPI}
+}
+
+// conditionTime returns the last transition time of the condition, zero if the condition is not found
+func conditionTime(condition *apis.Condition) measuredTime {
+	if condition == nil {
+		return measuredTime{Source: TimestampSourceAPI}
+	}
+	return apiTime(condition.LastTransitionTime.Inner)
+}
+
+// Sub returns the duration from the other timestamp
+func (m measuredTime) Sub(from measuredTime) time.Duration {
+	return m.Time.Sub(from.Time)
+}
+
+// String returns the timestamp with the precision of its source, empty if not set
+func (m measuredTime) String() string {
+	if m.Time.IsZero() {
+		return ""
+	}
+	return m.Time.Format(time.RFC3339Nano)
+}
+
+// timestampCells returns the cells of the timestamps each followed by its source
+func timestampCells(times ...measuredTime) []string {
+	cells := make([]string, 0, 2*len(times))
+	for _, t := range times {
+		cells = append(cells, t.String(), t.Source)
+	}
+	return cells
+}
+
+// timestampHeader returns the header of the cells returned by timestampCells
+func timestampHeader(names ...string) []string {
+	header := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		header = append(header, name, name+"_source")
+	}
+	return header
+}
+
+// countTimestampSources counts the source of each named timestamp in sources
+func countTimestampSources(sources map[string]map[string]int, names []string, times []measuredTime) {
+	for i, name := range names {
+		if times[i].Time.IsZero() {
+			continue
+		}
+		if sources[name] == nil {
+			sources[name] = map[string]int{}
+		}
+		sources[name][times[i].Source]++
+	}
+}
+
+// podEventTimes returns the eventTime of the first Event of the pod for each reason, for the Events reported with
+// an eventTime, like the Scheduled Event of the scheduler. The Events of the kubelet only have a first timestamp with a
+// second precision and are ignored
+func podEventTimes(events []podEvent) map[string]measuredTime {
+	times := map[string]measuredTime{}
+	for _, event := range events {
+		if event.time.Source != TimestampSourceEvent {
+			continue
+		}
+		if first, exists := times[event.reason]; exists && !event.time.Time.Before(first.Time) {
+			continue
+		}
+		times[event.reason] = event.time
+	}
+	return times
+}
@@ -0,0 +1,239 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	networkingv1api "knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1"
+	"knative.dev/pkg/apis"
+	servingv1api "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1beta1api "knative.dev/serving/pkg/apis/serving/v1beta1"
+	servingv1beta1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1beta1"
+
+	"knative.dev/kperf/pkg"
+)
+
+const (
+	TLSMeasureOutputFilename = "ksvc_tls_time"
+
+	// httpsPollInterval is the interval between the requests to an HTTPS URL until the first successful response
+	httpsPollInterval = 100 * time.Millisecond
+	// httpsRequestTimeout is the timeout of each request to an HTTPS URL
+	httpsRequestTimeout = 5 * time.Second
+)
+
+// The TLS phases of a service and of its DomainMappings, by the name of their CSV column
+const (
+	phaseCertificateReady              = "certificate_ready"
+	phaseDomainMappingReady            = "domain_mapping_ready"
+	phaseDomainMappingCertificateReady = "domain_mapping_certificate_ready"
+)
+
+// tlsPhaseNames are the names of the TLS phases in the order of the columns
+var tlsPhaseNames = []string{
+	phaseCertificateReady,
+	phaseDomainMappingReady,
+	phaseDomainMappingCertificateReady,
+}
+
+var tlsHeader = []string{"svc_name", "svc_namespace", "certificate", phaseCertificateReady,
+	"domain_mapping", "domain_mapping_certificate", phaseDomainMappingReady, phaseDomainMappingCertificateReady}
+
+// namespaceTLS are the Certificates and DomainMappings of a namespace, listed once for all of its services
+type namespaceTLS struct {
+	certificates   []networkingv1api.Certificate
+	domainMappings []servingv1beta1api.DomainMapping
+}
+
+// listNamespaceTLS lists the Certificates and DomainMappings of each namespace, the namespaces failing to be listed
+// having none
+func listNamespaceTLS(ctx context.Context, nwclient networkingv1alpha1.NetworkingV1alpha1Interface,
+	dmClient servingv1beta1client.ServingV1beta1Interface, nsNameList []string) map[string]*namespaceTLS {
+	objects := make(map[string]*namespaceTLS, len(nsNameList))
+	for _, ns := range nsNameList {
+		nsTLS := &namespaceTLS{}
+		certList, err := nwclient.Certificates(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			fmt.Printf("failed to list Certificates in namespace %s: %s\n", ns, err)
+		} else {
+			nsTLS.certificates = certList.Items
+		}
+		dmList, err := dmClient.DomainMappings(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			fmt.Printf("failed to list DomainMappings in namespace %s: %s\n", ns, err)
+		} else {
+			nsTLS.domainMappings = dmList.Items
+		}
+		objects[ns] = nsTLS
+	}
+	return objects
+}
+
+// httpsProbe requests the HTTPS URLs until the first successful response
+type httpsProbe struct {
+	// endpoint is the URL of the ingress the requests are sent to, the URLs being requested directly if empty
+	endpoint string
+	insecure bool
+	timeout  time.Duration
+}
+
+// firstResponse returns the time of the first response with a 2xx status to a request to the URL, with the host of
+// the URL as Host header and TLS server name
+func (p *httpsProbe) firstResponse(ctx context.Context, url *apis.URL) (time.Time, error) {
+	target := "https://" + url.Host + url.Path
+	if p.endpoint != "" {
+		target = p.endpoint + url.Path
+	}
+	client := &http.Client{
+		Timeout: httpsRequestTimeout,
+		Transport: &http.Transport{
+			// the local issuers used for testing are usually not trusted
+			TLSClientConfig: &tls.Config{ServerName: url.URL().Hostname(), InsecureSkipVerify: p.insecure},
+		},
+	}
+	defer client.CloseIdleConnections()
+	var first time.Time
+	var lastErr error
+	err := wait.PollImmediateWithContext(ctx, httpsPollInterval, p.timeout, func(ctx context.Context) (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return false, err
+		}
+		req.Host = url.Host
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			lastErr = fmt.Errorf("unexpected status %s", resp.Status)
+			return false, nil
+		}
+		first = time.Now()
+		return true, nil
+	})
+	if err != nil {
+		if lastErr != nil {
+			err = fmt.Errorf("%w: %s", err, lastErr)
+		}
+		return first, fmt.Errorf("no successful response from %s: %w", url, err)
+	}
+	return first, nil
+}
+
+// measureServiceTLS returns the TLS of the service, from the Certificate of its Route and the DomainMappings of the
+// namespace referencing it with their Certificates. It returns nil if the service has neither Certificate nor
+// DomainMapping
+func measureServiceTLS(nsTLS *namespaceTLS, svc *servingv1api.Service) *pkg.MeasureTLSResult {
+	result := &pkg.MeasureTLSResult{ServiceName: svc.Name, ServiceNamespace: svc.Namespace, Phases: map[string]float64{}}
+	if cert, ready := controlledCertificate(nsTLS.certificates, "Route", svc.Name); cert != nil {
+		result.Certificate = cert.Name
+		if !ready.Time.IsZero() {
+			result.Phases[phaseCertificateReady] = ready.Sub(apiTime(cert.GetCreationTimestamp())).Seconds()
+		}
+	}
+
+	for i := range nsTLS.domainMappings {
+		dm := &nsTLS.domainMappings[i]
+		if dm.Spec.Ref.Kind != "Service" || dm.Spec.Ref.Name != svc.Name ||
+			(dm.Spec.Ref.Namespace != "" && dm.Spec.Ref.Namespace != svc.Namespace) {
+			continue
+		}
+		dmResult := pkg.MeasureDomainMappingResult{Name: dm.Name, Phases: map[string]float64{}}
+		if ready := dm.Status.GetCondition(servingv1beta1api.DomainMappingConditionReady); ready != nil && ready.IsTrue() {
+			dmResult.Phases[phaseDomainMappingReady] = conditionTime(ready).Sub(apiTime(dm.GetCreationTimestamp())).Seconds()
+		}
+		if cert, ready := controlledCertificate(nsTLS.certificates, "DomainMapping", dm.Name); cert != nil {
+			dmResult.Certificate = cert.Name
+			if !ready.Time.IsZero() {
+				dmResult.Phases[phaseDomainMappingCertificateReady] = ready.Sub(apiTime(cert.GetCreationTimestamp())).Seconds()
+			}
+		}
+		result.DomainMappings = append(result.DomainMappings, dmResult)
+	}
+
+	if result.Certificate == "" && len(result.DomainMappings) == 0 {
+		return nil
+	}
+	return result
+}
+
+// controlledCertificate returns the Certificate controlled by the object of the kind and name with the time it is
+// ready, zero if not ready. The latest ready Certificate is returned if there are several, like the wildcard and the
+// tag Certificates of a Route
+func controlledCertificate(certs []networkingv1api.Certificate, kind, name string) (*networkingv1api.Certificate, measuredTime) {
+	var found *networkingv1api.Certificate
+	var foundReady measuredTime
+	for i := range certs {
+		owner := metav1.GetControllerOf(&certs[i])
+		if owner == nil || owner.Kind != kind || owner.Name != name {
+			continue
+		}
+		ready := measuredTime{}
+		if condition := certs[i].Status.GetCondition(networkingv1api.CertificateConditionReady); condition != nil &&
+			condition.Status == corev1.ConditionTrue {
+			ready = conditionTime(condition)
+		}
+		if found == nil || ready.Time.After(foundReady.Time) {
+			found, foundReady = &certs[i], ready
+		}
+	}
+	return found, foundReady
+}
+
+// tlsRows returns the rows of the TLS CSV with a row per DomainMapping of the service, or a single row if it has none
+func tlsRows(result *pkg.MeasureTLSResult) [][]string {
+	cell := func(phases map[string]float64, name string) string {
+		if d, ok := phases[name]; ok {
+			return formatSeconds(d)
+		}
+		return ""
+	}
+	svcCells := []string{result.ServiceName, result.ServiceNamespace, result.Certificate, cell(result.Phases, phaseCertificateReady)}
+	if len(result.DomainMappings) == 0 {
+		return [][]string{append(svcCells, "", "", "", "")}
+	}
+	rows := make([][]string, 0, len(result.DomainMappings))
+	for _, dm := range result.DomainMappings {
+		row := append(append([]string{}, svcCells...), dm.Name, dm.Certificate, cell(dm.Phases, phaseDomainMappingReady),
+			cell(dm.Phases, phaseDomainMappingCertificateReady))
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// tlsPhaseDurations returns the durations of the TLS phases of the service and of its DomainMappings by phase name
+func tlsPhaseDurations(result *pkg.MeasureTLSResult) map[string][]float64 {
+	durations := map[string][]float64{}
+	for name, d := range result.Phases {
+		durations[name] = append(durations[name], d)
+	}
+	for _, dm := range result.DomainMappings {
+		for name, d := range dm.Phases {
+			durations[name] = append(durations[name], d)
+		}
+	}
+	return durations
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	networkingv1api "knative.dev/networking/pkg/apis/networking/v1alpha1"
+	fakenetworkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1/fake"
+	"knative.dev/pkg/apis"
+	servingv1beta1 "knative.dev/serving/pkg/apis/serving/v1beta1"
+
+	"knative.dev/kperf/pkg"
+)
+
+// readyCertificate returns the Certificate controlled by the object of the kind and name, created and ready at the
+// offsets in seconds from start
+func readyCertificate(start time.Time, name, kind, owner string, created, ready float64) *networkingv1api.Certificate {
+	at := func(seconds float64) metav1.Time {
+		return metav1.NewTime(start.Add(time.Duration(seconds * float64(time.Second))))
+	}
+	controller := true
+	cert := &networkingv1api.Certificate{ObjectMeta: metav1.ObjectMeta{
+		Name: name, Namespace: "ns1", CreationTimestamp: at(created),
+		OwnerReferences: []metav1.OwnerReference{{Kind: kind, Name: owner, Controller: &controller}},
+	}}
+	cert.Status.Conditions = []apis.Condition{{Type: networkingv1api.CertificateConditionReady, Status: corev1.ConditionTrue,
+		LastTransitionTime: apis.VolatileTime{Inner: at(ready)}}}
+	return cert
+}
+
+func TestControlledCertificate(t *testing.T) {
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	notReady := readyCertificate(start, "route-tag", "Route", "ksvc-1", 1, 0)
+	notReady.Status.Conditions = nil
+	certs := []networkingv1api.Certificate{
+		*readyCertificate(start, "route-wildcard", "Route", "ksvc-1", 1, 2),
+		*readyCertificate(start, "route-host", "Route", "ksvc-1", 1, 3),
+		*notReady,
+		*readyCertificate(start, "other", "Route", "ksvc-2", 1, 4),
+	}
+
+	// the latest ready Certificate is returned
+	cert, ready := controlledCertificate(certs, "Route", "ksvc-1")
+	assert.Equal(t, "route-host", cert.Name)
+	assert.Equal(t, start.Add(3*time.Second), ready.Time)
+
+	cert, ready = controlledCertificate(certs[2:3], "Route", "ksvc-1")
+	assert.Equal(t, "route-tag", cert.Name)
+	assert.Assert(t, ready.Time.IsZero())
+
+	cert, _ = controlledCertificate(certs, "DomainMapping", "ksvc-1")
+	assert.Assert(t, cert == nil)
+}
+
+func TestMeasureServicesTLS(t *testing.T) {
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := newMeasureParams(start, 1, "user-container")
+	ctx := context.Background()
+
+	dmClient, err := p.NewDomainMappingClient()
+	assert.NilError(t, err)
+	dm := &servingv1beta1.DomainMapping{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1.ns1.kperf.test", Namespace: "ns1",
+		CreationTimestamp: metav1.NewTime(start.Add(time.Second))}}
+	dm.Spec.Ref.Kind, dm.Spec.Ref.Name = "Service", "ksvc-1"
+	dm.Status.Conditions = []apis.Condition{{Type: servingv1beta1.DomainMappingConditionReady, Status: corev1.ConditionTrue,
+		LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(start.Add(4 * time.Second))}}}
+	_, err = dmClient.DomainMappings("ns1").Create(ctx, dm, metav1.CreateOptions{})
+	assert.NilError(t, err)
+
+	nwclient, err := p.NewNetworkingClient()
+	assert.NilError(t, err)
+	for _, cert := range []*networkingv1api.Certificate{
+		readyCertificate(start, "route-ksvc-1", "Route", "ksvc-1", 2, 5),
+		readyCertificate(start, "ksvc-1.ns1.kperf.test", "DomainMapping", "ksvc-1.ns1.kperf.test", 2, 7),
+	} {
+		_, err = nwclient.Certificates("ns1").Create(ctx, cert, metav1.CreateOptions{})
+		assert.NilError(t, err)
+	}
+	fake := nwclient.(*fakenetworkingv1alpha1.FakeNetworkingV1alpha1).Fake
+	listCertificates := func() int {
+		count := 0
+		for _, action := range fake.Actions() {
+			if action.Matches("list", "certificates") {
+				count++
+			}
+		}
+		return count
+	}
+
+	// the TLS is not measured by default, without needing the DomainMapping client
+	output := t.TempDir()
+	noDomainMapping := *p
+	noDomainMapping.NewDomainMappingClient = nil
+	err = MeasureServices(&noDomainMapping, pkg.MeasureArgs{Namespace: "ns1", SvcPrefix: "ksvc", SvcRange: "1,1", Concurrency: 1, Output: output},
+		MeasureServicesOptions{NamespaceChanged: true})
+	assert.NilError(t, err)
+	matches, err := filepath.Glob(filepath.Join(output, "*_"+TLSMeasureOutputFilename+".csv"))
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(matches))
+	assert.Equal(t, 0, listCertificates())
+
+	output = t.TempDir()
+	err = MeasureServices(p, pkg.MeasureArgs{Namespace: "ns1", SvcPrefix: "ksvc", SvcRange: "1,2", Concurrency: 2, Output: output, TLS: true},
+		MeasureServicesOptions{NamespaceChanged: true})
+	assert.NilError(t, err)
+	// the Certificates are listed once for the namespace
+	assert.Equal(t, 1, listCertificates())
+
+	rows, _ := readMeasureOutput(t, output, TLSMeasureOutputFilename)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "route-ksvc-1", column(rows, 1, "certificate"))
+	assert.Equal(t, "3.000", column(rows, 1, phaseCertificateReady))
+	assert.Equal(t, "ksvc-1.ns1.kperf.test", column(rows, 1, "domain_mapping"))
+	assert.Equal(t, "3.000", column(rows, 1, phaseDomainMappingReady))
+	assert.Equal(t, "5.000", column(rows, 1, phaseDomainMappingCertificateReady))
+
+	_, result := readMeasureOutput(t, output, MeasureOutputFilename)
+	assert.Equal(t, 1, len(result.TLS))
+	assert.Equal(t, "route-ksvc-1", result.TLS[0].Certificate)
+	assert.Equal(t, 3.0, result.Phases[phaseCertificateReady].Average)
+}
+
+func TestHTTPSProbe(t *testing.T) {
+	restoreClock()
+	status := http.StatusServiceUnavailable
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	url, err := apis.ParseURL(server.URL)
+	assert.NilError(t, err)
+
+	probe := &httpsProbe{insecure: true, timeout: 300 * time.Millisecond}
+	_, err = probe.firstResponse(context.Background(), url)
+	assert.ErrorContains(t, err, "unexpected status 503")
+
+	status = http.StatusOK
+	before := time.Now()
+	first, err := probe.firstResponse(context.Background(), url)
+	assert.NilError(t, err)
+	assert.Assert(t, !first.Before(before))
+
+	// the requests are sent to the endpoint with the host of the URL
+	probe.endpoint = server.URL
+	_, err = probe.firstResponse(context.Background(), &apis.URL{Scheme: "https", Host: "ksvc-1.ns1.kperf.test"})
+	assert.NilError(t, err)
+}
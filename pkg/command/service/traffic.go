@@ -0,0 +1,546 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/target"
+
+	networkingv1api "knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+)
+
+const (
+	TrafficOutputFilename = "ksvc_traffic_time"
+
+	// revisionPollInterval is the interval to check a named revision has been created
+	revisionPollInterval = 500 * time.Millisecond
+	// tagRequestTimeout is the timeout of each request checking a tag URL serves traffic
+	tagRequestTimeout = 5 * time.Second
+)
+
+// trafficWeight is the percent of traffic and the optional tag of a revision
+type trafficWeight struct {
+	Percent int64
+	Tag     string
+}
+
+// parseTrafficSplit parses percents like "50,30,20" and tags like "blue,green," into the traffic weights of
+// as many revisions, the tags being optional
+func parseTrafficSplit(traffic, tags string) ([]trafficWeight, error) {
+	if traffic == "" {
+		return nil, errors.New("traffic is empty, expected percents like 50,30,20")
+	}
+	weights := []trafficWeight{}
+	var total int64
+	for _, p := range strings.Split(traffic, ",") {
+		percent, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected traffic percents like 50,30,20, given %s", traffic)
+		}
+		if percent < 0 {
+			return nil, fmt.Errorf("traffic percent must not be negative, given %s", traffic)
+		}
+		total += percent
+		weights = append(weights, trafficWeight{Percent: percent})
+	}
+	if total != 100 {
+		return nil, fmt.Errorf("traffic percents must sum to 100, given %s", traffic)
+	}
+	if tags != "" {
+		t := strings.Split(tags, ",")
+		if len(t) != len(weights) {
+			return nil, fmt.Errorf("expected %d tags, given %s", len(weights), tags)
+		}
+		for i := range weights {
+			weights[i].Tag = strings.TrimSpace(t[i])
+		}
+	}
+	return weights, nil
+}
+
+// evenTraffic returns the percents splitting the traffic evenly across n revisions, the last one
+// getting the remainder
+func evenTraffic(n int) string {
+	percents := make([]string, n)
+	for i := 0; i < n; i++ {
+		percent := 100 / n
+		if i == n-1 {
+			percent += 100 % n
+		}
+		percents[i] = strconv.Itoa(percent)
+	}
+	return strings.Join(percents, ",")
+}
+
+// trafficTargets returns the traffic targets sending weights[i] to revisions[i]
+func trafficTargets(revisions []string, weights []trafficWeight) []servingv1.TrafficTarget {
+	targets := make([]servingv1.TrafficTarget, len(weights))
+	for i, w := range weights {
+		percent := w.Percent
+		latest := false
+		targets[i] = servingv1.TrafficTarget{
+			RevisionName:   revisions[i],
+			Percent:        &percent,
+			Tag:            w.Tag,
+			LatestRevision: &latest,
+		}
+	}
+	return targets
+}
+
+// formatTraffic returns a readable traffic split like "ksvc-1-rev-1:50:blue,ksvc-1-rev-2:50"
+func formatTraffic(targets []servingv1.TrafficTarget) string {
+	split := make([]string, 0, len(targets))
+	for _, t := range targets {
+		s := fmt.Sprintf("%s:%d", t.RevisionName, *t.Percent)
+		if t.Tag != "" {
+			s += ":" + t.Tag
+		}
+		split = append(split, s)
+	}
+	return strings.Join(split, ",")
+}
+
+// generatedRevisionName returns the name of the i-th revision (from 1) of a generated service with a traffic split
+func generatedRevisionName(svc string, i int) string {
+	return fmt.Sprintf("%s-rev-%d", svc, i)
+}
+
+// rolloutTrafficSplit creates the revisions of a service created with the first of them and assigns them the
+// traffic weights. Each revision is rolled out by renaming the revision template once the previous revision
+// exists, as the configuration only creates a revision for the latest template it observes
+func rolloutTrafficSplit(ctx context.Context, ksvcClient servingv1client.ServingV1Interface, ns, name string, weights []trafficWeight, timeout time.Duration) error {
+	revisions := make([]string, len(weights))
+	for i := range weights {
+		revisions[i] = generatedRevisionName(name, i+1)
+	}
+	for i := 1; i < len(revisions); i++ {
+		err := wait.PollImmediateWithContext(ctx, revisionPollInterval, timeout, func(ctx context.Context) (bool, error) {
+			_, err := ksvcClient.Revisions(ns).Get(ctx, revisions[i-1], metav1.GetOptions{})
+			return err == nil, nil
+		})
+		if ctx.Err() != nil {
+			return fmt.Errorf("revision %s not created: %w", revisions[i-1], ctx.Err())
+		}
+		if err != nil {
+			return fmt.Errorf("revision %s not created after %s", revisions[i-1], timeout)
+		}
+
+		spec := map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name": revisions[i],
+				},
+			},
+		}
+		// the traffic is assigned along with the last revision, the route waits for it to be ready
+		if i == len(revisions)-1 {
+			spec["traffic"] = trafficTargets(revisions, weights)
+		}
+		payload, err := json.Marshal(map[string]interface{}{"spec": spec})
+		if err != nil {
+			return err
+		}
+		_, err = ksvcClient.Services(ns).Patch(ctx, name, types.MergePatchType, payload, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to roll out revision %s: %w", revisions[i], err)
+		}
+	}
+	return nil
+}
+
+func NewServiceTrafficCommand(p *pkg.PerfParams) *cobra.Command {
+	trafficArgs := pkg.TrafficArgs{}
+	serviceTrafficCommand := &cobra.Command{
+		Use:   "traffic",
+		Short: "Split Knative service traffic and measure route reconciliation",
+		Long: `Split the traffic of Knative service across its latest revisions and measure the time for the
+Route and the KIngress to converge and for the tag URLs to serve traffic
+
+For example:
+# To send 80% of the traffic to the second latest revision and 20% to the latest revision, tagged stable and canary
+kperf service traffic --svc-prefix svc --range 1,200 --namespace ns --traffic 80,20 --tags stable,canary
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().NFlag() == 0 {
+				return fmt.Errorf("'service traffic' requires flag(s)")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return SplitServicesTraffic(p, trafficArgs)
+		},
+	}
+
+	serviceTrafficCommand.Flags().StringVarP(&trafficArgs.Svc, "svc", "", "", "Service name")
+	serviceTrafficCommand.Flags().StringVarP(&trafficArgs.SvcRange, "range", "r", "", "Desired service range like 1,500 or 1-5,8,20-30")
+	serviceTrafficCommand.Flags().StringVarP(&trafficArgs.Namespace, "namespace", "", "", "Service namespace")
+	serviceTrafficCommand.Flags().StringVarP(&trafficArgs.SvcPrefix, "svc-prefix", "", "", "Service name prefix")
+	serviceTrafficCommand.Flags().StringVarP(&trafficArgs.RunID, "run-id", "", "", "Only select the services created by the kperf run with this ID, svc and svc-prefix are optional if set")
+	serviceTrafficCommand.Flags().StringVarP(&trafficArgs.NamespaceRange, "namespace-range", "", "", "Service namespace range like 1,500 or 1-5,8,20-30")
+	serviceTrafficCommand.Flags().StringVarP(&trafficArgs.NamespacePrefix, "namespace-prefix", "", "", "Service namespace prefix")
+	serviceTrafficCommand.Flags().IntVarP(&trafficArgs.Concurrency, "concurrency", "c", 10, "Number of services updated at the same time")
+	serviceTrafficCommand.Flags().StringVarP(&trafficArgs.Traffic, "traffic", "", "", "Traffic percents of the latest revisions from the oldest to the latest, like 50,30,20")
+	serviceTrafficCommand.Flags().StringVarP(&trafficArgs.Tags, "tags", "", "", "Tags of the revisions in the order of --traffic, like stable,,canary")
+	serviceTrafficCommand.Flags().BoolVarP(&trafficArgs.ResolvableDomain, "resolvable", "", false, "If the tag URLs are resolvable")
+	serviceTrafficCommand.Flags().BoolVarP(&trafficArgs.Https, "https", "", false, "Use https with TLS")
+	serviceTrafficCommand.Flags().DurationVarP(&trafficArgs.PollInterval, "poll-interval", "", time.Second, "Interval to check the reconciliation progress")
+	serviceTrafficCommand.Flags().DurationVarP(&trafficArgs.Timeout, "timeout", "", 5*time.Minute, "Duration to wait for a traffic split to converge")
+	serviceTrafficCommand.Flags().BoolVarP(&trafficArgs.Verbose, "verbose", "v", false, "Service verbose result")
+	serviceTrafficCommand.Flags().StringVarP(&trafficArgs.Output, "output", "o", ".", "Measure result location")
+	addTargetFlags(serviceTrafficCommand, &trafficArgs.Selector, &trafficArgs.SvcRegex, &trafficArgs.NamespaceSelector, &trafficArgs.NamespaceRegex)
+	return serviceTrafficCommand
+}
+
+// SplitServicesTraffic changes the traffic split of existing services and measures the reconciliation time
+func SplitServicesTraffic(params *pkg.PerfParams, inputs pkg.TrafficArgs) error {
+	if inputs.Concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive, given %d", inputs.Concurrency)
+	}
+	weights, err := parseTrafficSplit(inputs.Traffic, inputs.Tags)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	nsNameList, err := target.Namespaces{Name: inputs.Namespace, Prefix: inputs.NamespacePrefix, Range: inputs.NamespaceRange,
+		Selector: inputs.NamespaceSelector, Regex: inputs.NamespaceRegex}.Resolve(ctx, params.ClientSet)
+	if err != nil {
+		return err
+	}
+	ksvcClient, err := params.NewServingClient()
+	if err != nil {
+		return fmt.Errorf("failed to create serving client %s", err)
+	}
+	nwclient, err := params.NewNetworkingClient()
+	if err != nil {
+		return fmt.Errorf("failed to create networking client %s", err)
+	}
+	objs, err := getServices(ctx, ksvcClient, nsNameList, target.Services{Name: inputs.Svc, Prefix: inputs.SvcPrefix, Range: inputs.SvcRange,
+		Selector: inputs.Selector, Regex: inputs.SvcRegex, RunID: inputs.RunID})
+	if err != nil {
+		return err
+	}
+
+	result := pkg.TrafficResult{}
+	svcChannel := make(chan ServicesToScale)
+	var wg sync.WaitGroup
+	var m sync.Mutex
+	for i := 0; i < inputs.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range svcChannel {
+				r := runTrafficSplit(ctx, params, ksvcClient, nwclient, inputs, weights, obj.Namespace, obj.Service.Name)
+				if r.Error != "" {
+					fmt.Printf("failed to split traffic of service %s/%s: %s\n", obj.Namespace, obj.Service.Name, r.Error)
+				} else if inputs.Verbose {
+					fmt.Printf("[Verbose] Service %s/%s: traffic %s, route ready in %fs, ingress ready in %fs\n",
+						obj.Namespace, obj.Service.Name, r.Traffic, r.RouteReadyDuration, r.IngressReadyDuration)
+					for _, tag := range r.Tags {
+						fmt.Printf("[Verbose] Service %s/%s:   - tag %s served traffic in %fs\n", obj.Namespace, obj.Service.Name, tag.Tag, tag.ReadyDuration)
+					}
+				}
+				m.Lock()
+				result.Measurment = append(result.Measurment, r)
+				m.Unlock()
+			}
+		}()
+	}
+	for _, obj := range objs {
+		svcChannel <- obj
+	}
+	close(svcChannel)
+	wg.Wait()
+
+	var routeReadyList, ingressReadyList, tagReadyList []float64
+	rows := [][]string{{"svc_name", "svc_namespace", "traffic", "route_ready", "ingress_config_ready", "ingress_lb_ready",
+		"ingress_ready", "tags_ready", "unchanged", "error"}}
+	for _, r := range result.Measurment {
+		result.Total++
+		tags := make([]string, 0, len(r.Tags))
+		for _, tag := range r.Tags {
+			tags = append(tags, tag.Tag+"="+formatSeconds(tag.ReadyDuration))
+		}
+		rows = append(rows, []string{r.ServiceName, r.ServiceNamespace, r.Traffic,
+			formatSeconds(r.RouteReadyDuration), formatSeconds(r.IngressNetworkConfiguredDuration),
+			formatSeconds(r.IngressLoadBalancerReadyDuration), formatSeconds(r.IngressReadyDuration),
+			strings.Join(tags, ";"), strconv.FormatBool(r.Unchanged), r.Error})
+		if r.Error != "" {
+			result.Failed++
+			continue
+		}
+		result.Succeeded++
+		if r.Unchanged {
+			result.Unchanged++
+			continue
+		}
+		routeReadyList = append(routeReadyList, r.RouteReadyDuration)
+		ingressReadyList = append(ingressReadyList, r.IngressReadyDuration)
+		for _, tag := range r.Tags {
+			tagReadyList = append(tagReadyList, tag.ReadyDuration)
+		}
+	}
+
+	fmt.Printf("-------- Measurement --------\n")
+	fmt.Printf("Total: %d | Succeeded: %d (Unchanged: %d) Failed: %d\n", result.Total, result.Succeeded, result.Unchanged, result.Failed)
+	if len(routeReadyList) > 0 {
+		fmt.Printf("route ready latency result:\n")
+		result.RouteReady = latencyResultHandler(routeReadyList)
+		fmt.Printf("ingress ready latency result:\n")
+		result.IngressReady = latencyResultHandler(ingressReadyList)
+		if len(tagReadyList) > 0 {
+			fmt.Printf("tag ready latency result:\n")
+			result.TagReady = latencyResultHandler(tagReadyList)
+		}
+	}
+
+	knativeVersion := GetKnativeVersion(params)
+	ingressInfo := GetIngressController(params)
+	result.KnativeInfo.ServingVersion = knativeVersion["serving"]
+	result.KnativeInfo.EventingVersion = knativeVersion["eventing"]
+	result.KnativeInfo.IngressController = ingressInfo["ingressController"]
+	result.KnativeInfo.IngressVersion = ingressInfo["version"]
+
+	// generate CSV, HTML and JSON outputs from rows and result
+	err = GenerateOutput(inputs.Output, TrafficOutputFilename, true, true, true, rows, result)
+	if err != nil {
+		fmt.Printf("failed to generate output: %s\n", err)
+		return err
+	}
+
+	if result.Succeeded == 0 {
+		return fmt.Errorf("all %d traffic splits failed", result.Total)
+	}
+	return nil
+}
+
+// latestRevisions returns the names of the n latest revisions of the service, from the oldest to the latest
+func latestRevisions(ctx context.Context, ksvcClient servingv1client.ServingV1Interface, ns, name string, n int) ([]string, error) {
+	selector := labels.SelectorFromSet(labels.Set{serving.ServiceLabelKey: name})
+	revisionList, err := ksvcClient.Revisions(ns).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	if len(revisionList.Items) < n {
+		return nil, fmt.Errorf("%d revisions required, found %d", n, len(revisionList.Items))
+	}
+	revisions := revisionList.Items
+	generation := func(i int) int {
+		g, _ := strconv.Atoi(revisions[i].Labels[serving.ConfigurationGenerationLabelKey])
+		return g
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return generation(i) < generation(j)
+	})
+	names := []string{}
+	for _, r := range revisions[len(revisions)-n:] {
+		names = append(names, r.Name)
+	}
+	return names, nil
+}
+
+// runTrafficSplit assigns the traffic weights to the latest revisions of the service and measures, from the
+// update, the time for the Route and the KIngress to converge and for each tag URL to serve traffic
+func runTrafficSplit(ctx context.Context, params *pkg.PerfParams, ksvcClient servingv1client.ServingV1Interface, nwclient networkingv1alpha1.NetworkingV1alpha1Interface,
+	inputs pkg.TrafficArgs, weights []trafficWeight, namespace, name string) pkg.TrafficSplitResult {
+	result := pkg.TrafficSplitResult{ServiceName: name, ServiceNamespace: namespace}
+
+	revisions, err := latestRevisions(ctx, ksvcClient, namespace, name, len(weights))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	targets := trafficTargets(revisions, weights)
+	result.Traffic = formatTraffic(targets)
+
+	// the generations before the update tell apart the route and ingress converging to the new split
+	route, err := ksvcClient.Routes(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to get route: %s", err)
+		return result
+	}
+	// re-applying the split served by the route is a no-op update which does not bump the route generation
+	if route.Status.ObservedGeneration == route.Generation && route.IsReady() && routeServesTraffic(route, targets) {
+		result.UpdateTime = time.Now()
+		result.Unchanged = true
+		return result
+	}
+	routeGeneration := route.Generation
+	var ingressGeneration int64
+	ingress, err := nwclient.Ingresses(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		ingressGeneration = ingress.Generation
+	} else if !apierrors.IsNotFound(err) {
+		result.Error = fmt.Sprintf("failed to get ingress: %s", err)
+		return result
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"spec": map[string]interface{}{"traffic": targets}})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.UpdateTime = time.Now()
+	_, err = ksvcClient.Services(namespace).Patch(ctx, name, types.MergePatchType, payload, metav1.PatchOptions{})
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to update service traffic: %s", err)
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, inputs.Timeout)
+	defer cancel()
+
+	err = wait.PollImmediateUntilWithContext(ctx, inputs.PollInterval, func(ctx context.Context) (bool, error) {
+		r, err := ksvcClient.Routes(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		if r.Generation <= routeGeneration || !r.IsReady() || !routeServesTraffic(r, targets) {
+			return false, nil
+		}
+		route = r
+		return true, nil
+	})
+	if err != nil {
+		result.Error = fmt.Sprintf("timeout waiting for the route to converge: %s", err)
+		return result
+	}
+	result.RouteReadyDuration = time.Since(result.UpdateTime).Seconds()
+
+	// the Ingress conditions are checked as in MeasureServices, for the generation of the new split
+	err = wait.PollImmediateUntilWithContext(ctx, inputs.PollInterval, func(ctx context.Context) (bool, error) {
+		ing, err := nwclient.Ingresses(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil || ing.Generation <= ingressGeneration || ing.Status.ObservedGeneration != ing.Generation {
+			return false, nil
+		}
+		elapsed := time.Since(result.UpdateTime).Seconds()
+		if result.IngressNetworkConfiguredDuration == 0 && ing.Status.GetCondition(networkingv1api.IngressConditionNetworkConfigured).IsTrue() {
+			result.IngressNetworkConfiguredDuration = elapsed
+		}
+		if result.IngressLoadBalancerReadyDuration == 0 && ing.Status.GetCondition(networkingv1api.IngressConditionLoadBalancerReady).IsTrue() {
+			result.IngressLoadBalancerReadyDuration = elapsed
+		}
+		if !ing.IsReady() {
+			return false, nil
+		}
+		result.IngressReadyDuration = elapsed
+		return true, nil
+	})
+	if err != nil {
+		result.Error = fmt.Sprintf("timeout waiting for the ingress to converge: %s", err)
+		return result
+	}
+
+	result.Tags = probeTags(ctx, params, inputs, route, result.UpdateTime)
+	for _, tag := range result.Tags {
+		if tag.Error != "" {
+			result.Error = fmt.Sprintf("tag %s: %s", tag.Tag, tag.Error)
+		}
+	}
+	return result
+}
+
+// routeServesTraffic returns true if the route status has all the traffic targets
+func routeServesTraffic(route *servingv1.Route, targets []servingv1.TrafficTarget) bool {
+	for _, target := range targets {
+		found := false
+		for _, t := range route.Status.Traffic {
+			if t.RevisionName == target.RevisionName && t.Tag == target.Tag && t.Percent != nil && *t.Percent == *target.Percent {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// probeTags requests the tag URLs of the route until they serve traffic and returns the time it took since start
+func probeTags(ctx context.Context, params *pkg.PerfParams, inputs pkg.TrafficArgs, route *servingv1.Route, start time.Time) []pkg.TagReadyResult {
+	results := []pkg.TagReadyResult{}
+	for _, t := range route.Status.Traffic {
+		if t.Tag != "" && t.URL != nil {
+			results = append(results, pkg.TagReadyResult{Tag: t.Tag, URL: t.URL.String()})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(tag *pkg.TagReadyResult) {
+			defer wg.Done()
+			endpoint := tag.URL
+			if !inputs.ResolvableDomain {
+				var err error
+				endpoint, err = getIngressEndpoint(ctx, params, inputs.Https)
+				if err != nil {
+					tag.Error = fmt.Sprintf("failed to get the cluster endpoint: %s", err)
+					return
+				}
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+			if err != nil {
+				tag.Error = err.Error()
+				return
+			}
+			if u, err := url.Parse(tag.URL); err == nil {
+				req.Host = u.Host
+			}
+			client := http.Client{Timeout: tagRequestTimeout}
+			err = wait.PollImmediateUntilWithContext(ctx, inputs.PollInterval, func(ctx context.Context) (bool, error) {
+				resp, err := client.Do(req)
+				if err != nil {
+					return false, nil
+				}
+				resp.Body.Close()
+				return resp.StatusCode >= 200 && resp.StatusCode < 300, nil
+			})
+			if err != nil {
+				tag.Error = fmt.Sprintf("timeout waiting for %s to serve traffic: %s", tag.URL, err)
+				return
+			}
+			tag.ReadyDuration = time.Since(start).Seconds()
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
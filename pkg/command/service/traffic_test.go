@@ -0,0 +1,346 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"knative.dev/kperf/pkg"
+	networkingv1api "knative.dev/networking/pkg/apis/networking/v1alpha1"
+	networkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1"
+	fakenetworkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1/fake"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
+)
+
+// restoreClock undoes the patch of time.Now which the load tests leave behind, for the tests waiting on real time
+func restoreClock() {
+	monkey.Unpatch(time.Now)
+}
+
+func TestParseTrafficSplit(t *testing.T) {
+	weights, err := parseTrafficSplit("50, 30,20", "stable,,canary")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []trafficWeight{{Percent: 50, Tag: "stable"}, {Percent: 30}, {Percent: 20, Tag: "canary"}}, weights)
+
+	assert.Equal(t, "33,33,34", evenTraffic(3))
+	assert.Equal(t, "100", evenTraffic(1))
+
+	_, err = parseTrafficSplit("", "")
+	assert.ErrorContains(t, err, "traffic is empty")
+	_, err = parseTrafficSplit("50,a", "")
+	assert.ErrorContains(t, err, "expected traffic percents like 50,30,20, given 50,a")
+	_, err = parseTrafficSplit("50,40", "")
+	assert.ErrorContains(t, err, "traffic percents must sum to 100")
+	_, err = parseTrafficSplit("150,-50", "")
+	assert.ErrorContains(t, err, "traffic percent must not be negative")
+	_, err = parseTrafficSplit("50,50", "a")
+	assert.ErrorContains(t, err, "expected 2 tags, given a")
+
+	targets := trafficTargets([]string{"ksvc-1-rev-1", "ksvc-1-rev-2"}, []trafficWeight{{Percent: 80, Tag: "stable"}, {Percent: 20}})
+	assert.Equal(t, "ksvc-1-rev-1:80:stable,ksvc-1-rev-2:20", formatTraffic(targets))
+}
+
+func TestGenerateServicesWithTrafficSplit(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-1"}}
+	client := k8sfake.NewSimpleClientset(ns)
+
+	// the fake configuration controller creates the revision named in the template of each created or patched service
+	var lock sync.Mutex
+	revisions := map[string]bool{}
+	patches := []map[string]interface{}{}
+	fake := &clienttesting.Fake{}
+	fake.AddReactor("create", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		svc := a.(clienttesting.CreateAction).GetObject().(*servingv1.Service)
+		revisions[svc.Spec.Template.Name] = true
+		return true, svc, nil
+	})
+	fake.AddReactor("patch", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		patch := map[string]interface{}{}
+		if err := json.Unmarshal(a.(clienttesting.PatchAction).GetPatch(), &patch); err != nil {
+			return true, nil, err
+		}
+		patches = append(patches, patch)
+		spec := patch["spec"].(map[string]interface{})
+		revisions[spec["template"].(map[string]interface{})["metadata"].(map[string]interface{})["name"].(string)] = true
+		return true, &servingv1.Service{}, nil
+	})
+	fake.AddReactor("get", "revisions", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		name := a.(clienttesting.GetAction).GetName()
+		if !revisions[name] {
+			return true, nil, apierrors.NewNotFound(servingv1.Resource("revisions"), name)
+		}
+		return true, &servingv1.Revision{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+	})
+	fakeServing := &servingv1fake.FakeServingV1{Fake: fake}
+	p := &pkg.PerfParams{
+		ClientSet: client,
+		NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+			return fakeServing, nil
+		},
+	}
+
+	inputs := pkg.GenerateArgs{
+		Number:      1,
+		Batch:       1,
+		Concurrency: 1,
+		Namespace:   "ns-1",
+		SvcPrefix:   "ksvc",
+		Timeout:     time.Second,
+		Revisions:   3,
+		Traffic:     "50,30,20",
+		Tags:        ",,latest",
+	}
+	err := GenerateServices(p, inputs)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]bool{"ksvc-0-rev-1": true, "ksvc-0-rev-2": true, "ksvc-0-rev-3": true}, revisions)
+	assert.Equal(t, 2, len(patches))
+	_, found := patches[0]["spec"].(map[string]interface{})["traffic"]
+	assert.Assert(t, !found, "traffic is only assigned with the last revision")
+	traffic := patches[1]["spec"].(map[string]interface{})["traffic"].([]interface{})
+	assert.Equal(t, 3, len(traffic))
+	last := traffic[2].(map[string]interface{})
+	assert.Equal(t, "ksvc-0-rev-3", last["revisionName"])
+	assert.Equal(t, float64(20), last["percent"])
+	assert.Equal(t, "latest", last["tag"])
+
+	inputs.Traffic = "50,50"
+	inputs.Tags = ""
+	err = GenerateServices(p, inputs)
+	assert.ErrorContains(t, err, "expected 3 traffic percents, given 50,50")
+}
+
+// fakeTrafficSplit simulates the route and ingress controllers converging to the traffic of the last patch
+type fakeTrafficSplit struct {
+	lock       sync.Mutex
+	url        string
+	generation int64
+	traffic    []servingv1.TrafficTarget
+}
+
+func (f *fakeTrafficSplit) install(servingFake, networkingFake *clienttesting.Fake) {
+	servingFake.AddReactor("list", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		return true, &servingv1.ServiceList{Items: []servingv1.Service{{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1", Namespace: "ns-1"}}}}, nil
+	})
+	servingFake.AddReactor("list", "revisions", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		list := &servingv1.RevisionList{}
+		// listed from the latest to check they are sorted by generation
+		for i := 3; i > 0; i-- {
+			list.Items = append(list.Items, servingv1.Revision{ObjectMeta: metav1.ObjectMeta{
+				Name:   generatedRevisionName("ksvc-1", i),
+				Labels: map[string]string{serving.ServiceLabelKey: "ksvc-1", serving.ConfigurationGenerationLabelKey: strconv.Itoa(i)},
+			}})
+		}
+		return true, list, nil
+	})
+	servingFake.AddReactor("patch", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		patch := servingv1.Service{}
+		if err := json.Unmarshal(a.(clienttesting.PatchAction).GetPatch(), &patch); err != nil {
+			return true, nil, err
+		}
+		traffic := patch.Spec.Traffic
+		for i := range traffic {
+			if traffic[i].Tag != "" {
+				traffic[i].URL = apis.HTTP(f.url)
+			}
+		}
+		// like the API server, a patch which changes nothing keeps the generation
+		if !reflect.DeepEqual(f.traffic, traffic) {
+			f.generation++
+			f.traffic = traffic
+		}
+		return true, &servingv1.Service{}, nil
+	})
+	servingFake.AddReactor("get", "routes", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		route := &servingv1.Route{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1", Namespace: "ns-1", Generation: f.generation}}
+		route.Status.ObservedGeneration = f.generation
+		route.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionReady, Status: corev1.ConditionTrue}}
+		route.Status.Traffic = f.traffic
+		return true, route, nil
+	})
+	networkingFake.AddReactor("get", "ingresses", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		ingress := &networkingv1api.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1", Namespace: "ns-1", Generation: f.generation}}
+		ingress.Status.ObservedGeneration = f.generation
+		ingress.Status.Conditions = duckv1.Conditions{
+			{Type: apis.ConditionReady, Status: corev1.ConditionTrue},
+			{Type: networkingv1api.IngressConditionNetworkConfigured, Status: corev1.ConditionTrue},
+			{Type: networkingv1api.IngressConditionLoadBalancerReady, Status: corev1.ConditionTrue},
+		}
+		return true, ingress, nil
+	})
+}
+
+func TestRolloutTrafficSplitCancelled(t *testing.T) {
+	restoreClock()
+	// the revisions are never created, the rollout only stops with its context
+	fake := &clienttesting.Fake{}
+	fake.AddReactor("get", "revisions", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(servingv1.Resource("revisions"), a.(clienttesting.GetAction).GetName())
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	err := rolloutTrafficSplit(ctx, &servingv1fake.FakeServingV1{Fake: fake}, "ns-1", "ksvc-1", []trafficWeight{{Percent: 50}, {Percent: 50}}, time.Minute)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Assert(t, time.Since(start) < 10*time.Second, "the rollout waited %s after being cancelled", time.Since(start))
+}
+
+func TestSplitServicesTraffic(t *testing.T) {
+	restoreClock()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	setup := func() *pkg.PerfParams {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-1"}}
+		client := k8sfake.NewSimpleClientset(ns)
+		split := &fakeTrafficSplit{url: server.Listener.Addr().String()}
+		fakeServing := &servingv1fake.FakeServingV1{Fake: &clienttesting.Fake{}}
+		fakeNetworking := &fakenetworkingv1alpha1.FakeNetworkingV1alpha1{Fake: &clienttesting.Fake{}}
+		split.install(fakeServing.Fake, fakeNetworking.Fake)
+		return &pkg.PerfParams{
+			ClientSet: client,
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return fakeServing, nil
+			},
+			NewNetworkingClient: func() (networkingv1alpha1.NetworkingV1alpha1Interface, error) {
+				return fakeNetworking, nil
+			},
+		}
+	}
+
+	t.Run("split traffic across the latest revisions", func(t *testing.T) {
+		output := t.TempDir()
+		inputs := pkg.TrafficArgs{
+			SvcPrefix:        "ksvc",
+			SvcRange:         "1,1",
+			Namespace:        "ns-1",
+			Concurrency:      1,
+			Traffic:          "80,20",
+			Tags:             "stable,canary",
+			ResolvableDomain: true,
+			PollInterval:     10 * time.Millisecond,
+			Timeout:          time.Second,
+			Output:           output,
+		}
+		err := SplitServicesTraffic(setup(), inputs)
+		assert.NilError(t, err)
+
+		matches, err := filepath.Glob(filepath.Join(output, "*_"+TrafficOutputFilename+".json"))
+		assert.NilError(t, err)
+		assert.Equal(t, 1, len(matches))
+		data, err := os.ReadFile(matches[0])
+		assert.NilError(t, err)
+		result := pkg.TrafficResult{}
+		assert.NilError(t, json.Unmarshal(data, &result))
+		assert.Equal(t, 1, result.Succeeded)
+		m := result.Measurment[0]
+		assert.Equal(t, "", m.Error)
+		assert.Equal(t, "ksvc-1-rev-2:80:stable,ksvc-1-rev-3:20:canary", m.Traffic)
+		assert.Equal(t, 2, len(m.Tags))
+		for _, tag := range m.Tags {
+			assert.Equal(t, "", tag.Error)
+			assert.Assert(t, tag.ReadyDuration > 0)
+		}
+	})
+
+	t.Run("re-apply the split served by the route", func(t *testing.T) {
+		p := setup()
+		inputs := pkg.TrafficArgs{
+			SvcPrefix:        "ksvc",
+			SvcRange:         "1,1",
+			Namespace:        "ns-1",
+			Concurrency:      1,
+			Traffic:          "50,50",
+			ResolvableDomain: true,
+			PollInterval:     10 * time.Millisecond,
+			Timeout:          time.Second,
+			Output:           t.TempDir(),
+		}
+		assert.NilError(t, SplitServicesTraffic(p, inputs))
+
+		output := t.TempDir()
+		inputs.Output = output
+		assert.NilError(t, SplitServicesTraffic(p, inputs))
+		matches, err := filepath.Glob(filepath.Join(output, "*_"+TrafficOutputFilename+".json"))
+		assert.NilError(t, err)
+		assert.Equal(t, 1, len(matches))
+		data, err := os.ReadFile(matches[0])
+		assert.NilError(t, err)
+		result := pkg.TrafficResult{}
+		assert.NilError(t, json.Unmarshal(data, &result))
+		assert.Equal(t, 1, result.Succeeded)
+		assert.Equal(t, 1, result.Unchanged)
+		assert.Equal(t, "", result.Measurment[0].Error)
+		assert.Assert(t, result.Measurment[0].Unchanged)
+	})
+
+	t.Run("not enough revisions", func(t *testing.T) {
+		inputs := pkg.TrafficArgs{
+			SvcPrefix:    "ksvc",
+			SvcRange:     "1,1",
+			Namespace:    "ns-1",
+			Concurrency:  1,
+			Traffic:      "25,25,25,25",
+			PollInterval: 10 * time.Millisecond,
+			Timeout:      time.Second,
+			Output:       t.TempDir(),
+		}
+		err := SplitServicesTraffic(setup(), inputs)
+		assert.ErrorContains(t, err, "all 1 traffic splits failed")
+
+		inputs.Traffic = "25"
+		err = SplitServicesTraffic(setup(), inputs)
+		assert.ErrorContains(t, err, "traffic percents must sum to 100")
+	})
+}
@@ -0,0 +1,395 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/target"
+
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+)
+
+const (
+	UpdateOutputFilename = "ksvc_update_time"
+	DefaultUpdateEnvName = "KPERF_REVISION"
+)
+
+func NewServiceUpdateCommand(p *pkg.PerfParams) *cobra.Command {
+	updateArgs := pkg.UpdateArgs{}
+	serviceUpdateCommand := &cobra.Command{
+		Use:   "update",
+		Short: "Update Knative service and measure revision rollout",
+		Long: `Update Knative service to roll out new revisions and measure rollout time
+
+Each update bumps an environment variable of the service (or sets a new image), then measures
+the time for the new Revision to be ready, for the Route to switch to it and for the Pods of
+the old Revision to be terminated.
+
+For example:
+# To roll out a new revision of services svc-1 to svc-200 three times, 5 updates per second at most
+kperf service update --svc-prefix svc --range 1,200 --namespace ns --concurrency 20 --rate 5 --iterations 3
+
+# To roll out a new image
+kperf service update --svc-prefix svc --range 1,200 --namespace ns --image gcr.io/knative-samples/helloworld-go:v2
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().NFlag() == 0 {
+				return fmt.Errorf("'service update' requires flag(s)")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return UpdateServices(p, updateArgs)
+		},
+	}
+
+	serviceUpdateCommand.Flags().StringVarP(&updateArgs.Svc, "svc", "", "", "Service name")
+	serviceUpdateCommand.Flags().StringVarP(&updateArgs.SvcRange, "range", "r", "", "Desired service range like 1,500 or 1-5,8,20-30")
+	serviceUpdateCommand.Flags().StringVarP(&updateArgs.Namespace, "namespace", "", "", "Service namespace")
+	serviceUpdateCommand.Flags().StringVarP(&updateArgs.SvcPrefix, "svc-prefix", "", "", "Service name prefix")
+	serviceUpdateCommand.Flags().StringVarP(&updateArgs.RunID, "run-id", "", "", "Only select the services created by the kperf run with this ID, svc and svc-prefix are optional if set")
+	serviceUpdateCommand.Flags().StringVarP(&updateArgs.NamespaceRange, "namespace-range", "", "", "Service namespace range like 1,500 or 1-5,8,20-30")
+	serviceUpdateCommand.Flags().StringVarP(&updateArgs.NamespacePrefix, "namespace-prefix", "", "", "Service namespace prefix")
+	serviceUpdateCommand.Flags().IntVarP(&updateArgs.Concurrency, "concurrency", "c", 10, "Number of services updated at the same time")
+	serviceUpdateCommand.Flags().Float64VarP(&updateArgs.Rate, "rate", "", 0, "Maximum number of updates per second, 0 means no limit")
+	serviceUpdateCommand.Flags().IntVarP(&updateArgs.Iterations, "iterations", "i", 1, "Number of revisions rolled out per service")
+	serviceUpdateCommand.Flags().StringVarP(&updateArgs.EnvName, "env", "", DefaultUpdateEnvName, "Environment variable bumped to roll out a new revision")
+	serviceUpdateCommand.Flags().StringVarP(&updateArgs.Image, "image", "", "", "Image to roll out instead of bumping the environment variable, only with a single iteration")
+	serviceUpdateCommand.Flags().DurationVarP(&updateArgs.PollInterval, "poll-interval", "", time.Second, "Interval to check the rollout progress")
+	serviceUpdateCommand.Flags().DurationVarP(&updateArgs.Timeout, "timeout", "", 10*time.Minute, "Duration to wait for a rollout to complete")
+	serviceUpdateCommand.Flags().BoolVarP(&updateArgs.Verbose, "verbose", "v", false, "Service verbose result")
+	serviceUpdateCommand.Flags().StringVarP(&updateArgs.Output, "output", "o", ".", "Measure result location")
+	addTargetFlags(serviceUpdateCommand, &updateArgs.Selector, &updateArgs.SvcRegex, &updateArgs.NamespaceSelector, &updateArgs.NamespaceRegex)
+	return serviceUpdateCommand
+}
+
+// UpdateServices rolls out new revisions of existing services and measures the rollout time
+func UpdateServices(params *pkg.PerfParams, inputs pkg.UpdateArgs) error {
+	if inputs.Concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive, given %d", inputs.Concurrency)
+	}
+	if inputs.Iterations <= 0 {
+		return fmt.Errorf("iterations must be positive, given %d", inputs.Iterations)
+	}
+	if inputs.Rate < 0 {
+		return fmt.Errorf("rate must not be negative, given %v", inputs.Rate)
+	}
+	if inputs.Image == "" && inputs.EnvName == "" {
+		return errors.New("both env and image are empty")
+	}
+	if inputs.Image != "" && inputs.Iterations > 1 {
+		return fmt.Errorf("image is rolled out once, given %d iterations", inputs.Iterations)
+	}
+
+	ctx := context.Background()
+	nsNameList, err := target.Namespaces{Name: inputs.Namespace, Prefix: inputs.NamespacePrefix, Range: inputs.NamespaceRange,
+		Selector: inputs.NamespaceSelector, Regex: inputs.NamespaceRegex}.Resolve(ctx, params.ClientSet)
+	if err != nil {
+		return err
+	}
+	ksvcClient, err := params.NewServingClient()
+	if err != nil {
+		return fmt.Errorf("failed to create serving client %s", err)
+	}
+	objs, err := getServices(ctx, ksvcClient, nsNameList, target.Services{Name: inputs.Svc, Prefix: inputs.SvcPrefix, Range: inputs.SvcRange,
+		Selector: inputs.Selector, Regex: inputs.SvcRegex, RunID: inputs.RunID})
+	if err != nil {
+		return err
+	}
+
+	updateResult := updateAndMeasure(ctx, params, ksvcClient, inputs, objs)
+
+	knativeVersion := GetKnativeVersion(params)
+	ingressInfo := GetIngressController(params)
+	updateResult.KnativeInfo.ServingVersion = knativeVersion["serving"]
+	updateResult.KnativeInfo.EventingVersion = knativeVersion["eventing"]
+	updateResult.KnativeInfo.IngressController = ingressInfo["ingressController"]
+	updateResult.KnativeInfo.IngressVersion = ingressInfo["version"]
+
+	rows := [][]string{{"svc_name", "svc_namespace", "iteration", "old_revision", "new_revision",
+		"revision_ready", "route_ready", "old_pods_terminated", "error"}}
+	for _, m := range updateResult.Measurment {
+		rows = append(rows, []string{m.ServiceName, m.ServiceNamespace, strconv.Itoa(m.Iteration), m.OldRevision, m.NewRevision,
+			formatSeconds(m.RevisionReadyDuration), formatSeconds(m.RouteReadyDuration), formatSeconds(m.OldPodsTerminatedDuration),
+			m.Error})
+	}
+
+	// generate CSV, HTML and JSON outputs from rows and updateResult
+	err = GenerateOutput(inputs.Output, UpdateOutputFilename, true, true, true, rows, updateResult)
+	if err != nil {
+		fmt.Printf("failed to generate output: %s\n", err)
+		return err
+	}
+
+	if updateResult.Succeeded == 0 {
+		return fmt.Errorf("all %d rollouts failed", updateResult.Total)
+	}
+	return nil
+}
+
+func updateAndMeasure(ctx context.Context, params *pkg.PerfParams, ksvcClient servingv1client.ServingV1Interface, inputs pkg.UpdateArgs, objs []ServicesToScale) pkg.UpdateResult {
+	result := pkg.UpdateResult{}
+
+	// limiter releases one update every 1/rate seconds, updates are only bounded by concurrency without it
+	var limiter <-chan time.Time
+	if inputs.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / inputs.Rate))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	// a service is handled by a single worker so that its rollouts don't overlap
+	svcChannel := make(chan ServicesToScale)
+	var wg sync.WaitGroup
+	var m sync.Mutex
+	for i := 0; i < inputs.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range svcChannel {
+				for j := 1; j <= inputs.Iterations; j++ {
+					if limiter != nil {
+						<-limiter
+					}
+					r := runServiceUpdate(ctx, params, ksvcClient, inputs, obj.Namespace, obj.Service.Name)
+					r.Iteration = j
+					if r.Error != "" {
+						fmt.Printf("failed to roll out service %s/%s in iteration %d: %s\n", obj.Namespace, obj.Service.Name, j, r.Error)
+					} else if inputs.Verbose {
+						fmt.Printf("[Verbose] Service %s/%s: revision %s ready in %fs, route ready in %fs, old pods terminated in %fs\n",
+							obj.Namespace, obj.Service.Name, r.NewRevision, r.RevisionReadyDuration, r.RouteReadyDuration, r.OldPodsTerminatedDuration)
+					}
+					m.Lock()
+					result.Measurment = append(result.Measurment, r)
+					m.Unlock()
+				}
+			}
+		}()
+	}
+	for _, obj := range objs {
+		svcChannel <- obj
+	}
+	close(svcChannel)
+	wg.Wait()
+
+	var revisionReadyList, routeReadyList, oldPodsTerminatedList []float64
+	for _, r := range result.Measurment {
+		result.Total++
+		if r.Error != "" {
+			result.Failed++
+			continue
+		}
+		result.Succeeded++
+		revisionReadyList = append(revisionReadyList, r.RevisionReadyDuration)
+		routeReadyList = append(routeReadyList, r.RouteReadyDuration)
+		oldPodsTerminatedList = append(oldPodsTerminatedList, r.OldPodsTerminatedDuration)
+	}
+
+	fmt.Printf("-------- Measurement --------\n")
+	fmt.Printf("Total: %d | Succeeded: %d Failed: %d\n", result.Total, result.Succeeded, result.Failed)
+	if result.Succeeded > 0 {
+		fmt.Printf("revision ready latency result:\n")
+		result.RevisionReady = latencyResultHandler(revisionReadyList)
+		fmt.Printf("route ready latency result:\n")
+		result.RouteReady = latencyResultHandler(routeReadyList)
+		fmt.Printf("old pods terminated latency result:\n")
+		result.OldPodsTerminated = latencyResultHandler(oldPodsTerminatedList)
+	}
+	return result
+}
+
+// runServiceUpdate rolls out a new revision of the service and measures, from the update, the time for
+// the new Revision to be ready, for the Route to serve it and for the Pods of the old Revision to be terminated
+func runServiceUpdate(ctx context.Context, params *pkg.PerfParams, ksvcClient servingv1client.ServingV1Interface, inputs pkg.UpdateArgs, namespace, name string) pkg.ServiceUpdateResult {
+	result := pkg.ServiceUpdateResult{ServiceName: name, ServiceNamespace: namespace}
+
+	svc, err := ksvcClient.Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to get service: %s", err)
+		return result
+	}
+	result.OldRevision = svc.Status.LatestReadyRevisionName
+
+	payload, err := updatePatch(svc, inputs.EnvName, inputs.Image)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.UpdateTime = time.Now()
+	svc, err = ksvcClient.Services(namespace).Patch(ctx, name, types.JSONPatchType, payload, metav1.PatchOptions{})
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to update service: %s", err)
+		return result
+	}
+	generation := svc.Generation
+
+	ctx, cancel := context.WithTimeout(ctx, inputs.Timeout)
+	defer cancel()
+
+	// the new Revision is ready once the Service observed the update and its latest created Revision is ready
+	err = wait.PollImmediateUntilWithContext(ctx, inputs.PollInterval, func(ctx context.Context) (bool, error) {
+		svc, err := ksvcClient.Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		if svc.Status.ObservedGeneration < generation || svc.Status.LatestCreatedRevisionName == result.OldRevision ||
+			svc.Status.LatestReadyRevisionName != svc.Status.LatestCreatedRevisionName {
+			return false, nil
+		}
+		result.NewRevision = svc.Status.LatestReadyRevisionName
+		return true, nil
+	})
+	if err != nil {
+		result.Error = fmt.Sprintf("timeout waiting for the new revision to be ready: %s", err)
+		return result
+	}
+	result.RevisionReadyDuration = time.Since(result.UpdateTime).Seconds()
+
+	err = wait.PollImmediateUntilWithContext(ctx, inputs.PollInterval, func(ctx context.Context) (bool, error) {
+		route, err := ksvcClient.Routes(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		return routeServesRevision(route, result.NewRevision), nil
+	})
+	if err != nil {
+		result.Error = fmt.Sprintf("timeout waiting for the route to serve revision %s: %s", result.NewRevision, err)
+		return result
+	}
+	result.RouteReadyDuration = time.Since(result.UpdateTime).Seconds()
+
+	if result.OldRevision != "" {
+		selector := labels.SelectorFromSet(labels.Set{serving.RevisionLabelKey: result.OldRevision})
+		err = wait.PollImmediateUntilWithContext(ctx, inputs.PollInterval, func(ctx context.Context) (bool, error) {
+			pods, err := params.ClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+			if err != nil {
+				return false, nil
+			}
+			return len(pods.Items) == 0, nil
+		})
+		if err != nil {
+			result.Error = fmt.Sprintf("timeout waiting for the pods of revision %s to be terminated: %s", result.OldRevision, err)
+			return result
+		}
+	}
+	result.OldPodsTerminatedDuration = time.Since(result.UpdateTime).Seconds()
+	return result
+}
+
+// routeServesRevision returns true if the route is ready, observed its latest spec and sends traffic to revision
+func routeServesRevision(route *servingv1.Route, revision string) bool {
+	if !route.IsReady() || route.Status.ObservedGeneration < route.Generation {
+		return false
+	}
+	for _, t := range route.Status.Traffic {
+		if t.RevisionName == revision {
+			return true
+		}
+	}
+	return false
+}
+
+// updatePatch returns the JSON patch setting the image of the first container of the service, or
+// bumping its environment variable envName if image is empty. A named revision template is renamed
+// as Knative only creates a revision for a new name, and a service pinning its traffic to revisions is
+// rejected since the route would never serve the new revision
+func updatePatch(svc *servingv1.Service, envName, image string) ([]byte, error) {
+	containers := svc.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("service %s/%s has no container", svc.Namespace, svc.Name)
+	}
+	if pinsTraffic(svc) {
+		return nil, fmt.Errorf("service %s/%s pins its traffic to revisions, the new revision would not be served", svc.Namespace, svc.Name)
+	}
+
+	payload := pkg.PatchStringValue{}
+	if image != "" {
+		if containers[0].Image == image {
+			return nil, fmt.Errorf("service %s/%s already runs image %s", svc.Namespace, svc.Name, image)
+		}
+		payload.Op = "replace"
+		payload.Path = "/spec/template/spec/containers/0/image"
+		payload.Value = image
+	} else {
+		value := strconv.FormatInt(time.Now().UnixNano(), 10)
+		payload.Op = "add"
+		payload.Path = "/spec/template/spec/containers/0/env"
+		payload.Value = []map[string]string{{"name": envName, "value": value}}
+		for i, env := range containers[0].Env {
+			if env.Name == envName {
+				payload.Op = "replace"
+				payload.Path = fmt.Sprintf("/spec/template/spec/containers/0/env/%d/value", i)
+				payload.Value = value
+				break
+			}
+		}
+		if payload.Op == "add" && len(containers[0].Env) > 0 {
+			payload.Path = "/spec/template/spec/containers/0/env/-"
+			payload.Value = map[string]string{"name": envName, "value": value}
+		}
+	}
+	payloads := []pkg.PatchStringValue{payload}
+	if svc.Spec.Template.Name != "" {
+		payloads = append(payloads, pkg.PatchStringValue{
+			Op:    "replace",
+			Path:  "/spec/template/metadata/name",
+			Value: nextRevisionName(svc),
+		})
+	}
+	return json.Marshal(payloads)
+}
+
+// pinsTraffic returns true if no traffic target of the service follows its latest revision
+func pinsTraffic(svc *servingv1.Service) bool {
+	if len(svc.Spec.Traffic) == 0 {
+		return false
+	}
+	for _, t := range svc.Spec.Traffic {
+		if t.LatestRevision != nil && *t.LatestRevision || t.LatestRevision == nil && t.RevisionName == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// nextRevisionName returns the name of the revision following the named revision template of the service,
+// "<svc>-rev-<n+1>" after a revision named by generate like "<svc>-rev-<n>", "<svc>-<generation+1>" otherwise
+func nextRevisionName(svc *servingv1.Service) string {
+	prefix := svc.Name + "-rev-"
+	if strings.HasPrefix(svc.Spec.Template.Name, prefix) {
+		if n, err := strconv.Atoi(strings.TrimPrefix(svc.Spec.Template.Name, prefix)); err == nil {
+			return generatedRevisionName(svc.Name, n+1)
+		}
+	}
+	return fmt.Sprintf("%s-%d", svc.Name, svc.Generation+1)
+}
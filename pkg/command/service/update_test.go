@@ -0,0 +1,287 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/testutil"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/ptr"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
+)
+
+// fakeRollout simulates the Knative controllers: each patch of a service rolls out a new revision
+// which is immediately ready and served by the route, and the pods of the old revision are deleted
+type fakeRollout struct {
+	lock      sync.Mutex
+	client    *k8sfake.Clientset
+	revisions map[string]int
+	patches   []string
+}
+
+func newRevisionName(name string, generation int) string {
+	return fmt.Sprintf("%s-%05d", name, generation)
+}
+
+func (f *fakeRollout) service(ns, name string) *servingv1.Service {
+	revision := newRevisionName(name, f.revisions[name])
+	svc := &servingv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Generation: int64(f.revisions[name])},
+		Spec: servingv1.ServiceSpec{
+			ConfigurationSpec: servingv1.ConfigurationSpec{
+				Template: servingv1.RevisionTemplateSpec{
+					Spec: servingv1.RevisionSpec{
+						PodSpec: corev1.PodSpec{
+							Containers: []corev1.Container{{Image: "gcr.io/knative-samples/helloworld-go"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	svc.Status.ObservedGeneration = svc.Generation
+	svc.Status.LatestCreatedRevisionName = revision
+	svc.Status.LatestReadyRevisionName = revision
+	return svc
+}
+
+func (f *fakeRollout) route(ns, name string) *servingv1.Route {
+	route := &servingv1.Route{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}}
+	route.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionReady, Status: corev1.ConditionTrue}}
+	route.Status.Traffic = []servingv1.TrafficTarget{{RevisionName: newRevisionName(name, f.revisions[name])}}
+	return route
+}
+
+func (f *fakeRollout) install(fake *clienttesting.Fake) {
+	fake.AddReactor("list", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		list := &servingv1.ServiceList{}
+		for name := range f.revisions {
+			list.Items = append(list.Items, *f.service(a.GetNamespace(), name))
+		}
+		return true, list, nil
+	})
+	fake.AddReactor("get", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		return true, f.service(a.GetNamespace(), a.(clienttesting.GetAction).GetName()), nil
+	})
+	fake.AddReactor("get", "routes", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		return true, f.route(a.GetNamespace(), a.(clienttesting.GetAction).GetName()), nil
+	})
+	fake.AddReactor("patch", "services", func(a clienttesting.Action) (bool, runtime.Object, error) {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		patch := a.(clienttesting.PatchAction)
+		name := patch.GetName()
+		f.patches = append(f.patches, string(patch.GetPatch()))
+		old := newRevisionName(name, f.revisions[name])
+		f.revisions[name]++
+		// only the first revisions have a pod
+		f.client.CoreV1().Pods(a.GetNamespace()).Delete(context.TODO(), old+"-pod", metav1.DeleteOptions{})
+		return true, f.service(a.GetNamespace(), name), nil
+	})
+}
+
+func revisionPod(ns, revision string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      revision + "-pod",
+			Namespace: ns,
+			Labels:    map[string]string{serving.RevisionLabelKey: revision},
+		},
+	}
+}
+
+func TestUpdateServices(t *testing.T) {
+	setup := func() (*pkg.PerfParams, *fakeRollout) {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-1"}}
+		client := k8sfake.NewSimpleClientset(ns, revisionPod("ns-1", "ksvc-1-00001"), revisionPod("ns-1", "ksvc-2-00001"))
+		rollout := &fakeRollout{client: client, revisions: map[string]int{"ksvc-1": 1, "ksvc-2": 1}}
+		fake := &clienttesting.Fake{}
+		rollout.install(fake)
+		fakeServing := &servingv1fake.FakeServingV1{Fake: fake}
+		p := &pkg.PerfParams{
+			ClientSet: client,
+			NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+				return fakeServing, nil
+			},
+		}
+		return p, rollout
+	}
+
+	t.Run("roll out new revisions", func(t *testing.T) {
+		p, rollout := setup()
+		output := t.TempDir()
+		inputs := pkg.UpdateArgs{
+			SvcPrefix:    "ksvc",
+			SvcRange:     "1,2",
+			Namespace:    "ns-1",
+			Concurrency:  2,
+			Rate:         100,
+			Iterations:   2,
+			EnvName:      DefaultUpdateEnvName,
+			PollInterval: 10 * time.Millisecond,
+			Timeout:      time.Second,
+			Output:       output,
+		}
+		err := UpdateServices(p, inputs)
+		assert.NilError(t, err)
+		assert.Equal(t, 4, len(rollout.patches))
+		assert.Equal(t, 3, rollout.revisions["ksvc-1"])
+		assert.Equal(t, 3, rollout.revisions["ksvc-2"])
+
+		matches, err := filepath.Glob(filepath.Join(output, "*_"+UpdateOutputFilename+".json"))
+		assert.NilError(t, err)
+		assert.Equal(t, 1, len(matches))
+		data, err := os.ReadFile(matches[0])
+		assert.NilError(t, err)
+		result := pkg.UpdateResult{}
+		assert.NilError(t, json.Unmarshal(data, &result))
+		assert.Equal(t, 4, result.Total)
+		assert.Equal(t, 4, result.Succeeded)
+		for _, m := range result.Measurment {
+			assert.Equal(t, "", m.Error)
+			assert.Equal(t, newRevisionName(m.ServiceName, m.Iteration), m.OldRevision)
+			assert.Equal(t, newRevisionName(m.ServiceName, m.Iteration+1), m.NewRevision)
+		}
+	})
+
+	t.Run("roll out a new image", func(t *testing.T) {
+		p, rollout := setup()
+		inputs := pkg.UpdateArgs{
+			Svc:          "ksvc-1",
+			Namespace:    "ns-1",
+			Concurrency:  1,
+			Iterations:   1,
+			Image:        "gcr.io/knative-samples/helloworld-go:v2",
+			PollInterval: 10 * time.Millisecond,
+			Timeout:      time.Second,
+			Output:       t.TempDir(),
+		}
+		err := UpdateServices(p, inputs)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, []string{`[{"op":"replace","path":"/spec/template/spec/containers/0/image","value":"gcr.io/knative-samples/helloworld-go:v2"}]`}, rollout.patches)
+	})
+
+	t.Run("rollouts failing", func(t *testing.T) {
+		p, _ := setup()
+		inputs := pkg.UpdateArgs{
+			Svc:          "ksvc-1",
+			Namespace:    "ns-1",
+			Concurrency:  1,
+			Iterations:   1,
+			Image:        "gcr.io/knative-samples/helloworld-go",
+			PollInterval: 10 * time.Millisecond,
+			Timeout:      time.Second,
+			Output:       t.TempDir(),
+		}
+		err := UpdateServices(p, inputs)
+		assert.ErrorContains(t, err, "all 1 rollouts failed")
+
+		inputs.Iterations = 0
+		err = UpdateServices(p, inputs)
+		assert.ErrorContains(t, err, "iterations must be positive")
+
+		// the image is only rolled out in the first iteration
+		inputs.Iterations = 2
+		inputs.Image = "gcr.io/knative-samples/helloworld-go:v2"
+		err = UpdateServices(p, inputs)
+		assert.ErrorContains(t, err, "image is rolled out once, given 2 iterations")
+	})
+}
+
+func TestUpdatePatch(t *testing.T) {
+	svc := &servingv1.Service{}
+	_, err := updatePatch(svc, DefaultUpdateEnvName, "")
+	assert.ErrorContains(t, err, "has no container")
+
+	svc.Spec.Template.Spec.Containers = []corev1.Container{{Image: "image"}}
+	patch, err := updatePatch(svc, "BUMP", "")
+	assert.NilError(t, err)
+	payloads := []pkg.PatchStringValue{}
+	assert.NilError(t, json.Unmarshal(patch, &payloads))
+	assert.Equal(t, "add", payloads[0].Op)
+	assert.Equal(t, "/spec/template/spec/containers/0/env", payloads[0].Path)
+
+	svc.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "OTHER", Value: "1"}}
+	patch, err = updatePatch(svc, "BUMP", "")
+	assert.NilError(t, err)
+	assert.NilError(t, json.Unmarshal(patch, &payloads))
+	assert.Equal(t, "add", payloads[0].Op)
+	assert.Equal(t, "/spec/template/spec/containers/0/env/-", payloads[0].Path)
+
+	svc.Spec.Template.Spec.Containers[0].Env = append(svc.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: "BUMP", Value: "1"})
+	patch, err = updatePatch(svc, "BUMP", "")
+	assert.NilError(t, err)
+	assert.NilError(t, json.Unmarshal(patch, &payloads))
+	assert.Equal(t, "replace", payloads[0].Op)
+	assert.Equal(t, "/spec/template/spec/containers/0/env/1/value", payloads[0].Path)
+
+	// a named revision template is renamed
+	svc.Name = "ksvc-1"
+	svc.Generation = 3
+	svc.Spec.Template.Name = generatedRevisionName("ksvc-1", 2)
+	patch, err = updatePatch(svc, "BUMP", "")
+	assert.NilError(t, err)
+	assert.NilError(t, json.Unmarshal(patch, &payloads))
+	assert.Equal(t, 2, len(payloads))
+	assert.Equal(t, "/spec/template/metadata/name", payloads[1].Path)
+	assert.Equal(t, "ksvc-1-rev-3", payloads[1].Value)
+
+	svc.Spec.Template.Name = "custom"
+	patch, err = updatePatch(svc, "BUMP", "")
+	assert.NilError(t, err)
+	assert.NilError(t, json.Unmarshal(patch, &payloads))
+	assert.Equal(t, "ksvc-1-4", payloads[1].Value)
+
+	// the traffic following the latest revision serves the new revision
+	latest := true
+	svc.Spec.Traffic = []servingv1.TrafficTarget{{RevisionName: "custom", Percent: ptr.Int64(50)}, {LatestRevision: &latest, Percent: ptr.Int64(50)}}
+	_, err = updatePatch(svc, "BUMP", "")
+	assert.NilError(t, err)
+
+	svc.Spec.Traffic = trafficTargets([]string{"ksvc-1-rev-1", "ksvc-1-rev-2"}, []trafficWeight{{Percent: 50}, {Percent: 50}})
+	_, err = updatePatch(svc, "BUMP", "")
+	assert.ErrorContains(t, err, "service /ksvc-1 pins its traffic to revisions")
+}
+
+func TestNewServiceUpdateCommand(t *testing.T) {
+	cmd := NewServiceUpdateCommand(&pkg.PerfParams{})
+	_, err := testutil.ExecuteCommand(cmd)
+	assert.ErrorContains(t, err, "'service update' requires flag(s)")
+}
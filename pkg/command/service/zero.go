@@ -0,0 +1,207 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
+	"knative.dev/serving/pkg/apis/serving"
+
+	"knative.dev/kperf/pkg"
+)
+
+// zeroState keeps the scales of the PodAutoscalers, the replicas of the Deployments and the pods of a Knative
+// Service, by name
+type zeroState struct {
+	scales   map[string]int32
+	replicas map[string]int32
+	pods     map[string]bool
+}
+
+func newZeroState() *zeroState {
+	return &zeroState{scales: map[string]int32{}, replicas: map[string]int32{}, pods: map[string]bool{}}
+}
+
+// scaledToZero returns whether none of the PodAutoscalers wants or has a replica, none of the Deployments either, and
+// no pod is left, even terminating. The PodAutoscalers tell what the autoscaler decided, the Deployments and pods
+// what is actually left running
+func (s *zeroState) scaledToZero() bool {
+	for _, scale := range s.scales {
+		if scale != 0 {
+			return false
+		}
+	}
+	for _, replicas := range s.replicas {
+		if replicas != 0 {
+			return false
+		}
+	}
+	return len(s.pods) == 0
+}
+
+// setPodAutoscaler keeps the larger of the desired and actual scales of the PodAutoscaler, unknown before it is
+// reconciled
+func (s *zeroState) setPodAutoscaler(pa *autoscalingv1alpha1.PodAutoscaler) {
+	scale := int32(-1)
+	if pa.Status.DesiredScale != nil && pa.Status.ActualScale != nil {
+		scale = *pa.Status.DesiredScale
+		if *pa.Status.ActualScale > scale {
+			scale = *pa.Status.ActualScale
+		}
+	}
+	s.scales[pa.Name] = scale
+}
+
+func (s *zeroState) setDeployment(dp *appsv1.Deployment) {
+	replicas := dp.Status.Replicas
+	if dp.Spec.Replicas != nil && *dp.Spec.Replicas > replicas {
+		replicas = *dp.Spec.Replicas
+	}
+	s.replicas[dp.Name] = replicas
+}
+
+// apply updates the state from the event, and returns false if the event is an error
+func (s *zeroState) apply(event watch.Event) bool {
+	switch obj := event.Object.(type) {
+	case *autoscalingv1alpha1.PodAutoscaler:
+		if event.Type == watch.Deleted {
+			delete(s.scales, obj.Name)
+		} else {
+			s.setPodAutoscaler(obj)
+		}
+	case *appsv1.Deployment:
+		if event.Type == watch.Deleted {
+			delete(s.replicas, obj.Name)
+		} else {
+			s.setDeployment(obj)
+		}
+	case *corev1.Pod:
+		if event.Type == watch.Deleted {
+			delete(s.pods, obj.Name)
+		} else {
+			s.pods[obj.Name] = true
+		}
+	}
+	return event.Type != watch.Error
+}
+
+// waitScaledToZero waits until the PodAutoscalers of the Knative Service have a desired and actual scale of zero, its
+// Deployments have no replica and none of its pods is left, watching them from the state listed, and returns how long
+// it waited and whether the Knative Service was at zero before timeout. The next request is only a cold start if it was
+func waitScaledToZero(ctx context.Context, params *pkg.PerfParams, namespace, name string, timeout time.Duration) (time.Duration, bool, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	selector := labels.SelectorFromSet(labels.Set{serving.ServiceLabelKey: name}).String()
+	autoscalingClient, err := params.NewAutoscalingClient()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create autoscaling client: %w", err)
+	}
+	autoscalers := autoscalingClient.PodAutoscalers(namespace)
+	deployments := params.ClientSet.AppsV1().Deployments(namespace)
+	pods := params.ClientSet.CoreV1().Pods(namespace)
+
+	for {
+		paList, err := autoscalers.List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return waitedForZero(ctx, start, fmt.Errorf("failed to list the PodAutoscalers of service %s/%s: %w", namespace, name, err))
+		}
+		dpList, err := deployments.List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return waitedForZero(ctx, start, fmt.Errorf("failed to list the deployments of service %s/%s: %w", namespace, name, err))
+		}
+		podList, err := pods.List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return waitedForZero(ctx, start, fmt.Errorf("failed to list the pods of service %s/%s: %w", namespace, name, err))
+		}
+		state := newZeroState()
+		for i := range paList.Items {
+			state.setPodAutoscaler(&paList.Items[i])
+		}
+		for i := range dpList.Items {
+			state.setDeployment(&dpList.Items[i])
+		}
+		for _, pod := range podList.Items {
+			state.pods[pod.Name] = true
+		}
+		if state.scaledToZero() {
+			return time.Since(start), true, nil
+		}
+
+		// watch from the versions listed so that no change is missed
+		paWatcher, err := autoscalers.Watch(ctx, metav1.ListOptions{LabelSelector: selector, ResourceVersion: paList.ResourceVersion})
+		if err != nil {
+			return waitedForZero(ctx, start, fmt.Errorf("failed to watch the PodAutoscalers of service %s/%s: %w", namespace, name, err))
+		}
+		dpWatcher, err := deployments.Watch(ctx, metav1.ListOptions{LabelSelector: selector, ResourceVersion: dpList.ResourceVersion})
+		if err != nil {
+			paWatcher.Stop()
+			return waitedForZero(ctx, start, fmt.Errorf("failed to watch the deployments of service %s/%s: %w", namespace, name, err))
+		}
+		podWatcher, err := pods.Watch(ctx, metav1.ListOptions{LabelSelector: selector, ResourceVersion: podList.ResourceVersion})
+		if err != nil {
+			paWatcher.Stop()
+			dpWatcher.Stop()
+			return waitedForZero(ctx, start, fmt.Errorf("failed to watch the pods of service %s/%s: %w", namespace, name, err))
+		}
+		zero := watchScaledToZero(ctx, state, paWatcher, dpWatcher, podWatcher)
+		paWatcher.Stop()
+		dpWatcher.Stop()
+		podWatcher.Stop()
+		if zero || ctx.Err() != nil {
+			return time.Since(start), zero, nil
+		}
+		// a watch was closed or failed, list again
+	}
+}
+
+// watchScaledToZero applies the events to the state until the Knative Service is at zero, a watch is closed or fails,
+// or ctx is done, and returns whether the Knative Service is at zero
+func watchScaledToZero(ctx context.Context, state *zeroState, paWatcher, dpWatcher, podWatcher watch.Interface) bool {
+	for {
+		var event watch.Event
+		var ok bool
+		select {
+		case event, ok = <-paWatcher.ResultChan():
+		case event, ok = <-dpWatcher.ResultChan():
+		case event, ok = <-podWatcher.ResultChan():
+		case <-ctx.Done():
+			return false
+		}
+		if !ok || !state.apply(event) {
+			return false
+		}
+		if state.scaledToZero() {
+			return true
+		}
+	}
+}
+
+// waitedForZero returns the error, unless it is caused by the timeout which only means that the Knative Service is
+// not at zero
+func waitedForZero(ctx context.Context, start time.Time, err error) (time.Duration, bool, error) {
+	if ctx.Err() != nil {
+		return time.Since(start), false, nil
+	}
+	return time.Since(start), false, err
+}
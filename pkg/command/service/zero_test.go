@@ -0,0 +1,116 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
+	"knative.dev/serving/pkg/apis/serving"
+	autoscalingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/autoscaling/v1alpha1"
+	autoscalingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/autoscaling/v1alpha1/fake"
+
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/testutil"
+)
+
+// zeroParams returns the params of the fake clients with the PodAutoscalers
+func zeroParams(client *k8sfake.Clientset, pas ...runtime.Object) *pkg.PerfParams {
+	fakeAutoscaling := &autoscalingv1fake.FakeAutoscalingV1alpha1{Fake: testutil.NewKnativeFake(pas...)}
+	return &pkg.PerfParams{
+		ClientSet: client,
+		NewAutoscalingClient: func() (autoscalingv1client.AutoscalingV1alpha1Interface, error) {
+			return fakeAutoscaling, nil
+		},
+	}
+}
+
+func TestWaitScaledToZero(t *testing.T) {
+	restoreClock()
+	ctx := context.Background()
+	svcLabels := map[string]string{serving.ServiceLabelKey: "ksvc-1"}
+	deployment := func(replicas int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1-00001-deployment", Namespace: "ns-1", Labels: svcLabels},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{Replicas: replicas},
+		}
+	}
+	podAutoscaler := func(desired, actual int32) *autoscalingv1alpha1.PodAutoscaler {
+		pa := &autoscalingv1alpha1.PodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1-00001", Namespace: "ns-1", Labels: svcLabels}}
+		pa.Status.DesiredScale, pa.Status.ActualScale = &desired, &actual
+		return pa
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1-00001-deployment-abc", Namespace: "ns-1", Labels: svcLabels}}
+
+	t.Run("already at zero", func(t *testing.T) {
+		// the pods of other services are ignored
+		other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-2-pod", Namespace: "ns-1",
+			Labels: map[string]string{serving.ServiceLabelKey: "ksvc-2"}}}
+		p := zeroParams(k8sfake.NewSimpleClientset(deployment(0), other), podAutoscaler(0, 0))
+		_, zero, err := waitScaledToZero(ctx, p, "ns-1", "ksvc-1", time.Second)
+		assert.NilError(t, err)
+		assert.Assert(t, zero)
+	})
+
+	t.Run("wait for the scale, the replicas and the pods to be gone", func(t *testing.T) {
+		client := k8sfake.NewSimpleClientset(deployment(1), pod)
+		p := zeroParams(client, podAutoscaler(1, 1))
+		autoscalingClient, err := p.NewAutoscalingClient()
+		assert.NilError(t, err)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			autoscalingClient.PodAutoscalers("ns-1").Update(ctx, podAutoscaler(0, 1), metav1.UpdateOptions{})
+			client.AppsV1().Deployments("ns-1").Update(ctx, deployment(0), metav1.UpdateOptions{})
+			time.Sleep(50 * time.Millisecond)
+			autoscalingClient.PodAutoscalers("ns-1").Update(ctx, podAutoscaler(0, 0), metav1.UpdateOptions{})
+			time.Sleep(50 * time.Millisecond)
+			client.CoreV1().Pods("ns-1").Delete(ctx, pod.Name, metav1.DeleteOptions{})
+		}()
+		wait, zero, err := waitScaledToZero(ctx, p, "ns-1", "ksvc-1", 5*time.Second)
+		assert.NilError(t, err)
+		assert.Assert(t, zero)
+		assert.Assert(t, wait >= 150*time.Millisecond)
+	})
+
+	t.Run("not at zero after timeout", func(t *testing.T) {
+		// a terminating pod may still serve the request
+		p := zeroParams(k8sfake.NewSimpleClientset(deployment(0), pod), podAutoscaler(0, 0))
+		_, zero, err := waitScaledToZero(ctx, p, "ns-1", "ksvc-1", 50*time.Millisecond)
+		assert.NilError(t, err)
+		assert.Assert(t, !zero)
+
+		// the autoscaler still wants a replica, or the PodAutoscaler is not reconciled yet
+		p = zeroParams(k8sfake.NewSimpleClientset(deployment(0)), podAutoscaler(1, 0))
+		_, zero, err = waitScaledToZero(ctx, p, "ns-1", "ksvc-1", 50*time.Millisecond)
+		assert.NilError(t, err)
+		assert.Assert(t, !zero)
+
+		unknown := podAutoscaler(0, 0)
+		unknown.Status.ActualScale = nil
+		p = zeroParams(k8sfake.NewSimpleClientset(deployment(0)), unknown)
+		_, zero, err = waitScaledToZero(ctx, p, "ns-1", "ksvc-1", 50*time.Millisecond)
+		assert.NilError(t, err)
+		assert.Assert(t, !zero)
+	})
+}
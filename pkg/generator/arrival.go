@@ -0,0 +1,202 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ArrivalFixed   = "fixed"
+	ArrivalRamp    = "ramp"
+	ArrivalStep    = "step"
+	ArrivalPoisson = "poisson"
+	ArrivalBurst   = "burst"
+)
+
+// Arrival is the schedule of a BatchGenerator. Next is called each time the previous resources have been
+// released with the number of resources remaining, and returns how long to wait before releasing the next
+// `batch` resources. An Arrival is stateful and used by a single BatchGenerator.
+type Arrival interface {
+	Next(remaining int) (wait time.Duration, batch int)
+	// Name returns the name of the arrival pattern
+	Name() string
+	// Parameters returns the parameters of the arrival pattern, to be recorded with the results
+	Parameters() map[string]string
+}
+
+// FixedArrival releases `Batch` resources every `Interval`
+type FixedArrival struct {
+	Interval time.Duration
+	Batch    int
+}
+
+func (a *FixedArrival) Next(int) (time.Duration, int) {
+	return a.Interval, a.Batch
+}
+
+func (a *FixedArrival) Name() string {
+	return ArrivalFixed
+}
+
+func (a *FixedArrival) Parameters() map[string]string {
+	return map[string]string{
+		"interval": a.Interval.String(),
+		"batch":    strconv.Itoa(a.Batch),
+	}
+}
+
+// RampArrival releases resources every `Interval`, starting with `Start` resources and
+// releasing `Increment` more resources each time, at least one resource is released each time
+type RampArrival struct {
+	Interval  time.Duration
+	Start     int
+	Increment int
+
+	released int
+}
+
+func (a *RampArrival) Next(int) (time.Duration, int) {
+	batch := a.Start + a.released*a.Increment
+	a.released++
+	if batch < 1 {
+		batch = 1
+	}
+	return a.Interval, batch
+}
+
+func (a *RampArrival) Name() string {
+	return ArrivalRamp
+}
+
+func (a *RampArrival) Parameters() map[string]string {
+	return map[string]string{
+		"interval":  a.Interval.String(),
+		"start":     strconv.Itoa(a.Start),
+		"increment": strconv.Itoa(a.Increment),
+	}
+}
+
+// ArrivalPlateau is a plateau of a StepArrival, `Batch` resources are released `Times` times
+type ArrivalPlateau struct {
+	Batch int
+	Times int
+}
+
+// StepArrival releases resources every `Interval` following the plateaus of `Steps`,
+// the batch of the last plateau is kept until all resources are released
+type StepArrival struct {
+	Interval time.Duration
+	Steps    []ArrivalPlateau
+
+	step     int
+	released int
+}
+
+func (a *StepArrival) Next(int) (time.Duration, int) {
+	for a.step < len(a.Steps)-1 && a.released >= a.Steps[a.step].Times {
+		a.step++
+		a.released = 0
+	}
+	a.released++
+	return a.Interval, a.Steps[a.step].Batch
+}
+
+func (a *StepArrival) Name() string {
+	return ArrivalStep
+}
+
+func (a *StepArrival) Parameters() map[string]string {
+	steps := make([]string, 0, len(a.Steps))
+	for _, s := range a.Steps {
+		steps = append(steps, fmt.Sprintf("%dx%d", s.Batch, s.Times))
+	}
+	return map[string]string{
+		"interval": a.Interval.String(),
+		"steps":    strings.Join(steps, ","),
+	}
+}
+
+// PoissonArrival releases resources one by one with exponentially distributed waits,
+// so that `Rate` resources are released per second on average
+type PoissonArrival struct {
+	Rate float64
+	Rand *rand.Rand
+}
+
+func (a *PoissonArrival) Next(int) (time.Duration, int) {
+	if a.Rand == nil {
+		a.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return time.Duration(a.Rand.ExpFloat64() / a.Rate * float64(time.Second)), 1
+}
+
+func (a *PoissonArrival) Name() string {
+	return ArrivalPoisson
+}
+
+func (a *PoissonArrival) Parameters() map[string]string {
+	return map[string]string{
+		"rate": strconv.FormatFloat(a.Rate, 'f', -1, 64),
+	}
+}
+
+// BurstArrival releases all the resources at once after `Delay`
+type BurstArrival struct {
+	Delay time.Duration
+}
+
+func (a *BurstArrival) Next(remaining int) (time.Duration, int) {
+	return a.Delay, remaining
+}
+
+func (a *BurstArrival) Name() string {
+	return ArrivalBurst
+}
+
+func (a *BurstArrival) Parameters() map[string]string {
+	return map[string]string{
+		"delay": a.Delay.String(),
+	}
+}
+
+// ParseArrivalSteps parses plateaus like "5x3,10x3,20x1", i.e. 5 resources 3 times, then 10 resources 3 times
+// and 20 resources for the rest
+func ParseArrivalSteps(steps string) ([]ArrivalPlateau, error) {
+	result := []ArrivalPlateau{}
+	for _, s := range strings.Split(steps, ",") {
+		parts := strings.Split(strings.TrimSpace(s), "x")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected steps like 5x3,10x3, given %s", steps)
+		}
+		batch, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		times, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if batch <= 0 || times <= 0 {
+			return nil, fmt.Errorf("batch and times of step %s must be positive", s)
+		}
+		result = append(result, ArrivalPlateau{Batch: batch, Times: times})
+	}
+	return result, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generator_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"knative.dev/kperf/pkg/generator"
+)
+
+func nextBatches(arrival generator.Arrival, remaining int, n int) []int {
+	batches := []int{}
+	for i := 0; i < n; i++ {
+		_, batch := arrival.Next(remaining)
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+func TestArrivals(t *testing.T) {
+	t.Run("fixed arrival", func(t *testing.T) {
+		arrival := &generator.FixedArrival{Interval: time.Second, Batch: 3}
+		wait, _ := arrival.Next(10)
+		assert.Equal(t, time.Second, wait)
+		assert.DeepEqual(t, []int{3, 3, 3}, nextBatches(arrival, 10, 3))
+		assert.DeepEqual(t, map[string]string{"interval": "1s", "batch": "3"}, arrival.Parameters())
+	})
+
+	t.Run("ramp arrival", func(t *testing.T) {
+		arrival := &generator.RampArrival{Interval: time.Second, Start: 1, Increment: 2}
+		assert.DeepEqual(t, []int{1, 3, 5, 7}, nextBatches(arrival, 10, 4))
+		assert.Equal(t, generator.ArrivalRamp, arrival.Name())
+
+		// a decreasing ramp never stops releasing resources
+		arrival = &generator.RampArrival{Interval: time.Second, Start: 5, Increment: -3}
+		assert.DeepEqual(t, []int{5, 2, 1, 1}, nextBatches(arrival, 10, 4))
+	})
+
+	t.Run("step arrival", func(t *testing.T) {
+		arrival := &generator.StepArrival{Interval: time.Second, Steps: []generator.ArrivalPlateau{{Batch: 1, Times: 2}, {Batch: 5, Times: 1}, {Batch: 10, Times: 1}}}
+		assert.DeepEqual(t, []int{1, 1, 5, 10, 10}, nextBatches(arrival, 10, 5))
+		assert.Equal(t, "1x2,5x1,10x1", arrival.Parameters()["steps"])
+	})
+
+	t.Run("poisson arrival", func(t *testing.T) {
+		arrival := &generator.PoissonArrival{Rate: 100, Rand: rand.New(rand.NewSource(1))}
+		var total time.Duration
+		for i := 0; i < 1000; i++ {
+			wait, batch := arrival.Next(10)
+			assert.Equal(t, 1, batch)
+			total += wait
+		}
+		// 1000 arrivals at 100 per second should take about 10 seconds
+		assert.Assert(t, total > 9*time.Second && total < 11*time.Second, "unexpected total wait %s", total)
+	})
+
+	t.Run("burst arrival", func(t *testing.T) {
+		arrival := &generator.BurstArrival{Delay: time.Second}
+		wait, batch := arrival.Next(42)
+		assert.Equal(t, time.Second, wait)
+		assert.Equal(t, 42, batch)
+	})
+}
+
+func TestParseArrivalSteps(t *testing.T) {
+	steps, err := generator.ParseArrivalSteps("5x3, 10x1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []generator.ArrivalPlateau{{Batch: 5, Times: 3}, {Batch: 10, Times: 1}}, steps)
+
+	_, err = generator.ParseArrivalSteps("")
+	assert.ErrorContains(t, err, "expected steps like 5x3,10x3")
+
+	_, err = generator.ParseArrivalSteps("5x0")
+	assert.ErrorContains(t, err, "must be positive")
+
+	_, err = generator.ParseArrivalSteps("ax1")
+	assert.ErrorContains(t, err, "invalid syntax")
+}
+
+func TestArrivalBatchGenerator(t *testing.T) {
+	var generateFuncCalled uint64
+	generateFunc := func(ns string, index int) (string, string, error) {
+		atomic.AddUint64(&generateFuncCalled, 1)
+		return ns, fmt.Sprintf("%s-%d", ns, index), nil
+	}
+	postGeneratorFunc := func(ns, name string) error {
+		return nil
+	}
+
+	t.Run("burst generates everything at once", func(t *testing.T) {
+		generateFuncCalled = 0
+		start := time.Now()
+		generator.NewArrivalBatchGenerator(&generator.BurstArrival{Delay: 10 * time.Millisecond}, 8, 4, []string{"ns1"}, generateFunc, postGeneratorFunc).Generate(context.Background())
+		assert.Assert(t, time.Since(start) < time.Second)
+		assert.Assert(t, generateFuncCalled == 8)
+	})
+
+	t.Run("ramp generates increasing batches", func(t *testing.T) {
+		generateFuncCalled = 0
+		start := time.Now()
+		// batches of 1, 2, 3 and 4 released every 100ms
+		generator.NewArrivalBatchGenerator(&generator.RampArrival{Interval: 100 * time.Millisecond, Start: 1, Increment: 1}, 10, 4, []string{"ns1"}, generateFunc, postGeneratorFunc).Generate(context.Background())
+		duration := time.Since(start)
+		assert.Assert(t, duration >= 400*time.Millisecond && duration < time.Second, "unexpected duration %s", duration)
+		assert.Assert(t, generateFuncCalled == 10)
+	})
+}
@@ -15,102 +15,150 @@
 package generator
 
 import (
-	"os"
+	"context"
+	"sort"
+	"sync"
 	"time"
 )
 
 // func Generator do the generate action in namespace ns with the index as the suffix of the resource name
-// it returns the name and namespace of the generated resource
-type Generator func(ns string, index int) (string, string)
+// it returns the namespace and name of the generated resource, and the error if the generation failed
+type Generator func(ns string, index int) (string, string, error)
 
-// func PostGenerator is executed after Generator has been executed.
-// if the error is not nil, the whole generate process will exit
+// func PostGenerator is executed after Generator has succeeded.
+// if the error is not nil, the generated resource is reported as failed
 type PostGenerator func(string, string) error
 
-// BatchGenerator helps generate `count` of resource with `concurrency` number of gorutines. It executes `generateFunc` `batch` times
-// per time, and between each batch, it stop for `interval` time.
+// ItemResult is the result of the generation of the resource with the index
+type ItemResult struct {
+	Index     int
+	Namespace string
+	Name      string
+	// Created is true if Generator succeeded, even if PostGenerator failed afterwards
+	Created bool
+	// CreateLatency is the time taken by Generator
+	CreateLatency time.Duration
+	Err           error
+}
+
+// Report is the result of a generation. The resources which were never generated, because the generation was
+// cancelled, are not part of Attempted
+type Report struct {
+	Attempted int
+	Created   int
+	Failed    int
+	// Items are the results of the attempted resources sorted by index
+	Items []ItemResult
+}
+
+// BatchGenerator helps generate `count` of resource with `concurrency` number of gorutines. The `arrival` schedule
+// decides how many times `generateFunc` is executed per time and how long it stops between each batch.
 type BatchGenerator struct {
-	interval          time.Duration
+	arrival           Arrival
 	count             int
-	counter           int
-	batch             int
 	concurrency       int
 	namespaceList     []string
 	generateFunc      Generator
 	postGeneratorFunc PostGenerator
 
-	indexChan     chan int
-	finishedChan  chan int
-	finishedCount int
-	doneChan      chan bool
+	indexChan  chan int
+	resultChan chan ItemResult
 }
 
+// NewBatchGenerator returns a BatchGenerator executing `generateFunc` `batch` times per time, and stopping for
+// `interval` time between each batch
 func NewBatchGenerator(interval time.Duration, count, batch int, concurrency int, namespaceList []string, generator Generator, postGenerator PostGenerator) *BatchGenerator {
+	return NewArrivalBatchGenerator(&FixedArrival{Interval: interval, Batch: batch}, count, concurrency, namespaceList, generator, postGenerator)
+}
+
+// NewArrivalBatchGenerator returns a BatchGenerator following the `arrival` schedule
+func NewArrivalBatchGenerator(arrival Arrival, count int, concurrency int, namespaceList []string, generator Generator, postGenerator PostGenerator) *BatchGenerator {
 	return &BatchGenerator{
-		interval:          interval,
+		arrival:           arrival,
 		count:             count,
-		counter:           0,
-		batch:             batch,
 		concurrency:       concurrency,
 		namespaceList:     namespaceList,
 		generateFunc:      generator,
 		postGeneratorFunc: postGenerator,
 
-		indexChan:     make(chan int, batch*5),
-		finishedChan:  make(chan int, batch*5),
-		finishedCount: 0,
-		doneChan:      make(chan bool),
+		indexChan:  make(chan int, count),
+		resultChan: make(chan ItemResult, count),
 	}
 }
 
-func (bg *BatchGenerator) Generate() {
+// Generate generates the resources until all of them are attempted or ctx is done. The resources being generated
+// when ctx is done are completed, and the report of the attempted ones is returned along with the error of ctx.
+func (bg *BatchGenerator) Generate(ctx context.Context) (Report, error) {
+	report := Report{Items: []ItemResult{}}
 	// avoid the blocked channel
 	if bg.count == 0 {
-		return
+		return report, nil
 	}
-	ticker := time.NewTicker(bg.interval)
-	defer ticker.Stop()
-	go bg.checkFinished()
+	var wg sync.WaitGroup
 	for i := 0; i < bg.concurrency; i++ {
-		go bg.doGenerate()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bg.doGenerate(ctx)
+		}()
 	}
-	for {
+
+	counter := 0
+	wait, batch := bg.arrival.Next(bg.count)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for counter < bg.count && ctx.Err() == nil {
 		select {
-		case <-bg.doneChan:
-			return
-		case <-ticker.C:
+		case <-ctx.Done():
+		case <-timer.C:
 			i := 0
-			for bg.counter < bg.count && i < bg.batch {
-				bg.indexChan <- bg.counter
-				bg.counter++
+			for counter < bg.count && i < batch {
+				bg.indexChan <- counter
+				counter++
 				i++
 			}
+			if counter < bg.count {
+				wait, batch = bg.arrival.Next(bg.count - counter)
+				timer.Reset(wait)
+			}
 		}
 	}
+	close(bg.indexChan)
+	wg.Wait()
+	close(bg.resultChan)
 
-}
-
-func (bg *BatchGenerator) doGenerate() {
-	for {
-		select {
-		case <-bg.doneChan:
-			return
-		case index := <-bg.indexChan:
-			ns := bg.namespaceList[index%len(bg.namespaceList)]
-			ns, name := bg.generateFunc(ns, index)
-			if bg.postGeneratorFunc(ns, name) != nil {
-				os.Exit(1)
-			}
-			bg.finishedChan <- 1
+	for result := range bg.resultChan {
+		report.Attempted++
+		if result.Created {
+			report.Created++
+		}
+		if result.Err != nil {
+			report.Failed++
 		}
+		report.Items = append(report.Items, result)
 	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		return report.Items[i].Index < report.Items[j].Index
+	})
+	return report, ctx.Err()
 }
 
-func (bg *BatchGenerator) checkFinished() {
-	for range bg.finishedChan {
-		bg.finishedCount++
-		if bg.finishedCount >= bg.count {
-			close(bg.doneChan)
+func (bg *BatchGenerator) doGenerate(ctx context.Context) {
+	for index := range bg.indexChan {
+		// the queued resources are dropped once ctx is done
+		if ctx.Err() != nil {
+			continue
+		}
+		result := ItemResult{Index: index, Namespace: bg.namespaceList[index%len(bg.namespaceList)]}
+		start := time.Now()
+		ns, name, err := bg.generateFunc(result.Namespace, index)
+		result.CreateLatency = time.Since(start)
+		result.Namespace, result.Name = ns, name
+		if err == nil {
+			result.Created = true
+			err = bg.postGeneratorFunc(ns, name)
 		}
+		result.Err = err
+		bg.resultChan <- result
 	}
 }
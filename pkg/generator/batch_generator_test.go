@@ -15,6 +15,8 @@
 package generator_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync/atomic"
 	"testing"
@@ -28,9 +30,9 @@ func TestBatchGenerator(t *testing.T) {
 	var generateFuncCaled uint64
 	var postGeneratorFuncCalled uint64
 
-	generateFunc := func(ns string, index int) (string, string) {
+	generateFunc := func(ns string, index int) (string, string, error) {
 		atomic.AddUint64(&generateFuncCaled, 1)
-		return ns, fmt.Sprintf("%s-%d", ns, index)
+		return ns, fmt.Sprintf("%s-%d", ns, index), nil
 	}
 	postGeneratorFunc := func(ns, name string) error {
 		atomic.AddUint64(&postGeneratorFuncCalled, 1)
@@ -41,7 +43,7 @@ func TestBatchGenerator(t *testing.T) {
 		generateFuncCaled = 0
 		postGeneratorFuncCalled = 0
 		start := time.Now().Unix()
-		generator.NewBatchGenerator(time.Duration(1)*time.Second, 0, 2, 2, []string{"ns1", "ns2"}, generateFunc, postGeneratorFunc).Generate()
+		generator.NewBatchGenerator(time.Duration(1)*time.Second, 0, 2, 2, []string{"ns1", "ns2"}, generateFunc, postGeneratorFunc).Generate(context.Background())
 		duration := time.Now().Unix() - start
 		// should complete immediately
 		assert.Assert(t, duration < 1)
@@ -53,7 +55,7 @@ func TestBatchGenerator(t *testing.T) {
 		generateFuncCaled = 0
 		postGeneratorFuncCalled = 0
 		start := time.Now().Unix()
-		generator.NewBatchGenerator(time.Duration(1)*time.Second, 8, 2, 2, []string{"ns1", "ns2"}, generateFunc, postGeneratorFunc).Generate()
+		generator.NewBatchGenerator(time.Duration(1)*time.Second, 8, 2, 2, []string{"ns1", "ns2"}, generateFunc, postGeneratorFunc).Generate(context.Background())
 		duration := time.Now().Unix() - start
 		// should complete in count/batch = 4 seconds
 		assert.Assert(t, duration >= 4 && duration <= 5)
@@ -65,7 +67,7 @@ func TestBatchGenerator(t *testing.T) {
 		generateFuncCaled = 0
 		postGeneratorFuncCalled = 0
 		start := time.Now().Unix()
-		generator.NewBatchGenerator(time.Duration(1)*time.Second, 8, 4, 2, []string{"ns1", "ns2"}, generateFunc, postGeneratorFunc).Generate()
+		generator.NewBatchGenerator(time.Duration(1)*time.Second, 8, 4, 2, []string{"ns1", "ns2"}, generateFunc, postGeneratorFunc).Generate(context.Background())
 		duration := time.Now().Unix() - start
 		// should complete in count/batch = 2 seconds
 		assert.Assert(t, duration >= 2 && duration <= 3)
@@ -77,7 +79,7 @@ func TestBatchGenerator(t *testing.T) {
 		generateFuncCaled = 0
 		postGeneratorFuncCalled = 0
 		start := time.Now().Unix()
-		generator.NewBatchGenerator(time.Duration(1)*time.Second, 8, 8, 16, []string{"ns1", "ns2"}, generateFunc, postGeneratorFunc).Generate()
+		generator.NewBatchGenerator(time.Duration(1)*time.Second, 8, 8, 16, []string{"ns1", "ns2"}, generateFunc, postGeneratorFunc).Generate(context.Background())
 		duration := time.Now().Unix() - start
 		// should complete in count/batch = 1 second
 		assert.Assert(t, duration >= 1 && duration <= 2)
@@ -85,3 +87,58 @@ func TestBatchGenerator(t *testing.T) {
 		assert.Assert(t, postGeneratorFuncCalled == 8)
 	})
 }
+
+func TestBatchGeneratorReport(t *testing.T) {
+	generateFunc := func(ns string, index int) (string, string, error) {
+		name := fmt.Sprintf("%s-%d", ns, index)
+		if index == 1 {
+			return ns, name, errors.New("create error")
+		}
+		return ns, name, nil
+	}
+	postGeneratorFunc := func(ns, name string) error {
+		if name == "ns1-2" {
+			return errors.New("not ready")
+		}
+		return nil
+	}
+
+	report, err := generator.NewBatchGenerator(10*time.Millisecond, 4, 2, 2, []string{"ns1", "ns2"}, generateFunc, postGeneratorFunc).Generate(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, 4, report.Attempted)
+	assert.Equal(t, 3, report.Created)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, 4, len(report.Items))
+	for i, item := range report.Items {
+		assert.Equal(t, i, item.Index)
+	}
+	assert.Equal(t, "ns2-1", report.Items[1].Name)
+	assert.Assert(t, !report.Items[1].Created)
+	assert.ErrorContains(t, report.Items[1].Err, "create error")
+	assert.Assert(t, report.Items[2].Created)
+	assert.ErrorContains(t, report.Items[2].Err, "not ready")
+	assert.NilError(t, report.Items[3].Err)
+}
+
+func TestBatchGeneratorCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	generateFunc := func(ns string, index int) (string, string, error) {
+		if index == 3 {
+			cancel()
+		}
+		return ns, fmt.Sprintf("%s-%d", ns, index), nil
+	}
+	postGeneratorFunc := func(ns, name string) error {
+		return nil
+	}
+
+	start := time.Now()
+	report, err := generator.NewBatchGenerator(time.Second, 8, 2, 1, []string{"ns1", "ns2"}, generateFunc, postGeneratorFunc).Generate(ctx)
+	// should stop ahead of scheduled 4 (count/batch) seconds
+	assert.Assert(t, time.Since(start) < 3*time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 4, report.Attempted)
+	assert.Equal(t, 4, report.Created)
+	assert.Equal(t, 3, report.Items[3].Index)
+}
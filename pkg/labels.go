@@ -0,0 +1,45 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import "k8s.io/apimachinery/pkg/labels"
+
+const (
+	// ManagedByLabelKey and ManagedByLabelValue mark the resources created by kperf
+	ManagedByLabelKey   = "app.kubernetes.io/managed-by"
+	ManagedByLabelValue = "kperf"
+
+	// RunIDLabelKey is the label key of the ID of the kperf run which created a resource
+	RunIDLabelKey = "kperf.knative.dev/run-id"
+
+	// IndexAnnotationKey is the annotation key of the index of a generated Knative Service
+	IndexAnnotationKey = "kperf.knative.dev/index"
+
+	// GenerateArgsAnnotationKey is the annotation key of the parameters a Knative Service is generated with
+	GenerateArgsAnnotationKey = "kperf.knative.dev/generate-args"
+)
+
+// RunLabels returns the labels of the resources created by the kperf run runID
+func RunLabels(runID string) map[string]string {
+	return map[string]string{
+		ManagedByLabelKey: ManagedByLabelValue,
+		RunIDLabelKey:     runID,
+	}
+}
+
+// RunSelector returns the label selector of the resources created by the kperf run runID
+func RunSelector(runID string) string {
+	return labels.SelectorFromSet(labels.Set{RunIDLabelKey: runID}).String()
+}
@@ -0,0 +1,235 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"knative.dev/kperf/pkg"
+)
+
+const (
+	// KindConfigMap and KindService are the kinds of the objects changed, a Knative Service for KindService
+	KindConfigMap = "ConfigMap"
+	KindService   = "Service"
+)
+
+// ErrNotFound is returned when there is no journal for the run
+var ErrNotFound = errors.New("journal not found")
+
+// Change is a change of the values of a string map of an object, like the data of a ConfigMap or the annotations of
+// the template of a Knative Service
+type Change struct {
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Path      []string `json:"path"`
+	// Original are the values before the change by key, nil for a key which was not set
+	Original map[string]*string `json:"original"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s/%s %s", c.Kind, c.Namespace, c.Name, strings.Join(c.Path, "."))
+}
+
+func (c Change) sameMap(other Change) bool {
+	return c.Kind == other.Kind && c.Namespace == other.Namespace && c.Name == other.Name &&
+		strings.Join(c.Path, ".") == strings.Join(other.Path, ".")
+}
+
+// Patcher applies a JSON merge patch to an object of the cluster
+type Patcher func(ctx context.Context, kind, namespace, name string, patch []byte) error
+
+// NewPatcher returns the Patcher of the ConfigMaps and the Knative Services of the cluster
+func NewPatcher(params *pkg.PerfParams) Patcher {
+	return func(ctx context.Context, kind, namespace, name string, patch []byte) error {
+		switch kind {
+		case KindConfigMap:
+			_, err := params.ClientSet.CoreV1().ConfigMaps(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		case KindService:
+			ksvcClient, err := params.NewServingClient()
+			if err != nil {
+				return err
+			}
+			_, err = ksvcClient.Services(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		}
+		return fmt.Errorf("unsupported kind %s", kind)
+	}
+}
+
+// mergePatch returns the JSON merge patch setting the values at the path, a nil value removing the key
+func mergePatch(path []string, values map[string]*string) ([]byte, error) {
+	var patch interface{} = values
+	for i := len(path) - 1; i >= 0; i-- {
+		patch = map[string]interface{}{path[i]: patch}
+	}
+	return json.Marshal(patch)
+}
+
+// Journal records the changes a kperf run makes to the cluster in a file, before making them, so that they are
+// restored at the end of the run, or by 'kperf restore' if kperf could not restore them
+type Journal struct {
+	path string
+
+	lock    sync.Mutex
+	RunID   string    `json:"runID"`
+	Created time.Time `json:"created"`
+	Changes []Change  `json:"changes"`
+}
+
+// DefaultDir returns the directory of the journals, next to the kperf config file
+func DefaultDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".", "kperf-journal")
+	}
+	return filepath.Join(dir, "kperf", "journal")
+}
+
+func journalPath(dir, runID string) string {
+	return filepath.Join(dir, runID+".json")
+}
+
+// Open creates the journal of the run in dir. It fails if the run already has a journal, as its changes are not
+// restored and the values changed by kperf would be recorded as the original ones
+func Open(dir, runID string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory %s: %w", dir, err)
+	}
+	j := &Journal{path: journalPath(dir, runID), RunID: runID, Created: time.Now()}
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("the changes of run %s are not restored yet, restore them with 'kperf restore --run-id %s' first", runID, runID)
+		}
+		return nil, fmt.Errorf("failed to create journal: %w", err)
+	}
+	f.Close()
+	return j, j.save()
+}
+
+// Load reads the journal of the run from dir, ErrNotFound is returned if there is none
+func Load(dir, runID string) (*Journal, error) {
+	path := journalPath(dir, runID)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w for run %s in %s", ErrNotFound, runID, dir)
+		}
+		return nil, err
+	}
+	j := &Journal{path: path}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("failed to parse journal %s: %w", path, err)
+	}
+	return j, nil
+}
+
+// save writes the journal to a temporary file renamed over the journal, so that it is never left half written
+func (j *Journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// Apply records the change then patches the object with the values, a nil value removing the key. The original
+// values already recorded for the map are kept, being the ones before the first change of the run
+func (j *Journal) Apply(ctx context.Context, patcher Patcher, change Change, values map[string]*string) error {
+	patch, err := mergePatch(change.Path, values)
+	if err != nil {
+		return err
+	}
+	j.lock.Lock()
+	recorded := false
+	for i := range j.Changes {
+		if j.Changes[i].sameMap(change) {
+			for key, value := range change.Original {
+				if _, exists := j.Changes[i].Original[key]; !exists {
+					j.Changes[i].Original[key] = value
+				}
+			}
+			recorded = true
+		}
+	}
+	if !recorded {
+		j.Changes = append(j.Changes, change)
+	}
+	err = j.save()
+	j.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return patcher(ctx, change.Kind, change.Namespace, change.Name, patch)
+}
+
+// Restore restores the original values of the changes in reverse order and removes the journal. The changes which
+// cannot be restored are kept in the journal to be restored later
+func (j *Journal) Restore(ctx context.Context, patcher Patcher) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	failed := []Change{}
+	messages := []string{}
+	for i := len(j.Changes) - 1; i >= 0; i-- {
+		change := j.Changes[i]
+		patch, err := mergePatch(change.Path, change.Original)
+		if err == nil {
+			err = patcher(ctx, change.Kind, change.Namespace, change.Name, patch)
+		}
+		if apierrors.IsNotFound(err) {
+			fmt.Printf("skipped %s, not found\n", change)
+			continue
+		}
+		if err != nil {
+			failed = append([]Change{change}, failed...)
+			messages = append(messages, fmt.Sprintf("%s: %s", change, err))
+			continue
+		}
+		fmt.Printf("restored %s\n", change)
+	}
+	if len(failed) > 0 {
+		j.Changes = failed
+		if err := j.save(); err != nil {
+			messages = append(messages, err.Error())
+		}
+		return fmt.Errorf("failed to restore %d changes of run %s: %s", len(failed), j.RunID, strings.Join(messages, "; "))
+	}
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove journal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mutation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
+
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/testutil"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestJournal(t *testing.T) {
+	ctx := context.Background()
+	cfgm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config-autoscaler", Namespace: "knative-serving"},
+		Data: map[string]string{"allow-zero-initial-scale": "false"}}
+	svc := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1", Namespace: "ns-1"}}
+	svc.Spec.Template.Annotations = map[string]string{"autoscaling.knative.dev/window": "60s", "other": "kept"}
+	fakeServing := &servingv1fake.FakeServingV1{Fake: testutil.NewKnativeFake(svc)}
+	params := &pkg.PerfParams{
+		ClientSet: k8sfake.NewSimpleClientset(cfgm),
+		NewServingClient: func() (servingv1client.ServingV1Interface, error) {
+			return fakeServing, nil
+		},
+	}
+	patcher := NewPatcher(params)
+	dir := t.TempDir()
+
+	journal, err := Open(dir, "run-1")
+	assert.NilError(t, err)
+	err = journal.Apply(ctx, patcher, Change{Kind: KindConfigMap, Namespace: "knative-serving", Name: "config-autoscaler",
+		Path: []string{"data"}, Original: map[string]*string{"allow-zero-initial-scale": stringPtr("false")}},
+		map[string]*string{"allow-zero-initial-scale": stringPtr("true")})
+	assert.NilError(t, err)
+	annotations := Change{Kind: KindService, Namespace: "ns-1", Name: "ksvc-1", Path: []string{"spec", "template", "metadata", "annotations"},
+		Original: map[string]*string{"autoscaling.knative.dev/window": stringPtr("60s"), "autoscaling.knative.dev/initial-scale": nil}}
+	err = journal.Apply(ctx, patcher, annotations, map[string]*string{
+		"autoscaling.knative.dev/window": stringPtr("6s"), "autoscaling.knative.dev/initial-scale": stringPtr("0")})
+	assert.NilError(t, err)
+	// the values changed by kperf are not recorded as the original ones
+	annotations.Original = map[string]*string{"autoscaling.knative.dev/window": stringPtr("6s")}
+	err = journal.Apply(ctx, patcher, annotations, map[string]*string{"autoscaling.knative.dev/window": stringPtr("7s")})
+	assert.NilError(t, err)
+
+	got, err := fakeServing.Services("ns-1").Get(ctx, "ksvc-1", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{"autoscaling.knative.dev/window": "7s", "autoscaling.knative.dev/initial-scale": "0", "other": "kept"},
+		got.Spec.Template.Annotations)
+
+	// the journal is written before the changes are made
+	loaded, err := Load(dir, "run-1")
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(loaded.Changes))
+	assert.Equal(t, "60s", *loaded.Changes[1].Original["autoscaling.knative.dev/window"])
+	assert.Assert(t, loaded.Changes[1].Original["autoscaling.knative.dev/initial-scale"] == nil)
+
+	_, err = Open(dir, "run-1")
+	assert.ErrorContains(t, err, "the changes of run run-1 are not restored yet, restore them with 'kperf restore --run-id run-1' first")
+
+	// a journal loaded after a crash restores the changes
+	assert.NilError(t, loaded.Restore(ctx, patcher))
+	got, err = fakeServing.Services("ns-1").Get(ctx, "ksvc-1", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{"autoscaling.knative.dev/window": "60s", "other": "kept"}, got.Spec.Template.Annotations)
+	gotCfgm, err := params.ClientSet.CoreV1().ConfigMaps("knative-serving").Get(ctx, "config-autoscaler", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, "false", gotCfgm.Data["allow-zero-initial-scale"])
+
+	_, err = Load(dir, "run-1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestJournalRestoreFailure(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	journal, err := Open(dir, "run-1")
+	assert.NilError(t, err)
+	noop := func(context.Context, string, string, string, []byte) error {
+		return nil
+	}
+	for _, name := range []string{"ksvc-1", "ksvc-2"} {
+		err = journal.Apply(ctx, noop, Change{Kind: KindService, Namespace: "ns-1", Name: name, Path: []string{"metadata", "annotations"},
+			Original: map[string]*string{"a": nil}}, map[string]*string{"a": stringPtr("b")})
+		assert.NilError(t, err)
+	}
+
+	failing := func(_ context.Context, _, _, name string, _ []byte) error {
+		if name == "ksvc-2" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	err = journal.Restore(ctx, failing)
+	assert.ErrorContains(t, err, "failed to restore 1 changes of run run-1: Service ns-1/ksvc-2 metadata.annotations: connection refused")
+
+	// only the changes not restored are kept
+	loaded, err := Load(dir, "run-1")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(loaded.Changes))
+	assert.Equal(t, "ksvc-2", loaded.Changes[0].Name)
+}
@@ -24,6 +24,7 @@ import (
 	networkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1"
 	autoscalingv1alpha1 "knative.dev/serving/pkg/client/clientset/versioned/typed/autoscaling/v1alpha1"
 	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+	servingv1beta1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1beta1"
 )
 
 func (params *PerfParams) Initialize() error {
@@ -48,6 +49,9 @@ func (params *PerfParams) Initialize() error {
 	if params.NewNetworkingClient == nil {
 		params.NewNetworkingClient = params.newNetworkingClient
 	}
+	if params.NewDomainMappingClient == nil {
+		params.NewDomainMappingClient = params.newDomainMappingClient
+	}
 	return nil
 }
 
@@ -69,6 +73,10 @@ func (params *PerfParams) newServingClient() (servingv1client.ServingV1Interface
 		return nil, err
 	}
 
+	if params.WrapServingTransport != nil {
+		restConfig.Wrap(params.WrapServingTransport)
+	}
+
 	client, err := servingv1client.NewForConfig(restConfig)
 	if err != nil {
 		return nil, err
@@ -89,6 +97,19 @@ func (params *PerfParams) newNetworkingClient() (networkingv1alpha1.NetworkingV1
 	return client, nil
 }
 
+func (params *PerfParams) newDomainMappingClient() (servingv1beta1client.ServingV1beta1Interface, error) {
+	restConfig, err := params.RestConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := servingv1beta1client.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
 // RestConfig returns REST config, which can be to use to create specific clientset
 func (params *PerfParams) RestConfig() (*rest.Config, error) {
 	var err error
@@ -0,0 +1,273 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/ptr"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1beta1 "knative.dev/serving/pkg/apis/serving/v1beta1"
+)
+
+const (
+	// Default is the profile of the Knative Services generated without profile
+	Default = "default"
+
+	// HelloworldImage is the image of the default profile
+	HelloworldImage = "gcr.io/knative-samples/helloworld-go"
+	// LargeImage is an image of about 1GB pulled on cold start by the large-image profile
+	LargeImage = "docker.io/library/python:3.11"
+	// AutoscaleImage is an image burning CPU on request used by the cpu-heavy profile
+	AutoscaleImage = "gcr.io/knative-samples/autoscale-go:0.1"
+	// SidecarImage is the image of the sidecar of the sidecar profile
+	SidecarImage = "docker.io/library/busybox:1.36"
+
+	// DataName is the base name of the ConfigMap and Secret mounted by the volumes profile, see RunObjectName
+	DataName = "kperf-profile-data"
+
+	containerPort = 8080
+)
+
+// Options are the parameters common to all the profiles
+type Options struct {
+	MinScale int
+	MaxScale int
+	// RunID is the ID of the kperf run, naming the ConfigMaps and Secrets the Knative Services mount
+	RunID string
+}
+
+// Profile is a kind of workload to generate Knative Services of
+type Profile struct {
+	Name        string
+	Description string
+	// Build returns the spec of a Knative Service of the profile, the name and namespace are set by the caller
+	Build func(opts Options) *servingv1.Service
+	// ConfigMaps and Secrets are mounted by the Knative Services of the profile, they have to be created in each
+	// namespace before the Knative Services and named by RunObjectName, so that each run has its own
+	ConfigMaps []corev1.ConfigMap
+	Secrets    []corev1.Secret
+	// DomainMapping is whether each Knative Service of the profile is mapped to a domain of its own by a
+	// DomainMapping, see NewDomainMapping
+	DomainMapping bool
+}
+
+var profiles = map[string]Profile{
+	Default: {
+		Name:        Default,
+		Description: "the helloworld-go sample",
+		Build: func(opts Options) *servingv1.Service {
+			return newService(opts, helloworldContainer())
+		},
+	},
+	"large-image": {
+		Name:        "large-image",
+		Description: "an HTTP server in an image of about 1GB, to measure the image pull on cold start",
+		Build: func(opts Options) *servingv1.Service {
+			return newService(opts, corev1.Container{
+				Image:   LargeImage,
+				Command: []string{"python", "-m", "http.server", strconv.Itoa(containerPort)},
+				Ports:   []corev1.ContainerPort{{ContainerPort: containerPort}},
+			})
+		},
+	},
+	"slow-start": {
+		Name:        "slow-start",
+		Description: "the helloworld-go sample with a readiness probe delayed by 10 seconds",
+		Build: func(opts Options) *servingv1.Service {
+			container := helloworldContainer()
+			container.ReadinessProbe = &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					HTTPGet: &corev1.HTTPGetAction{Path: "/"},
+				},
+				InitialDelaySeconds: 10,
+				PeriodSeconds:       1,
+			}
+			return newService(opts, container)
+		},
+	},
+	"cpu-heavy": {
+		Name:        "cpu-heavy",
+		Description: "the autoscale-go sample computing primes with 1 CPU requested and 2 CPUs at most",
+		Build: func(opts Options) *servingv1.Service {
+			return newService(opts, corev1.Container{
+				Image: AutoscaleImage,
+				Ports: []corev1.ContainerPort{{ContainerPort: containerPort}},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("1"),
+						corev1.ResourceMemory: resource.MustParse("128Mi"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("2"),
+						corev1.ResourceMemory: resource.MustParse("256Mi"),
+					},
+				},
+			})
+		},
+	},
+	"sidecar": {
+		Name:        "sidecar",
+		Description: "the helloworld-go sample with a busybox sidecar, requires the multi-container feature of Knative Serving",
+		Build: func(opts Options) *servingv1.Service {
+			return newService(opts, helloworldContainer(), corev1.Container{
+				Name:    "sidecar",
+				Image:   SidecarImage,
+				Command: []string{"sh", "-c", "while true; do sleep 3600; done"},
+			})
+		},
+	},
+	"high-concurrency": {
+		Name:        "high-concurrency",
+		Description: "the helloworld-go sample accepting 1000 concurrent requests per pod",
+		Build: func(opts Options) *servingv1.Service {
+			svc := newService(opts, helloworldContainer())
+			svc.Spec.Template.Spec.ContainerConcurrency = ptr.Int64(1000)
+			return svc
+		},
+	},
+	"volumes": {
+		Name:        "volumes",
+		Description: "the helloworld-go sample mounting a ConfigMap and a Secret named " + DataName + "-<run ID>",
+		Build: func(opts Options) *servingv1.Service {
+			name := RunObjectName(DataName, opts.RunID)
+			container := helloworldContainer()
+			container.VolumeMounts = []corev1.VolumeMount{
+				{Name: "config", MountPath: "/etc/kperf/config", ReadOnly: true},
+				{Name: "secret", MountPath: "/etc/kperf/secret", ReadOnly: true},
+			}
+			svc := newService(opts, container)
+			svc.Spec.Template.Spec.Volumes = []corev1.Volume{
+				{
+					Name: "config",
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+					},
+				},
+				{
+					Name: "secret",
+					VolumeSource: corev1.VolumeSource{
+						Secret: &corev1.SecretVolumeSource{SecretName: name},
+					},
+				},
+			}
+			return svc
+		},
+		ConfigMaps: []corev1.ConfigMap{{
+			ObjectMeta: metav1.ObjectMeta{Name: DataName},
+			Data:       map[string]string{"config.yaml": "message: hello from kperf\n"},
+		}},
+		Secrets: []corev1.Secret{{
+			ObjectMeta: metav1.ObjectMeta{Name: DataName},
+			StringData: map[string]string{"token": "kperf"},
+		}},
+	},
+	"domain-mapping": {
+		Name:          "domain-mapping",
+		Description:   "the helloworld-go sample mapped to <name>.<namespace>.<domain> by a DomainMapping, with a certificate if auto-TLS is enabled",
+		DomainMapping: true,
+		Build: func(opts Options) *servingv1.Service {
+			return newService(opts, helloworldContainer())
+		},
+	},
+}
+
+// Get returns the profile with the name
+func Get(name string) (Profile, error) {
+	p, exists := profiles[name]
+	if !exists {
+		return Profile{}, fmt.Errorf("unknown profile %s, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+	return p, nil
+}
+
+// RunObjectName returns the name of an object of a profile for the kperf run runID, the objects of the runs sharing a
+// namespace being deleted with the last Knative Service of their run
+func RunObjectName(name, runID string) string {
+	if runID == "" {
+		return name
+	}
+	return name + "-" + runID
+}
+
+// Names returns the names of the profiles sorted alphabetically
+func Names() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func helloworldContainer() corev1.Container {
+	return corev1.Container{
+		Image: HelloworldImage,
+		Ports: []corev1.ContainerPort{{ContainerPort: containerPort}},
+	}
+}
+
+// newService returns a Knative Service running the containers with the autoscaling bounds of the options
+func newService(opts Options, containers ...corev1.Container) *servingv1.Service {
+	svc := &servingv1.Service{}
+	svc.Spec.Template = servingv1.RevisionTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"autoscaling.knative.dev/minScale": strconv.Itoa(opts.MinScale),
+				"autoscaling.knative.dev/maxScale": strconv.Itoa(opts.MaxScale),
+			},
+		},
+	}
+	svc.Spec.Template.Spec.Containers = containers
+	return svc
+}
+
+// NewDomainMapping returns the DomainMapping of the Knative Service to the domain <name>.<namespace>.<domain>. The
+// DomainMapping is owned by the Knative Service if it has a UID, to be deleted along with it
+func NewDomainMapping(svc *servingv1.Service, domain string) *servingv1beta1.DomainMapping {
+	// the labels are copied so that the DomainMapping does not share the map of the Knative Service
+	labels := make(map[string]string, len(svc.Labels))
+	for k, v := range svc.Labels {
+		labels[k] = v
+	}
+	dm := &servingv1beta1.DomainMapping{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%s.%s", svc.Name, svc.Namespace, domain),
+			Namespace: svc.Namespace,
+			Labels:    labels,
+		},
+		Spec: servingv1beta1.DomainMappingSpec{
+			Ref: duckv1.KReference{
+				APIVersion: servingv1.SchemeGroupVersion.String(),
+				Kind:       "Service",
+				Name:       svc.Name,
+				Namespace:  svc.Namespace,
+			},
+		},
+	}
+	if svc.UID != "" {
+		dm.OwnerReferences = []metav1.OwnerReference{
+			*metav1.NewControllerRef(svc, servingv1.SchemeGroupVersion.WithKind("Service")),
+		}
+	}
+	return dm
+}
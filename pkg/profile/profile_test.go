@@ -0,0 +1,85 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func TestProfiles(t *testing.T) {
+	assert.DeepEqual(t, []string{"cpu-heavy", "default", "domain-mapping", "high-concurrency", "large-image", "sidecar", "slow-start", "volumes"}, Names())
+
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			p, err := Get(name)
+			assert.NilError(t, err)
+			assert.Equal(t, name, p.Name)
+			svc := p.Build(Options{MinScale: 1, MaxScale: 3, RunID: "run-1"})
+			assert.Equal(t, "1", svc.Spec.Template.Annotations["autoscaling.knative.dev/minScale"])
+			assert.Equal(t, "3", svc.Spec.Template.Annotations["autoscaling.knative.dev/maxScale"])
+
+			// Knative Serving requires exactly one container to serve the requests
+			serving := 0
+			for _, container := range svc.Spec.Template.Spec.Containers {
+				assert.Assert(t, container.Image != "")
+				if len(container.Ports) > 0 {
+					serving++
+				}
+			}
+			assert.Equal(t, 1, serving)
+
+			// the profile provides the ConfigMaps and Secrets its Knative Services mount
+			for _, volume := range svc.Spec.Template.Spec.Volumes {
+				if volume.ConfigMap != nil {
+					assert.Equal(t, volume.ConfigMap.Name, RunObjectName(p.ConfigMaps[0].Name, "run-1"))
+				}
+				if volume.Secret != nil {
+					assert.Equal(t, volume.Secret.SecretName, RunObjectName(p.Secrets[0].Name, "run-1"))
+				}
+			}
+
+			// each Knative Service gets its own spec
+			assert.Assert(t, svc != p.Build(Options{}))
+		})
+	}
+
+	_, err := Get("unknown")
+	assert.ErrorContains(t, err, "unknown profile unknown, expected one of cpu-heavy, default")
+}
+
+func TestNewDomainMapping(t *testing.T) {
+	svc := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "ksvc-1", Namespace: "ns-1", Labels: map[string]string{"run": "1"}}}
+	dm := NewDomainMapping(svc, "example.com")
+	assert.Equal(t, "ksvc-1.ns-1.example.com", dm.Name)
+	assert.Equal(t, "ns-1", dm.Namespace)
+	assert.Equal(t, "1", dm.Labels["run"])
+	// the labels of the Knative Service are not changed along with the ones of the DomainMapping
+	dm.Labels["run"] = "2"
+	assert.Equal(t, "1", svc.Labels["run"])
+	assert.Equal(t, "Service", dm.Spec.Ref.Kind)
+	assert.Equal(t, "ksvc-1", dm.Spec.Ref.Name)
+	// the Knative Service is not persisted yet
+	assert.Equal(t, 0, len(dm.OwnerReferences))
+
+	svc.UID = types.UID("uid-1")
+	dm = NewDomainMapping(svc, "example.com")
+	assert.Equal(t, types.UID("uid-1"), dm.OwnerReferences[0].UID)
+	assert.Equal(t, "Service", dm.OwnerReferences[0].Kind)
+}
@@ -0,0 +1,355 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package target
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+
+	"knative.dev/kperf/pkg"
+)
+
+// Namespaces selects the namespaces targeted by a command, either by name or by the prefix with the range of their
+// index, the label selector and the regular expression of their name, which must all match
+type Namespaces struct {
+	// Name is the name of the single namespace targeted, the other criteria being ignored if set
+	Name   string
+	Prefix string
+	// Range is the range of the index of the namespaces named <prefix>-<index>, all the namespaces named
+	// <prefix>-<index> being targeted if empty, see ParseRange
+	Range    string
+	Selector string
+	Regex    string
+}
+
+// Services selects the Knative Services targeted by a command in the namespaces, by name or by the prefix with the
+// range of their index, the label selector, the regular expression of their name and the run which created them,
+// which must all match
+type Services struct {
+	Name   string
+	Prefix string
+	// Range is the range of the index of the Knative Services named <prefix>-<index>, all the Knative Services with
+	// the prefix being targeted if empty, see ParseRange
+	Range    string
+	Selector string
+	Regex    string
+	RunID    string
+}
+
+// ParseRange returns the indexes of a range, either its start and end like 1,500 or a list of indexes and ranges like
+// 1-5,8,20-30, in ascending order without duplicates. Two indexes separated by a comma are always the start and end
+// of a range, like before range lists were supported
+func ParseRange(s string) ([]int, error) {
+	syntaxErr := fmt.Errorf("expected range like 1,500, given %s, or a range list like 1-5,8,20-30", s)
+	parts := strings.Split(s, ",")
+	if len(parts) == 2 && !strings.Contains(s, "-") {
+		start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, err
+		}
+		end, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+		return indexRange(start, end)
+	}
+
+	seen := map[int]bool{}
+	indexes := []int{}
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, syntaxErr
+		}
+		var partIndexes []int
+		if startStr, endStr, ok := strings.Cut(part, "-"); ok {
+			if startStr == "" || endStr == "" {
+				return nil, syntaxErr
+			}
+			start, err := strconv.Atoi(startStr)
+			if err != nil {
+				return nil, err
+			}
+			end, err := strconv.Atoi(endStr)
+			if err != nil {
+				return nil, err
+			}
+			partIndexes, err = indexRange(start, end)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			index, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, err
+			}
+			partIndexes = []int{index}
+		}
+		for _, index := range partIndexes {
+			if !seen[index] {
+				seen[index] = true
+				indexes = append(indexes, index)
+			}
+		}
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// indexRange returns the indexes from start to end included
+func indexRange(start, end int) ([]int, error) {
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("expected 0 <= start <= end, given start %d and end %d", start, end)
+	}
+	indexes := make([]int, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// nameMatcher matches the names by prefix with the range of their index and by regular expression
+type nameMatcher struct {
+	prefix string
+	// names are the names of the range, nil to match the names by prefix only
+	names map[string]bool
+	// indexed requires the names matched by prefix only to be <prefix>-<index>, so that a prefix never matches
+	// the names merely starting with it
+	indexed bool
+	regex   *regexp.Regexp
+}
+
+// newNameMatcher returns the matcher of the names, the range being parsed only if the prefix is set
+func newNameMatcher(kind, prefix, indexRange, regex string) (*nameMatcher, error) {
+	m := &nameMatcher{prefix: prefix}
+	if prefix != "" && indexRange != "" {
+		indexes, err := ParseRange(indexRange)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s range %s: %w", kind, indexRange, err)
+		}
+		m.names = make(map[string]bool, len(indexes))
+		for _, index := range indexes {
+			m.names[fmt.Sprintf("%s-%d", prefix, index)] = true
+		}
+	}
+	if regex != "" {
+		var err error
+		m.regex, err = regexp.Compile(regex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s regex %s: %w", kind, regex, err)
+		}
+	}
+	return m, nil
+}
+
+func (m *nameMatcher) matches(name string) bool {
+	if m.names != nil && !m.names[name] {
+		return false
+	}
+	if m.prefix != "" && !strings.HasPrefix(name, m.prefix) {
+		return false
+	}
+	if m.indexed && m.names == nil && m.prefix != "" && !isIndex(strings.TrimPrefix(name, m.prefix+"-")) {
+		return false
+	}
+	return m.regex == nil || m.regex.MatchString(name)
+}
+
+// isIndex returns whether s is the index of a name, a non empty string of digits
+func isIndex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve returns the names of the namespaces, the namespace named Name being required to exist. Without range, the
+// prefix only targets the namespaces named <prefix>-<index>
+func (n Namespaces) Resolve(ctx context.Context, client kubernetes.Interface) ([]string, error) {
+	matcher, err := newNameMatcher("namespace", n.Prefix, n.Range, n.Regex)
+	if err != nil {
+		return nil, err
+	}
+	matcher.indexed = true
+	if _, err := labels.Parse(n.Selector); err != nil {
+		return nil, fmt.Errorf("failed to parse namespace selector %s: %w", n.Selector, err)
+	}
+
+	if n.Name != "" {
+		ns, err := client.CoreV1().Namespaces().Get(ctx, n.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return []string{ns.Name}, nil
+	}
+	if n.Prefix == "" && n.Selector == "" && n.Regex == "" {
+		return nil, errors.New("both namespace and namespace-prefix are empty, and neither namespace-selector nor namespace-regex is set")
+	}
+	nsList, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: n.Selector})
+	if err != nil {
+		return nil, err
+	}
+	nsNameList := []string{}
+	for _, ns := range nsList.Items {
+		if matcher.matches(ns.Name) {
+			nsNameList = append(nsNameList, ns.Name)
+		}
+	}
+	if len(nsNameList) == 0 {
+		return nil, fmt.Errorf("no namespace found %s", n)
+	}
+	return nsNameList, nil
+}
+
+// String describes the criteria of the namespaces
+func (n Namespaces) String() string {
+	return describe([][2]string{{"name", n.Name}, {"prefix", n.Prefix}, {"range", n.Range},
+		{"selector", n.Selector}, {"regex", n.Regex}})
+}
+
+// All returns whether the Knative Services are not restricted by any criteria, all the Knative Services of the
+// namespaces being targeted
+func (s Services) All() bool {
+	return s.Name == "" && s.Prefix == "" && s.Selector == "" && s.Regex == "" && s.RunID == ""
+}
+
+// labelSelector returns the label selector of the Knative Services, from Selector and the run ID
+func (s Services) labelSelector() (string, error) {
+	selectors := []string{}
+	if s.Selector != "" {
+		if _, err := labels.Parse(s.Selector); err != nil {
+			return "", fmt.Errorf("failed to parse selector %s: %w", s.Selector, err)
+		}
+		selectors = append(selectors, s.Selector)
+	}
+	if s.RunID != "" {
+		selectors = append(selectors, pkg.RunSelector(s.RunID))
+	}
+	return strings.Join(selectors, ","), nil
+}
+
+// Validate returns an error if the range, the label selector or the regular expression cannot be parsed, the range
+// being checked even without prefix
+func (s Services) Validate() error {
+	if s.Range != "" {
+		if _, err := ParseRange(s.Range); err != nil {
+			return fmt.Errorf("failed to parse svc range %s: %w", s.Range, err)
+		}
+	}
+	if _, err := newNameMatcher("svc", s.Prefix, s.Range, s.Regex); err != nil {
+		return err
+	}
+	_, err := s.labelSelector()
+	return err
+}
+
+// Names returns the namespace and name of the Knative Services named by the prefix and the range in each namespace,
+// whether they exist or not, so that the missing ones can be reported. It returns nil if the Knative Services are
+// not only selected by prefix and range
+func (s Services) Names(namespaces []string) ([][2]string, error) {
+	if s.Prefix == "" || s.Range == "" || s.Name != "" || s.Selector != "" || s.Regex != "" || s.RunID != "" {
+		return nil, nil
+	}
+	indexes, err := ParseRange(s.Range)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse svc range %s: %w", s.Range, err)
+	}
+	names := make([][2]string, 0, len(namespaces)*len(indexes))
+	for _, ns := range namespaces {
+		for _, index := range indexes {
+			names = append(names, [2]string{ns, fmt.Sprintf("%s-%d", s.Prefix, index)})
+		}
+	}
+	return names, nil
+}
+
+// Resolve returns the Knative Services matching the criteria in the namespaces, the Knative Service named Name being
+// required to exist in every namespace. The namespaces whose Knative Services cannot be listed are skipped, and no
+// error is returned if there is no Knative Service
+func (s Services) Resolve(ctx context.Context, client servingv1client.ServingV1Interface, namespaces []string) ([]*servingv1.Service, error) {
+	matcher, err := newNameMatcher("svc", s.Prefix, s.Range, s.Regex)
+	if err != nil {
+		return nil, err
+	}
+	labelSelector, err := s.labelSelector()
+	if err != nil {
+		return nil, err
+	}
+	selector, err := labels.Parse(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	services := []*servingv1.Service{}
+	for _, ns := range namespaces {
+		if s.Name != "" {
+			svc, err := client.Services(ns).Get(ctx, s.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			if selector.Matches(labels.Set(svc.Labels)) && matcher.matches(svc.Name) {
+				services = append(services, svc)
+			}
+			continue
+		}
+		svcList, err := client.Services(ns).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+		if err != nil {
+			fmt.Printf("failed to list services in namespace %s: %s\n", ns, err)
+			continue
+		}
+		for i := range svcList.Items {
+			if matcher.matches(svcList.Items[i].Name) {
+				services = append(services, &svcList.Items[i])
+			}
+		}
+	}
+	return services, nil
+}
+
+// String describes the criteria of the Knative Services, the run ID first
+func (s Services) String() string {
+	return describe([][2]string{{"run ID", s.RunID}, {"name", s.Name}, {"prefix", s.Prefix}, {"range", s.Range},
+		{"selector", s.Selector}, {"regex", s.Regex}})
+}
+
+// describe returns the criteria set as "with <name> <value>, <name> <value>"
+func describe(criteria [][2]string) string {
+	set := []string{}
+	for _, c := range criteria {
+		if c[1] != "" {
+			set = append(set, c[0]+" "+c[1])
+		}
+	}
+	if len(set) == 0 {
+		return "without criteria"
+	}
+	return "with " + strings.Join(set, ", ")
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 The Knative Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package target
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
+
+	"knative.dev/kperf/pkg"
+	"knative.dev/kperf/pkg/testutil"
+)
+
+func TestParseRange(t *testing.T) {
+	for _, tc := range []struct {
+		given    string
+		expected []int
+		err      string
+	}{
+		{given: "1,3", expected: []int{1, 2, 3}},
+		{given: "2,2", expected: []int{2}},
+		{given: "1-3,8,20-21", expected: []int{1, 2, 3, 8, 20, 21}},
+		{given: "5,1-2", expected: []int{1, 2, 5}},
+		{given: "1-4,3-5,4", expected: []int{1, 2, 3, 4, 5}},
+		{given: "7", expected: []int{7}},
+		{given: "3,1", err: "expected 0 <= start <= end, given start 3 and end 1"},
+		{given: "3-1", err: "expected 0 <= start <= end, given start 3 and end 1"},
+		{given: "1-", err: "expected range like 1,500, given 1-, or a range list like 1-5,8,20-30"},
+		{given: "1,,2", err: "expected range like 1,500, given 1,,2, or a range list like 1-5,8,20-30"},
+		{given: "a,2", err: "strconv.Atoi: parsing \"a\": invalid syntax"},
+	} {
+		t.Run(tc.given, func(t *testing.T) {
+			indexes, err := ParseRange(tc.given)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.expected, indexes)
+		})
+	}
+}
+
+func TestResolveNamespaces(t *testing.T) {
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	client := k8sfake.NewSimpleClientset(
+		namespace("test-1", map[string]string{"team": "a"}),
+		namespace("test-2", map[string]string{"team": "b"}),
+		namespace("test-3", map[string]string{"team": "a"}),
+		namespace("other-1", map[string]string{"team": "a"}),
+		// the namespaces starting with the prefix but not named <prefix>-<index>
+		namespace("testing", map[string]string{"team": "d"}),
+		namespace("test-prod", map[string]string{"team": "d"}),
+	)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name     string
+		given    Namespaces
+		expected []string
+		err      string
+	}{
+		{name: "name", given: Namespaces{Name: "test-2", Prefix: "other"}, expected: []string{"test-2"}},
+		{name: "prefix", given: Namespaces{Prefix: "test"}, expected: []string{"test-1", "test-2", "test-3"}},
+		{name: "prefix and range list", given: Namespaces{Prefix: "test", Range: "1,3-5"}, expected: []string{"test-1", "test-3"}},
+		{name: "selector", given: Namespaces{Selector: "team=a"}, expected: []string{"other-1", "test-1", "test-3"}},
+		{name: "selector and regex", given: Namespaces{Selector: "team=a", Regex: "^test-"}, expected: []string{"test-1", "test-3"}},
+		{name: "no criteria", given: Namespaces{}, err: "both namespace and namespace-prefix are empty"},
+		{name: "none found", given: Namespaces{Prefix: "test", Selector: "team=c"}, err: "no namespace found with prefix test, selector team=c"},
+		{name: "invalid regex", given: Namespaces{Regex: "("}, err: "failed to parse namespace regex ("},
+		{name: "invalid selector", given: Namespaces{Selector: "team in (a"}, err: "failed to parse namespace selector team in (a"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nsNameList, err := tc.given.Resolve(ctx, client)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.expected, nsNameList)
+		})
+	}
+}
+
+func TestResolveServices(t *testing.T) {
+	service := func(ns, name, runID string, labels map[string]string) *servingv1.Service {
+		svc := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: labels}}
+		if runID != "" {
+			svc.Labels = pkg.RunLabels(runID)
+			for k, v := range labels {
+				svc.Labels[k] = v
+			}
+		}
+		return svc
+	}
+	fakeServing := &servingv1fake.FakeServingV1{Fake: testutil.NewKnativeFake(
+		service("ns-1", "ksvc-1", "run-1", map[string]string{"tier": "web"}),
+		service("ns-1", "ksvc-2", "run-2", nil),
+		service("ns-1", "ksvc-10", "run-1", nil),
+		service("ns-1", "other-1", "run-1", map[string]string{"tier": "web"}),
+		service("ns-2", "ksvc-1", "", nil),
+	)}
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name       string
+		given      Services
+		namespaces []string
+		expected   []string
+		err        string
+	}{
+		{name: "prefix", given: Services{Prefix: "ksvc"}, namespaces: []string{"ns-1"},
+			expected: []string{"ns-1/ksvc-1", "ns-1/ksvc-10", "ns-1/ksvc-2"}},
+		{name: "prefix and range", given: Services{Prefix: "ksvc", Range: "1,2"}, namespaces: []string{"ns-1", "ns-2"},
+			expected: []string{"ns-1/ksvc-1", "ns-1/ksvc-2", "ns-2/ksvc-1"}},
+		{name: "run ID", given: Services{RunID: "run-1"}, namespaces: []string{"ns-1", "ns-2"},
+			expected: []string{"ns-1/ksvc-1", "ns-1/ksvc-10", "ns-1/other-1"}},
+		{name: "run ID and prefix", given: Services{Prefix: "ksvc", RunID: "run-1"}, namespaces: []string{"ns-1"},
+			expected: []string{"ns-1/ksvc-1", "ns-1/ksvc-10"}},
+		{name: "selector", given: Services{Selector: "tier=web"}, namespaces: []string{"ns-1"},
+			expected: []string{"ns-1/ksvc-1", "ns-1/other-1"}},
+		{name: "selector and regex", given: Services{Selector: "tier=web", Regex: "^other"}, namespaces: []string{"ns-1"},
+			expected: []string{"ns-1/other-1"}},
+		{name: "name", given: Services{Name: "ksvc-1"}, namespaces: []string{"ns-1", "ns-2"},
+			expected: []string{"ns-1/ksvc-1", "ns-2/ksvc-1"}},
+		{name: "name of another run", given: Services{Name: "ksvc-2", RunID: "run-1"}, namespaces: []string{"ns-1"},
+			expected: []string{}},
+		{name: "name not found", given: Services{Name: "ksvc-2"}, namespaces: []string{"ns-2"}, err: "not found"},
+		{name: "invalid regex", given: Services{Regex: "("}, namespaces: []string{"ns-1"}, err: "failed to parse svc regex ("},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			services, err := tc.given.Resolve(ctx, fakeServing, tc.namespaces)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.NilError(t, err)
+			names := []string{}
+			for _, svc := range services {
+				names = append(names, svc.Namespace+"/"+svc.Name)
+			}
+			assert.DeepEqual(t, tc.expected, names)
+		})
+	}
+}
+
+func TestServices(t *testing.T) {
+	assert.Assert(t, Services{}.All())
+	assert.Assert(t, !Services{RunID: "run-1"}.All())
+	assert.Equal(t, "with run ID run-1, prefix ksvc, range 1-5,8", Services{Prefix: "ksvc", Range: "1-5,8", RunID: "run-1"}.String())
+
+	// the range is validated even without prefix
+	assert.ErrorContains(t, Services{Range: "1200-"}.Validate(), "failed to parse svc range 1200-")
+	assert.ErrorContains(t, Services{Selector: "tier in (web"}.Validate(), "failed to parse selector tier in (web")
+	assert.NilError(t, Services{Prefix: "ksvc", Range: "1-2,4", Selector: "tier=web"}.Validate())
+
+	names, err := Services{Prefix: "ksvc", Range: "1-2,4"}.Names([]string{"ns-1", "ns-2"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, [][2]string{{"ns-1", "ksvc-1"}, {"ns-1", "ksvc-2"}, {"ns-1", "ksvc-4"},
+		{"ns-2", "ksvc-1"}, {"ns-2", "ksvc-2"}, {"ns-2", "ksvc-4"}}, names)
+
+	// the Knative Services selected by other criteria must be listed
+	names, err = Services{Prefix: "ksvc", Range: "1,2", RunID: "run-1"}.Names([]string{"ns-1"})
+	assert.NilError(t, err)
+	assert.Assert(t, names == nil)
+}
@@ -16,12 +16,13 @@ package pkg
 import (
 	"time"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/transport"
 	networkingv1alpha1 "knative.dev/networking/pkg/client/clientset/versioned/typed/networking/v1alpha1"
 	autoscalingv1alpha1 "knative.dev/serving/pkg/client/clientset/versioned/typed/autoscaling/v1alpha1"
 	servingv1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
+	servingv1beta1client "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1beta1"
 )
 
 type PerfParams struct {
@@ -31,6 +32,10 @@ type PerfParams struct {
 	NewAutoscalingClient func() (autoscalingv1alpha1.AutoscalingV1alpha1Interface, error)
 	NewServingClient     func() (servingv1client.ServingV1Interface, error)
 	NewNetworkingClient  func() (networkingv1alpha1.NetworkingV1alpha1Interface, error)
+	// NewDomainMappingClient returns the client of the serving.knative.dev/v1beta1 API of the DomainMappings
+	NewDomainMappingClient func() (servingv1beta1client.ServingV1beta1Interface, error)
+	// WrapServingTransport wraps the transport of the serving client created by default, e.g. to instrument the requests
+	WrapServingTransport transport.WrapperFunc
 }
 
 type GenerateArgs struct {
@@ -46,49 +51,109 @@ type GenerateArgs struct {
 	Namespace       string
 	SvcPrefix       string
 
-	CheckReady bool
-	Timeout    time.Duration
+	CheckReady      bool
+	Timeout         time.Duration
+	ContinueOnError bool
+
+	CreateNamespaces bool
 
 	Template string
+	Profile  string
+	Domain   string
+	RunID    string
+
+	Arrival       string
+	RampIncrement int
+	Steps         string
+	Rate          float64
+
+	Revisions int
+	Traffic   string
+	Tags      string
+
+	MeasureAdmission bool
+	Timeline         bool
+	DryRun           string
+	ManifestOutput   string
+
+	// Https is whether to request the HTTPS URLs of the Knative Services and of their DomainMappings once ready
+	// until the first successful response
+	Https              bool
+	ResolvableDomain   bool
+	InsecureSkipVerify bool
+	HttpsTimeout       time.Duration
+
+	Output string
 }
 
 type CleanArgs struct {
-	NamespacePrefix string
-	NamespaceRange  string
-	Namespace       string
-	SvcPrefix       string
-	Concurrency     int
+	NamespacePrefix   string
+	NamespaceRange    string
+	Namespace         string
+	SvcPrefix         string
+	Selector          string
+	SvcRegex          string
+	NamespaceSelector string
+	NamespaceRegex    string
+	RunID             string
+	Concurrency       int
+
+	DeleteNamespaces bool
+	Timeout          time.Duration
+
+	// Wait is whether to wait for the objects of the Knative Services to be deleted and measure their teardown
+	Wait   bool
+	Output string
 }
 
 type MeasureArgs struct {
-	SvcRange        string
-	Namespace       string
-	SvcPrefix       string
-	NamespaceRange  string
-	NamespacePrefix string
-	Concurrency     int
-	Verbose         bool
-	Output          string
+	SvcRange          string
+	Namespace         string
+	SvcPrefix         string
+	NamespaceRange    string
+	NamespacePrefix   string
+	Selector          string
+	SvcRegex          string
+	NamespaceSelector string
+	NamespaceRegex    string
+	RunID             string
+	Concurrency       int
+	Verbose           bool
+	Output            string
+
+	// TLS is whether to measure the Certificates and DomainMappings of the services
+	TLS bool
 }
 
 type ScaleArgs struct {
-	Svc              string
-	SvcRange         string
-	Namespace        string
-	SvcPrefix        string
-	NamespaceRange   string
-	NamespacePrefix  string
-	Concurrency      int
-	MaxRetries       int
-	RequestInterval  time.Duration
-	RequestTimeout   time.Duration
-	ResolvableDomain bool
-	Verbose          bool
-	Output           string
-	Https            bool
-	Iterations       int
-	TimeInterval     time.Duration
-	StableWindow     string
+	Svc               string
+	SvcRange          string
+	Namespace         string
+	SvcPrefix         string
+	NamespaceRange    string
+	NamespacePrefix   string
+	Selector          string
+	SvcRegex          string
+	NamespaceSelector string
+	NamespaceRegex    string
+	RunID             string
+	Concurrency       int
+	Stagger           time.Duration
+	MaxRetries        int
+	RequestInterval   time.Duration
+	RequestTimeout    time.Duration
+	ResolvableDomain  bool
+	Verbose           bool
+	Output            string
+	Https             bool
+	Iterations        int
+	// ScaleToZeroTimeout is how long each iteration waits for the service to be scaled to zero
+	ScaleToZeroTimeout time.Duration
+	StableWindow       string
+	// JournalDir is the directory of the journal of the changes to the cluster, see mutation.Journal
+	JournalDir string
+	// Herd scales all the services from zero at the same instant in each iteration
+	Herd bool
 }
 
 type LoadArgs struct {
@@ -98,6 +163,11 @@ type LoadArgs struct {
 	SvcPrefix             string
 	NamespaceRange        string
 	NamespacePrefix       string
+	Selector              string
+	SvcRegex              string
+	NamespaceSelector     string
+	NamespaceRegex        string
+	RunID                 string
 	Verbose               bool
 	ResolvableDomain      bool
 	WaitPodsReadyDuration time.Duration
@@ -106,6 +176,125 @@ type LoadArgs struct {
 	LoadDuration          string
 	LoadConcurrency       string
 	Https                 bool
+	Concurrency           int
+	Stagger               time.Duration
+}
+
+type UpdateArgs struct {
+	Svc               string
+	SvcRange          string
+	Namespace         string
+	SvcPrefix         string
+	NamespaceRange    string
+	NamespacePrefix   string
+	Selector          string
+	SvcRegex          string
+	NamespaceSelector string
+	NamespaceRegex    string
+	RunID             string
+	Concurrency       int
+	Rate              float64
+	Iterations        int
+	EnvName           string
+	Image             string
+	PollInterval      time.Duration
+	Timeout           time.Duration
+	Verbose           bool
+	Output            string
+}
+
+type TrafficArgs struct {
+	Svc               string
+	SvcRange          string
+	Namespace         string
+	SvcPrefix         string
+	NamespaceRange    string
+	NamespacePrefix   string
+	Selector          string
+	SvcRegex          string
+	NamespaceSelector string
+	NamespaceRegex    string
+	RunID             string
+	Concurrency       int
+	Traffic           string
+	Tags              string
+	ResolvableDomain  bool
+	Https             bool
+	PollInterval      time.Duration
+	Timeout           time.Duration
+	Verbose           bool
+	Output            string
+}
+
+type ScenarioArgs struct {
+	File   string
+	Output string
+}
+
+type RestoreArgs struct {
+	RunID      string
+	JournalDir string
+}
+
+type GenerateResult struct {
+	RunID        string
+	Number       int
+	Concurrency  int
+	Namespaces   []string
+	Arrival      ArrivalInfo
+	DryRun       string `json:",omitempty"`
+	StartTime    time.Time
+	EndTime      time.Time
+	Duration     float64
+	Attempted    int
+	Created      int
+	Failed       int
+	Items        []GenerateItemResult
+	ReadyLatency LatencyResult
+	Admission    AdmissionResult
+	Phases       map[string]LatencyResult `json:",omitempty"`
+	// HTTPSResponse and DomainMappingHTTPSResponse aggregate the first HTTPS responses of the Knative Services and
+	// of their DomainMappings
+	HTTPSResponse              *LatencyResult `json:",omitempty"`
+	DomainMappingHTTPSResponse *LatencyResult `json:",omitempty"`
+}
+
+type GenerateItemResult struct {
+	Index         int
+	Namespace     string
+	Name          string
+	Created       bool
+	CreateLatency float64
+	ReadyDuration float64 `json:",omitempty"`
+	// FirstHTTPSResponse is the time from the create request to the first successful HTTPS response, and
+	// DomainMappingHTTPSResponse the one from the create request of the DomainMapping
+	FirstHTTPSResponse         float64            `json:",omitempty"`
+	DomainMappingHTTPSResponse float64            `json:",omitempty"`
+	Admission                  *AdmissionTiming   `json:",omitempty"`
+	Phases                     map[string]float64 `json:",omitempty"`
+	Error                      string             `json:",omitempty"`
+}
+
+// AdmissionTiming is the client side round trip of the create request of a Knative Service in seconds, split into
+// sending the request, the processing by the API server and its admission webhooks, and receiving the response
+type AdmissionTiming struct {
+	Total    float64
+	Send     float64
+	Server   float64
+	Response float64
+}
+
+// AdmissionResult aggregates the admission timings of the Knative Services
+type AdmissionResult struct {
+	Total    LatencyResult
+	Send     LatencyResult
+	Server   LatencyResult
+	Response LatencyResult
+}
+
+type ArrivalInfo struct {
+	Pattern    string
+	Parameters map[string]string
 }
 
 type MeasureResult struct {
@@ -114,18 +303,301 @@ type MeasureResult struct {
 	Service      ServiceCount
 	KnativeInfo  KnativeInfo
 	SvcReadyTime []float64 `json:"-"`
+	// Phases are the latency distributions of each phase of the creation lifecycle, by the name of its CSV column
+	Phases map[string]LatencyResult `json:",omitempty"`
+	// TimestampSources counts the sources of each raw timestamp, like api or event
+	TimestampSources map[string]map[string]int `json:",omitempty"`
+	// PodReady aggregates the durations from the revision creation for the first, median and last pod of each
+	// revision to be ready
+	PodReady *PodReadyResult `json:",omitempty"`
+	// Startup aggregates the startup phases of the pods from their Events
+	Startup *PodStartupResult  `json:",omitempty"`
+	Pods    []MeasurePodResult `json:",omitempty"`
+	// TLS are the Certificates and DomainMappings of the services, their phases being aggregated in Phases
+	TLS []MeasureTLSResult `json:",omitempty"`
+	// Failures are the services which are not measured, grouped in FailureGroups
+	Failures      []ServiceFailure `json:",omitempty"`
+	FailureGroups []FailureGroup   `json:",omitempty"`
+}
+
+// ServiceFailure is why a service is not measured, from the resource found failing
+type ServiceFailure struct {
+	ServiceName      string
+	ServiceNamespace string
+	// Status is NotReady, NotFound or Fail
+	Status       string
+	Resource     string
+	ResourceName string
+	// ServiceReason is the reason of the Ready condition of the service
+	ServiceReason string `json:",omitempty"`
+	Reason        string
+	Message       string
+}
+
+// MeasureTLSResult is the TLS of a service, in seconds from the creation of the Certificate of its Route for
+// certificate_ready
+type MeasureTLSResult struct {
+	ServiceName      string
+	ServiceNamespace string
+	Certificate      string `json:",omitempty"`
+	// Phases are the durations by the name of their CSV column, the phases not measured being absent
+	Phases         map[string]float64
+	DomainMappings []MeasureDomainMappingResult `json:",omitempty"`
+}
+
+// MeasureDomainMappingResult is a DomainMapping of a service, in seconds from the creation of the DomainMapping
+// except domain_mapping_certificate_ready which is from the creation of its Certificate
+type MeasureDomainMappingResult struct {
+	Name        string
+	Certificate string `json:",omitempty"`
+	Phases      map[string]float64
+}
+
+type FailureGroup struct {
+	Status   string
+	Resource string
+	Reason   string
+	Count    int
+}
+
+type PodReadyResult struct {
+	First  LatencyResult `json:"first"`
+	Median LatencyResult `json:"median"`
+	Last   LatencyResult `json:"last"`
+}
+
+// MeasurePodResult is the measurement of a pod of the latest ready revision of a service, in seconds from the pod
+// creation except PodCreated which is from the revision creation
+type MeasurePodResult struct {
+	ServiceName      string
+	ServiceNamespace string
+	PodName          string
+	PodCreated       float64
+	PodScheduled     float64
+	ContainersReady  float64
+	Ready            bool
+	Containers       []MeasureContainerResult
+	Startup          *PodStartup `json:",omitempty"`
+}
+
+// PodStartup is the breakdown in seconds of the startup of a pod from its Events
+type PodStartup struct {
+	Scheduling         float64
+	ImagePull          float64
+	ContainerCreation  float64
+	ContainerStart     float64
+	ReadinessProbeWait float64
+	ProbeFailures      int32
+}
+
+type PodStartupResult struct {
+	Scheduling         LatencyResult `json:"scheduling"`
+	ImagePull          LatencyResult `json:"imagePull"`
+	ContainerCreation  LatencyResult `json:"containerCreation"`
+	ContainerStart     LatencyResult `json:"containerStart"`
+	ReadinessProbeWait LatencyResult `json:"readinessProbeWait"`
+	ProbeFailures      int32         `json:"probeFailures"`
+}
+
+type MeasureContainerResult struct {
+	Name         string
+	Started      float64
+	Ready        bool
+	RestartCount int32
 }
 
 type ScaleResult struct {
 	KnativeInfo KnativeInfo
-	Measurment  []ScaleFromZeroResult
+	Schedule    ScheduleResult `json:"schedule"`
+	// Herd are the iterations of the services scaled from zero at the same instant, with --herd
+	Herd       []HerdIterationResult `json:"herd,omitempty"`
+	Measurment []ScaleFromZeroResult
+}
+
+// HerdIterationResult is an iteration of the services scaled from zero at the same instant, once all of them are at
+// zero. The latencies are the ones of the cold services, the services not at zero being warm
+type HerdIterationResult struct {
+	Iteration int       `json:"iteration"`
+	Released  time.Time `json:"released"`
+	Services  int       `json:"services"`
+	Cold      int       `json:"cold"`
+	Warm      int       `json:"warm"`
+	Failed    int       `json:"failed"`
+	// ReleaseSkew is the seconds from the release to the last request sent
+	ReleaseSkew       float64       `json:"releaseSkew"`
+	ServiceLatency    LatencyResult `json:"serviceLatency"`
+	DeploymentLatency LatencyResult `json:"deploymentLatency"`
+	// Spread is the seconds between the fastest and the slowest cold start
+	Spread float64 `json:"spread"`
+	// LastReady is the seconds from the release to the response of the last cold service
+	LastReady float64 `json:"lastReady"`
+}
+
+// ScheduleResult is how the services were run by a worker pool, at most Concurrency at a time and started at least
+// Stagger seconds apart, so that the results of runs of different sizes can be compared
+type ScheduleResult struct {
+	Concurrency int     `json:"concurrency"`
+	Stagger     float64 `json:"stagger"`
+	Services    int     `json:"services"`
+	// Waves is the number of services run one after the other by a worker when all the runs take the same time
+	Waves    int            `json:"waves"`
+	Duration float64        `json:"duration"`
+	Runs     []ScheduledRun `json:"runs"`
+}
+
+// ScheduledRun is the run of a service by a worker, Start and End being the seconds since the start of the pool
+type ScheduledRun struct {
+	ServiceName      string  `json:"serviceName"`
+	ServiceNamespace string  `json:"serviceNamespace"`
+	Worker           int     `json:"worker"`
+	Start            float64 `json:"start"`
+	End              float64 `json:"end"`
 }
 
 type ScaleFromZeroResult struct {
 	ServiceName       string
 	ServiceNamespace  string
-	ServiceLatency    LatencyResult `json:"serviceLatency"`
-	DeploymentLatency LatencyResult `json:"deploymentLatency"`
+	ServiceLatency    LatencyResult          `json:"serviceLatency"`
+	DeploymentLatency LatencyResult          `json:"deploymentLatency"`
+	Iterations        []ScaleIterationResult `json:"iterations,omitempty"`
+	// ColdIterations are the iterations which started with the service scaled to zero, the only ones in the latencies
+	ColdIterations int `json:"coldIterations"`
+	WarmIterations int `json:"warmIterations"`
+}
+
+// ScaleIterationResult is a scale from zero of a service, with the startup of the first pod to be ready
+type ScaleIterationResult struct {
+	Iteration int `json:"iteration"`
+	// Cold is whether the service was scaled to zero before the request, a warm iteration not measuring a cold start
+	Cold bool `json:"cold"`
+	// ScaleToZeroWait is the seconds waited for the service to be scaled to zero before the request
+	ScaleToZeroWait   float64     `json:"scaleToZeroWait"`
+	ServiceLatency    float64     `json:"serviceLatency"`
+	DeploymentLatency float64     `json:"deploymentLatency"`
+	PodName           string      `json:"podName,omitempty"`
+	PodStartup        *PodStartup `json:"podStartup,omitempty"`
+}
+
+type UpdateResult struct {
+	KnativeInfo       KnativeInfo
+	Total             int
+	Succeeded         int
+	Failed            int
+	RevisionReady     LatencyResult `json:"revisionReady"`
+	RouteReady        LatencyResult `json:"routeReady"`
+	OldPodsTerminated LatencyResult `json:"oldPodsTerminated"`
+	Measurment        []ServiceUpdateResult
+}
+
+type ServiceUpdateResult struct {
+	ServiceName               string
+	ServiceNamespace          string
+	Iteration                 int
+	OldRevision               string
+	NewRevision               string
+	UpdateTime                time.Time
+	RevisionReadyDuration     float64
+	RouteReadyDuration        float64
+	OldPodsTerminatedDuration float64
+	Error                     string `json:",omitempty"`
+}
+
+type CleanResult struct {
+	KnativeInfo KnativeInfo
+	Total       int
+	TornDown    int
+	// Phases are the latency distributions of the deletion of the objects of each kind, by the name of its CSV column
+	Phases                map[string]LatencyResult `json:"phases"`
+	TornDownLatency       LatencyResult            `json:"tornDown"`
+	NamespaceEmptyLatency LatencyResult            `json:"namespaceEmpty"`
+	Namespaces            []NamespaceTeardownResult
+	Measurment            []ServiceTeardownResult
+}
+
+// ServiceTeardownResult is the teardown of a Knative Service, in seconds from its deletion
+type ServiceTeardownResult struct {
+	ServiceName      string
+	ServiceNamespace string
+	// Phases are the durations until the last object of each kind is deleted, by the name of its CSV column
+	Phases   map[string]float64
+	TornDown float64
+	Complete bool
+	// Pending are the kind/name of the objects not deleted when the wait stopped
+	Pending []string `json:",omitempty"`
+}
+
+// NamespaceTeardownResult is the duration from the deletion of the first Knative Service of a namespace until the
+// namespace has no Knative Service, Revision, Deployment, Pod or Ingress left
+type NamespaceTeardownResult struct {
+	Namespace string
+	Empty     float64
+	Complete  bool
+	// Remaining are the kind/name of the objects left in the namespace when the wait stopped
+	Remaining []string `json:",omitempty"`
+}
+
+type TrafficResult struct {
+	KnativeInfo KnativeInfo
+	Total       int
+	Succeeded   int
+	Failed      int
+	// Unchanged is the number of the succeeded splits already served by the route, left out of the latencies
+	Unchanged    int
+	RouteReady   LatencyResult `json:"routeReady"`
+	IngressReady LatencyResult `json:"ingressReady"`
+	TagReady     LatencyResult `json:"tagReady"`
+	Measurment   []TrafficSplitResult
+}
+
+type TrafficSplitResult struct {
+	ServiceName                      string
+	ServiceNamespace                 string
+	Traffic                          string
+	UpdateTime                       time.Time
+	RouteReadyDuration               float64
+	IngressNetworkConfiguredDuration float64
+	IngressLoadBalancerReadyDuration float64
+	IngressReadyDuration             float64
+	// Unchanged is true if the route already served the split, so that the update was a no-op
+	Unchanged bool
+	Tags      []TagReadyResult
+	Error     string `json:",omitempty"`
+}
+
+type TagReadyResult struct {
+	Tag           string
+	URL           string
+	ReadyDuration float64
+	Error         string `json:",omitempty"`
+}
+
+type ScenarioResult struct {
+	Name      string
+	File      string
+	Status    string
+	StartTime time.Time
+	EndTime   time.Time
+	Duration  float64
+	Steps     []ScenarioStepResult
+	Cleanup   []ScenarioStepResult
+}
+
+type ScenarioStepResult struct {
+	Name      string
+	Type      string
+	Args      []string
+	Status    string
+	Error     string `json:",omitempty"`
+	StartTime time.Time
+	EndTime   time.Time
+	Duration  float64
+	Wait      *ScenarioWaitResult `json:",omitempty"`
+}
+
+type ScenarioWaitResult struct {
+	For      string
+	Duration float64
+	Error    string `json:",omitempty"`
 }
 
 type LatencyResult struct {
@@ -136,6 +608,7 @@ type LatencyResult struct {
 	P90     float64 `json:"p90"`
 	P95     float64 `json:"p95"`
 	P99     float64 `json:"p99"`
+	StdDev  float64 `json:"stddev"`
 }
 
 type PatchStringValue struct {
@@ -146,6 +619,7 @@ type PatchStringValue struct {
 
 type LoadResult struct {
 	KnativeInfo KnativeInfo
+	Schedule    ScheduleResult `json:"schedule"`
 	Measurment  []LoadFromZeroResult
 }
 
@@ -164,8 +638,8 @@ type LoadReplicaResult struct {
 	ReplicaReadyDuration float64
 }
 type LoadPodResult struct {
-	PodCreateTime    metav1.Time
-	PodReadyTime     metav1.Time
+	PodCreateTime    time.Time
+	PodReadyTime     time.Time
 	PodReadyDuration float64
 }
 type Sums struct {